@@ -19,6 +19,7 @@ package cl
 
 import (
 	"fmt"
+	goast "go/ast"
 	"go/types"
 	"log"
 	"reflect"
@@ -196,6 +197,14 @@ type Config struct {
 	// NoFileLine = true means not to generate file line comments.
 	NoFileLine bool
 
+	// NodeSpan, if not nil, is called with the Go node generated from each
+	// statement, function, call, selector and identifier, and the XGo span of
+	// the node, so that the Go code can be mapped back to the span. The Go
+	// node of a statement or function is its file line comments, and the one
+	// of an expression is the Go expression. It is ignored if NoFileLine is
+	// set. See github.com/goplus/xgo/tool.SourceMap.
+	NodeSpan func(node goast.Node, start, end token.Position)
+
 	// NoAutoGenMain = true means not to auto generate main func is no entry.
 	NoAutoGenMain bool
 
@@ -232,6 +241,9 @@ func (p *nodeInterp) LoadExpr(node ast.Node) string {
 	}
 	pos := p.fset.Position(start)
 	f := p.files[pos.Filename]
+	if f == nil { // eg. node of a Go file
+		return ""
+	}
 	n := int(node.End() - start)
 	return string(f.Code[pos.Offset : pos.Offset+n])
 }
@@ -351,6 +363,8 @@ type pkgCtx struct {
 	goxMainClass string
 	goxMain      int32 // normal gox files with main func
 
+	nodeSpan func(node goast.Node, start, end token.Position) // see Config.NodeSpan
+
 	featTypesAlias bool // support types alias
 }
 
@@ -519,6 +533,7 @@ func NewPackage(pkgPath string, pkg *ast.Package, conf *Config) (p *gogen.Packag
 		overpos:    make(map[string]token.Pos),
 		syms:       make(map[string]loader),
 		generics:   make(map[string]bool),
+		nodeSpan:   conf.NodeSpan,
 	}
 	confGox := &gogen.Config{
 		Types:           conf.Types,
@@ -17,13 +17,19 @@
 package cl_test
 
 import (
+	"fmt"
+	goast "go/ast"
+	"go/types"
 	"os"
+	"reflect"
+	"strings"
 	"sync"
 	"testing"
 
 	"github.com/goplus/gogen"
 	"github.com/goplus/xgo/cl"
 	"github.com/goplus/xgo/cl/cltest"
+	"github.com/goplus/xgo/token"
 )
 
 const (
@@ -3869,6 +3875,61 @@ func main() {
 `)
 }
 
+func TestNodeSpan(t *testing.T) {
+	conf := *gblConfLine
+	spans := make(map[string]string)     // file line comment => XGo span
+	exprSpans := make(map[string]string) // Go expression => XGo span
+	conf.NodeSpan = func(node goast.Node, start, end token.Position) {
+		span := fmt.Sprintf("%s:%d:%d-%d:%d", start.Filename, start.Line, start.Column, end.Line, end.Column)
+		switch node := node.(type) {
+		case *goast.CommentGroup:
+			spans[strings.TrimSpace(node.List[0].Text)] = span
+		case goast.Expr:
+			exprSpans[types.ExprString(node)] = span
+		default:
+			t.Fatalf("NodeSpan: %T", node)
+		}
+	}
+	gopClTestEx(t, &conf, "main", `
+func f() {
+	x := 1; println x
+}
+
+f()
+`, `package main
+
+import "fmt"
+//line /foo/bar.xgo:2:1
+func f() {
+//line /foo/bar.xgo:3:1
+	x := 1
+//line /foo/bar.xgo:3:1
+	fmt.Println(x)
+}
+//line /foo/bar.xgo:6
+func main() {
+//line /foo/bar.xgo:6:1
+	f()
+}
+`)
+	want := map[string]string{
+		"//line /foo/bar.xgo:2:1": "/foo/bar.xgo:2:1-4:2",
+		"//line /foo/bar.xgo:3:1": "/foo/bar.xgo:3:10-3:19", // the last statement of the line
+		"//line /foo/bar.xgo:6:1": "/foo/bar.xgo:6:1-6:4",
+	}
+	if !reflect.DeepEqual(spans, want) {
+		t.Fatal("NodeSpan:", spans)
+	}
+	want = map[string]string{
+		"x":              "/foo/bar.xgo:3:18-3:19",
+		"fmt.Println(x)": "/foo/bar.xgo:3:10-3:19",
+		"f()":            "/foo/bar.xgo:6:1-6:4",
+	}
+	if !reflect.DeepEqual(exprSpans, want) {
+		t.Fatal("NodeSpan of expressions:", exprSpans)
+	}
+}
+
 func TestCommentLineRoot(t *testing.T) {
 	conf := *cltest.Conf
 	conf.NoFileLine = false
@@ -20,9 +20,13 @@ import (
 	"fmt"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 
+	"github.com/goplus/xgo/cl"
 	"github.com/goplus/xgo/cl/cltest"
+	"github.com/goplus/xgo/parser"
+	"github.com/goplus/xgo/parser/fsx/memfs"
 )
 
 func codeErrorTest(t *testing.T, msg, src string) {
@@ -1117,3 +1121,19 @@ var a = struct{v int}{(x => x)}
 var a = struct{v int}{v: (x => x)}
 `)
 }
+
+// A mismatch raised in a Go file of the package has no XGo code to quote.
+func TestErrMismatchInGoFile(t *testing.T) {
+	fs := memfs.TwoFiles("/foo", "a.go", "package main\n\nvar _ int = \"s\"\n", "b.xgo", "echo 1\n")
+	pkgs, err := parser.ParseFSDir(cltest.Conf.Fset, fs, "/foo", parser.Config{})
+	if err != nil {
+		t.Fatal("parser.ParseFSDir:", err)
+	}
+	_, err = cl.NewPackage("", pkgs["main"], cltest.Conf)
+	if err == nil {
+		t.Fatal("no error?")
+	}
+	if ret := err.Error(); !strings.Contains(ret, "a.go:3:13: cannot use ") || !strings.Contains(ret, "as type int") {
+		t.Fatal("error:", ret)
+	}
+}
@@ -311,6 +311,9 @@ func callCmdNoArgs(ctx *blockCtx, src ast.Node, panicErr bool) (err error) {
 }
 
 func compileExpr(ctx *blockCtx, expr ast.Expr, inFlags ...int) {
+	if ctx.pkgCtx != nil && ctx.nodeSpan != nil {
+		defer exprSpanOf(ctx, expr, ctx.cb.InternalStack().Len())
+	}
 	switch v := expr.(type) {
 	case *ast.Ident:
 		flags, cmdNoArgs := identOrSelectorFlags(inFlags)
@@ -402,6 +405,22 @@ func compileExpr(ctx *blockCtx, expr ast.Expr, inFlags ...int) {
 	}
 }
 
+// exprSpanOf reports the span of expr if it is a call, selector or identifier
+// whose Go expression is pushed onto the stack of n elements, see
+// Config.NodeSpan.
+func exprSpanOf(ctx *blockCtx, expr ast.Expr, n int) {
+	switch expr.(type) {
+	case *ast.Ident, *ast.CallExpr, *ast.SelectorExpr:
+	default:
+		return
+	}
+	if stk := ctx.cb.InternalStack(); stk.Len() == n+1 {
+		if val := stk.Get(-1).Val; val != nil {
+			ctx.nodeSpanOf(val, expr)
+		}
+	}
+}
+
 func compileExprOrNone(ctx *blockCtx, expr ast.Expr) {
 	if expr != nil {
 		compileExpr(ctx, expr)
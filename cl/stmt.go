@@ -68,9 +68,18 @@ func commentStmtEx(cb *gogen.CodeBuilder, ctx *pkgCtx, stmt ast.Stmt) {
 	comments := &goast.CommentGroup{
 		List: []*goast.Comment{{Text: line}},
 	}
+	ctx.nodeSpanOf(comments, stmt)
 	cb.SetComments(comments, false)
 }
 
+// nodeSpanOf reports the span of node, whose Go code is goNode, see
+// Config.NodeSpan.
+func (p *pkgCtx) nodeSpanOf(goNode goast.Node, node ast.Node) {
+	if p.nodeSpan != nil {
+		p.nodeSpan(goNode, p.fset.Position(node.Pos()), p.fset.Position(node.End()))
+	}
+}
+
 func checkStmtDoc(stmt ast.Stmt) *ast.CommentGroup {
 	if decl, ok := stmt.(*ast.DeclStmt); ok {
 		if d, ok := decl.Decl.(*ast.GenDecl); ok {
@@ -101,6 +110,9 @@ func commentFunc(ctx *blockCtx, fn *gogen.Func, decl *ast.FuncDecl) {
 		if decl.Doc != nil {
 			doc.List = append(doc.List, decl.Doc.List...)
 		}
+		if !decl.Shadow {
+			ctx.nodeSpanOf(doc, decl)
+		}
 		fn.SetComments(ctx.pkg, doc)
 	} else if decl.Doc != nil {
 		fn.SetComments(ctx.pkg, decl.Doc)
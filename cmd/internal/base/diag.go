@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package base
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/goplus/xgo/tool"
+)
+
+// Diags reports errors of XGo code as diagnostics for the -json and -sarif
+// flags of a command.
+type Diags struct {
+	JSON  bool   // print diagnostics to stdout as a stream of JSON objects
+	SARIF string // write diagnostics to this file as a SARIF log at Close
+
+	conf *tool.Config
+	list []tool.Diagnostic
+}
+
+// NewDiags creates a Diags converting errors by conf.
+func NewDiags(conf *tool.Config, json bool, sarif string) *Diags {
+	return &Diags{JSON: json, SARIF: sarif, conf: conf}
+}
+
+// Report reports err as diagnostics. It returns false if err still needs to be
+// printed as text, that is, if -json isn't set.
+func (p *Diags) Report(err error) bool {
+	if !p.JSON && p.SARIF == "" {
+		return false
+	}
+	diags := p.conf.Diagnostics(err)
+	if p.SARIF != "" {
+		p.list = append(p.list, diags...)
+	}
+	if p.JSON {
+		tool.WriteDiagnostics(os.Stdout, diags)
+	}
+	return p.JSON
+}
+
+// Close writes the SARIF log if -sarif is set, even if there are no errors, so
+// that fixed errors are closed by code scanning.
+func (p *Diags) Close() {
+	if p.SARIF == "" {
+		return
+	}
+	f, err := os.Create(p.SARIF)
+	if err == nil {
+		err = tool.WriteSARIF(f, p.list, p.conf.XGo.Version)
+		if e := f.Close(); err == nil {
+			err = e
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "write SARIF log:", err)
+	}
+}
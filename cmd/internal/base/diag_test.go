@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package base
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/goplus/mod/env"
+	"github.com/goplus/xgo/tool"
+)
+
+// captureStdout returns what f writes to os.Stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	file, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	stdout := os.Stdout
+	os.Stdout = file
+	defer func() { os.Stdout = stdout }()
+	f()
+	b, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// readSARIF returns the results of the SARIF log file.
+func readSARIF(t *testing.T, file string) (version string, results []map[string]any) {
+	t.Helper()
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Results []map[string]any `json:"results"`
+		} `json:"runs"`
+	}
+	if err = json.Unmarshal(b, &log); err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Runs) != 1 {
+		t.Fatalf("%d runs", len(log.Runs))
+	}
+	return log.Version, log.Runs[0].Results
+}
+
+func TestDiags(t *testing.T) {
+	conf := &tool.Config{XGo: &env.XGo{Version: "v1.5.0"}}
+	errs := []error{errors.New("no Go files"), errors.New("no main")}
+	sarif := filepath.Join(t.TempDir(), "out.sarif")
+	tests := []struct {
+		name     string
+		json     bool
+		sarif    string
+		reported bool // returned by Report
+		stdout   string
+		results  int // -1 if no SARIF log
+	}{
+		{"text", false, "", false, "", -1},
+		{"json", true, "", true,
+			`{"range":{"start":{"line":0,"col":0},"end":{"line":0,"col":0}},"severity":"error","code":"other","message":"no Go files"}` + "\n" +
+				`{"range":{"start":{"line":0,"col":0},"end":{"line":0,"col":0}},"severity":"error","code":"other","message":"no main"}` + "\n", -1},
+		{"sarif", false, sarif, false, "", 2},
+		{"json and sarif", true, sarif, true,
+			`{"range":{"start":{"line":0,"col":0},"end":{"line":0,"col":0}},"severity":"error","code":"other","message":"no Go files"}` + "\n" +
+				`{"range":{"start":{"line":0,"col":0},"end":{"line":0,"col":0}},"severity":"error","code":"other","message":"no main"}` + "\n", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(sarif)
+			diags := NewDiags(conf, tt.json, tt.sarif)
+			stdout := captureStdout(t, func() {
+				for _, err := range errs {
+					if reported := diags.Report(err); reported != tt.reported {
+						t.Errorf("Report(%v) = %v", err, reported)
+					}
+				}
+				diags.Close()
+			})
+			if stdout != tt.stdout {
+				t.Errorf("stdout: %q", stdout)
+			}
+			if tt.results < 0 {
+				if _, err := os.Stat(sarif); err == nil {
+					t.Error("SARIF log written")
+				}
+				return
+			}
+			version, results := readSARIF(t, sarif)
+			if version != "2.1.0" || len(results) != tt.results {
+				t.Fatalf("SARIF log: version %q, %d results", version, len(results))
+			}
+			want := map[string]any{"ruleId": "other", "level": "error", "message": map[string]any{"text": "no main"}}
+			if !reflect.DeepEqual(results[1], want) {
+				t.Errorf("SARIF result: %v", results[1])
+			}
+		})
+	}
+}
+
+func TestDiagsNoErrors(t *testing.T) {
+	conf := &tool.Config{XGo: &env.XGo{Version: "v1.5.0"}}
+	sarif := filepath.Join(t.TempDir(), "out.sarif")
+	if err := os.WriteFile(sarif, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	NewDiags(conf, false, sarif).Close()
+	if _, results := readSARIF(t, sarif); len(results) != 0 {
+		t.Fatalf("SARIF log: %d results", len(results))
+	}
+}
@@ -34,14 +34,18 @@ import (
 
 // gop build
 var Cmd = &base.Command{
-	UsageLine: "gop build [-debug -o output] [packages]",
+	UsageLine: "gop build [-debug -overlay -rawpos -json -sarif file -o output] [packages]",
 	Short:     "Build XGo files",
 }
 
 var (
-	flag       = &Cmd.Flag
-	flagDebug  = flag.Bool("debug", false, "print debug information")
-	flagOutput = flag.String("o", "", "gop build output file")
+	flag        = &Cmd.Flag
+	flagDebug   = flag.Bool("debug", false, "print debug information")
+	flagOutput  = flag.String("o", "", "gop build output file")
+	flagOverlay = flag.Bool("overlay", false, "generate Go files into the cache instead of the source directories")
+	flagRawPos  = flag.Bool("rawpos", false, "don't map the positions reported by the go command to XGo code")
+	flagJSON    = flag.Bool("json", false, "print errors of XGo code as a stream of JSON diagnostics to stdout")
+	flagSARIF   = flag.String("sarif", "", "write errors of XGo code to the `file` as a SARIF log, eg. for GitHub code scanning")
 )
 
 func init() {
@@ -74,13 +78,23 @@ func runCmd(cmd *base.Command, args []string) {
 		log.Panicln("too many arguments:", args)
 	}
 
-	conf, err := tool.NewDefaultConf(".", tool.ConfFlagNoTestFiles, pass.Tags())
+	confFlags := tool.ConfFlagNoTestFiles | tool.ConfFlagSourceMap
+	if *flagOverlay {
+		confFlags |= tool.ConfFlagOverlay
+	}
+	conf, err := tool.NewDefaultConf(".", confFlags, pass.Tags())
 	if err != nil {
 		log.Panicln("tool.NewDefaultConf:", err)
 	}
 	defer conf.UpdateCache()
+	defer conf.Overlay.Close()
+	diags := base.NewDiags(conf, *flagJSON, *flagSARIF)
+	defer diags.Close()
 
 	confCmd := conf.NewGoCmdConf()
+	if *flagRawPos {
+		confCmd.MapPos = nil
+	}
 	if *flagOutput != "" {
 		output, err := filepath.Abs(*flagOutput)
 		if err != nil {
@@ -89,10 +103,10 @@ func runCmd(cmd *base.Command, args []string) {
 		confCmd.Flags = []string{"-o", output}
 	}
 	confCmd.Flags = append(confCmd.Flags, pass.Args...)
-	build(proj, conf, confCmd)
+	build(proj, conf, confCmd, diags)
 }
 
-func build(proj xgoprojs.Proj, conf *tool.Config, build *gocmd.BuildConfig) {
+func build(proj xgoprojs.Proj, conf *tool.Config, build *gocmd.BuildConfig, diags *base.Diags) {
 	const flags = tool.GenFlagPrompt
 	var obj string
 	var err error
@@ -111,10 +125,14 @@ func build(proj xgoprojs.Proj, conf *tool.Config, build *gocmd.BuildConfig) {
 	if tool.NotFound(err) {
 		fmt.Fprintf(os.Stderr, "gop build %v: not found\n", obj)
 	} else if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		if !diags.Report(err) {
+			fmt.Fprintln(os.Stderr, err)
+		}
 	} else {
 		return
 	}
+	diags.Close() // os.Exit skips deferred calls
+	conf.Overlay.Close()
 	os.Exit(1)
 }
 
@@ -33,7 +33,7 @@ import (
 
 // gop go
 var Cmd = &base.Command{
-	UsageLine: "gop go [-v] [packages|files]",
+	UsageLine: "gop go [-v -p n -srcmap -json -sarif file] [packages|files]",
 	Short:     "Convert XGo code into Go code",
 }
 
@@ -44,7 +44,11 @@ var (
 	flagSingleMode       = flag.Bool("s", false, "run in single file mode for package")
 	flagIgnoreNotatedErr = flag.Bool(
 		"ignore-notated-error", false, "ignore notated errors, only available together with -t (check mode)")
-	flagTags = flag.String("tags", "", "a comma-separated list of additional build tags to consider satisfied")
+	flagTags     = flag.String("tags", "", "a comma-separated list of additional build tags to consider satisfied")
+	flagParallel = flag.Int("p", 1, "the number of packages that can be generated in parallel")
+	flagSrcMap   = flag.Bool("srcmap", false, "save the source map of each generated Go file in the user cache")
+	flagJSON     = flag.Bool("json", false, "print errors as a stream of JSON diagnostics to stdout")
+	flagSARIF    = flag.String("sarif", "", "write errors to the `file` as a SARIF log, eg. for GitHub code scanning")
 )
 
 func init() {
@@ -72,13 +76,23 @@ func runCmd(cmd *base.Command, args []string) {
 		cl.SetDisableRecover(true)
 	}
 
-	conf, err := tool.NewDefaultConf(".", 0, *flagTags)
+	var confFlags tool.ConfFlags
+	if *flagSrcMap {
+		confFlags |= tool.ConfFlagSourceMap
+	}
+	conf, err := tool.NewDefaultConf(".", confFlags, *flagTags)
 	if err != nil {
 		log.Panicln("tool.NewDefaultConf:", err)
 	}
 	defer conf.UpdateCache()
+	conf.Parallel = *flagParallel
+	diags := base.NewDiags(conf, *flagJSON, *flagSARIF)
+	defer diags.Close()
 
-	flags := tool.GenFlagPrintError | tool.GenFlagPrompt
+	flags := tool.GenFlagPrompt
+	if !diags.JSON {
+		flags |= tool.GenFlagPrintError
+	}
 	if *flagCheckMode {
 		flags |= tool.GenFlagCheckOnly
 		if *flagIgnoreNotatedErr {
@@ -100,7 +114,9 @@ func runCmd(cmd *base.Command, args []string) {
 			log.Panicln("`gop go` doesn't support", reflect.TypeOf(v))
 		}
 		if err != nil {
+			diags.Report(err)
 			fmt.Fprintf(os.Stderr, "GenGo failed: %d errors.\n", errorNum(err))
+			diags.Close() // os.Exit skips deferred calls
 			os.Exit(1)
 		}
 	}
@@ -34,13 +34,17 @@ import (
 
 // gop install
 var Cmd = &base.Command{
-	UsageLine: "gop install [-debug] [packages]",
+	UsageLine: "gop install [-debug -overlay -rawpos -json -sarif file] [packages]",
 	Short:     "Build XGo files and install target to GOBIN",
 }
 
 var (
-	flag      = &Cmd.Flag
-	flagDebug = flag.Bool("debug", false, "print debug information")
+	flag        = &Cmd.Flag
+	flagDebug   = flag.Bool("debug", false, "print debug information")
+	flagOverlay = flag.Bool("overlay", false, "generate Go files into the cache instead of the source directories")
+	flagRawPos  = flag.Bool("rawpos", false, "don't map the positions reported by the go command to XGo code")
+	flagJSON    = flag.Bool("json", false, "print errors of XGo code as a stream of JSON diagnostics to stdout")
+	flagSARIF   = flag.String("sarif", "", "write errors of XGo code to the `file` as a SARIF log, eg. for GitHub code scanning")
 )
 
 func init() {
@@ -71,20 +75,30 @@ func runCmd(cmd *base.Command, args []string) {
 		cl.SetDisableRecover(true)
 	}
 
-	conf, err := tool.NewDefaultConf(".", tool.ConfFlagNoTestFiles, pass.Tags())
+	confFlags := tool.ConfFlagNoTestFiles | tool.ConfFlagSourceMap
+	if *flagOverlay {
+		confFlags |= tool.ConfFlagOverlay
+	}
+	conf, err := tool.NewDefaultConf(".", confFlags, pass.Tags())
 	if err != nil {
 		log.Panicln("tool.NewDefaultConf:", err)
 	}
 	defer conf.UpdateCache()
+	defer conf.Overlay.Close()
+	diags := base.NewDiags(conf, *flagJSON, *flagSARIF)
+	defer diags.Close()
 
 	confCmd := conf.NewGoCmdConf()
+	if *flagRawPos {
+		confCmd.MapPos = nil
+	}
 	confCmd.Flags = pass.Args
 	for _, proj := range projs {
-		install(proj, conf, confCmd)
+		install(proj, conf, confCmd, diags)
 	}
 }
 
-func install(proj xgoprojs.Proj, conf *tool.Config, install *gocmd.InstallConfig) {
+func install(proj xgoprojs.Proj, conf *tool.Config, install *gocmd.InstallConfig, diags *base.Diags) {
 	const flags = tool.GenFlagPrompt
 	var obj string
 	var err error
@@ -103,10 +117,14 @@ func install(proj xgoprojs.Proj, conf *tool.Config, install *gocmd.InstallConfig
 	if tool.NotFound(err) {
 		fmt.Fprintf(os.Stderr, "gop install %v: not found\n", obj)
 	} else if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		if !diags.Report(err) {
+			fmt.Fprintln(os.Stderr, err)
+		}
 	} else {
 		return
 	}
+	diags.Close() // os.Exit skips deferred calls
+	conf.Overlay.Close()
 	os.Exit(1)
 }
 
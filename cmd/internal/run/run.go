@@ -33,7 +33,7 @@ import (
 
 // gop run
 var Cmd = &base.Command{
-	UsageLine: "gop run [-nc -asm -quiet -debug -prof] package [arguments...]",
+	UsageLine: "gop run [-nc -asm -quiet -debug -prof -overlay -rawpos -json -sarif file] package [arguments...]",
 	Short:     "Run a XGo program",
 }
 
@@ -44,6 +44,10 @@ var (
 	flagQuiet   = flag.Bool("quiet", false, "don't generate any compiling stage log")
 	flagNoChdir = flag.Bool("nc", false, "don't change dir (only for `gop run pkgPath`)")
 	flagProf    = flag.Bool("prof", false, "do profile and generate profile report")
+	flagOverlay = flag.Bool("overlay", false, "generate Go files into the cache instead of the source directories")
+	flagRawPos  = flag.Bool("rawpos", false, "don't map the positions reported by the go command to XGo code")
+	flagJSON    = flag.Bool("json", false, "print errors of XGo code as a stream of JSON diagnostics to stdout")
+	flagSARIF   = flag.String("sarif", "", "write errors of XGo code to the `file` as a SARIF log, eg. for GitHub code scanning")
 )
 
 func init() {
@@ -80,21 +84,31 @@ func runCmd(cmd *base.Command, args []string) {
 	}
 
 	noChdir := *flagNoChdir
-	conf, err := tool.NewDefaultConf(".", tool.ConfFlagNoTestFiles, pass.Tags())
+	confFlags := tool.ConfFlagNoTestFiles | tool.ConfFlagSourceMap
+	if *flagOverlay {
+		confFlags |= tool.ConfFlagOverlay
+	}
+	conf, err := tool.NewDefaultConf(".", confFlags, pass.Tags())
 	if err != nil {
 		log.Panicln("tool.NewDefaultConf:", err)
 	}
 	defer conf.UpdateCache()
+	defer conf.Overlay.Close()
+	diags := base.NewDiags(conf, *flagJSON, *flagSARIF)
+	defer diags.Close()
 
 	if !conf.Mod.HasModfile() { // if no go.mod, check GopDeps
 		conf.XGoDeps = new(int)
 	}
 	confCmd := conf.NewGoCmdConf()
+	if *flagRawPos {
+		confCmd.MapPos = nil
+	}
 	confCmd.Flags = pass.Args
-	run(proj, args, !noChdir, conf, confCmd)
+	run(proj, args, !noChdir, conf, confCmd, diags)
 }
 
-func run(proj xgoprojs.Proj, args []string, chDir bool, conf *tool.Config, run *gocmd.RunConfig) {
+func run(proj xgoprojs.Proj, args []string, chDir bool, conf *tool.Config, run *gocmd.RunConfig, diags *base.Diags) {
 	const flags = 0
 	var obj string
 	var err error
@@ -113,10 +127,14 @@ func run(proj xgoprojs.Proj, args []string, chDir bool, conf *tool.Config, run *
 	if tool.NotFound(err) {
 		fmt.Fprintf(os.Stderr, "gop run %v: not found\n", obj)
 	} else if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		if !diags.Report(err) {
+			fmt.Fprintln(os.Stderr, err)
+		}
 	} else {
 		return
 	}
+	diags.Close() // os.Exit skips deferred calls
+	conf.Overlay.Close()
 	os.Exit(1)
 }
 
@@ -19,6 +19,11 @@ package serve
 
 import (
 	"context"
+	"expvar"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
 
 	"github.com/goplus/xgo/cmd/internal/base"
 	"github.com/goplus/xgo/x/jsonrpc2"
@@ -36,6 +41,15 @@ var Cmd = &base.Command{
 var (
 	flag        = &Cmd.Flag
 	flagVerbose = flag.Bool("v", false, "print verbose information")
+	flagListen  = flag.String("listen", "", "listen on tcp:host:port or unix:path instead of stdin/stdout")
+	flagIdle    = flag.Duration("idle", 0, "shut down after no connections for the duration (0 means never)")
+	flagFramer  = flag.String("framer", "header", "message framing: header (Content-Length headers), raw (newline-delimited) or length (length-prefixed)")
+	flagMaxMsg  = flag.Int64("maxmsg", 0, "maximum size of a message in bytes (0 means 64MB)")
+	flagTrace   = flag.Bool("trace", false, "log each request with its duration")
+	flagTimeout = flag.Duration("timeout", 0, "cancel a request after the duration (0 means never)")
+	flagRecord  = flag.String("record", "", "record the messages of the session to a JSONL file")
+	flagReplay  = flag.String("replay", "", "replay a session recorded by -record and report responses that differ")
+	flagDebug   = flag.String("debug", "", "serve request metrics at http://host:port/debug/vars")
 )
 
 func init() {
@@ -52,11 +66,87 @@ func runCmd(cmd *base.Command, args []string) {
 		jsonrpc2.SetDebug(jsonrpc2.DbgFlagCall)
 	}
 
-	listener := stdio.Listener(false)
+	framer, err := langserver.NewFramer(*flagFramer, *flagMaxMsg)
+	if err != nil {
+		log.Fatalln("serve:", err)
+	}
+
+	if *flagReplay != "" {
+		replay(framer, *flagReplay)
+		return
+	}
+	if *flagRecord != "" {
+		f, err := os.Create(*flagRecord)
+		if err != nil {
+			log.Fatalln("serve:", err)
+		}
+		defer f.Close()
+		framer = jsonrpc2.TraceFramer(framer, f)
+	}
+
+	conf := &langserver.Config{Framer: framer}
+	if *flagTimeout > 0 {
+		conf.MethodTimeouts = map[string]time.Duration{"": *flagTimeout}
+	}
+	if *flagTrace {
+		conf.Interceptors = append(conf.Interceptors, jsonrpc2.LogInterceptor(func(e *jsonrpc2.LogEntry) {
+			log.Println(e)
+		}))
+	}
+	if *flagDebug != "" {
+		metrics := new(jsonrpc2.Metrics)
+		expvar.Publish("jsonrpc2", metrics)
+		conf.Interceptors = append(conf.Interceptors, metrics.Interceptor())
+		go func() {
+			log.Println("debug:", http.ListenAndServe(*flagDebug, nil))
+		}()
+	}
+	conf.Interceptors = append(conf.Interceptors, jsonrpc2.RecoverInterceptor(func(v any, stack []byte) {
+		log.Printf("panic: %v\n%s", v, stack)
+	}))
+
+	ctx := context.Background()
+	var listener langserver.Listener
+	if *flagListen != "" {
+		listener, err = langserver.Listen(ctx, *flagListen)
+		if err != nil {
+			log.Fatalln("listen failed:", err)
+		}
+	} else {
+		listener = stdio.Listener(false)
+	}
+	if *flagIdle > 0 {
+		listener = jsonrpc2.NewIdleListener(*flagIdle, listener)
+	}
 	defer listener.Close()
 
-	server := langserver.NewServer(context.Background(), listener, nil)
-	server.Wait()
+	server := langserver.NewServer(ctx, listener, conf)
+	if err = server.Wait(); err != nil && err != jsonrpc2.ErrIdleTimeout {
+		log.Println("serve:", err)
+	}
+}
+
+func replay(framer jsonrpc2.Framer, file string) {
+	f, err := os.Open(file)
+	if err != nil {
+		log.Fatalln("replay:", err)
+	}
+	records, err := jsonrpc2.ReadRecords(f)
+	f.Close()
+	if err != nil {
+		log.Fatalln("replay:", err)
+	}
+	diffs, err := langserver.Replay(context.Background(), records, &langserver.Config{Framer: framer})
+	if err != nil {
+		log.Fatalln("replay:", err)
+	}
+	for _, diff := range diffs {
+		fmt.Fprintln(os.Stderr, diff)
+	}
+	if len(diffs) > 0 {
+		fmt.Fprintf(os.Stderr, "%d responses differ\n", len(diffs))
+		os.Exit(1)
+	}
 }
 
 // -----------------------------------------------------------------------------
@@ -33,13 +33,17 @@ import (
 
 // gop test
 var Cmd = &base.Command{
-	UsageLine: "gop test [-debug] [packages]",
+	UsageLine: "gop test [-debug -overlay -rawpos -diagjson -sarif file] [packages]",
 	Short:     "Test XGo packages",
 }
 
 var (
-	flag      = &Cmd.Flag
-	flagDebug = flag.Bool("debug", false, "print debug information")
+	flag        = &Cmd.Flag
+	flagDebug   = flag.Bool("debug", false, "print debug information")
+	flagOverlay = flag.Bool("overlay", false, "generate Go files into the cache instead of the source directories")
+	flagRawPos  = flag.Bool("rawpos", false, "don't map the positions reported by the go command to XGo code")
+	flagJSON    = flag.Bool("diagjson", false, "print errors of XGo code as a stream of JSON diagnostics to stdout, unlike -json of go test")
+	flagSARIF   = flag.String("sarif", "", "write errors of XGo code to the `file` as a SARIF log, eg. for GitHub code scanning")
 )
 
 func init() {
@@ -69,20 +73,30 @@ func runCmd(cmd *base.Command, args []string) {
 		cl.SetDisableRecover(true)
 	}
 
-	conf, err := tool.NewDefaultConf(".", 0, pass.Tags())
+	confFlags := tool.ConfFlagSourceMap
+	if *flagOverlay {
+		confFlags |= tool.ConfFlagOverlay
+	}
+	conf, err := tool.NewDefaultConf(".", confFlags, pass.Tags())
 	if err != nil {
 		log.Panicln("tool.NewDefaultConf:", err)
 	}
 	defer conf.UpdateCache()
+	defer conf.Overlay.Close()
+	diags := base.NewDiags(conf, *flagJSON, *flagSARIF)
+	defer diags.Close()
 
 	confCmd := conf.NewGoCmdConf()
+	if *flagRawPos {
+		confCmd.MapPos = nil
+	}
 	confCmd.Flags = pass.Args
 	for _, proj := range projs {
-		test(proj, conf, confCmd)
+		test(proj, conf, confCmd, diags)
 	}
 }
 
-func test(proj xgoprojs.Proj, conf *tool.Config, test *gocmd.TestConfig) {
+func test(proj xgoprojs.Proj, conf *tool.Config, test *gocmd.TestConfig, diags *base.Diags) {
 	const flags = tool.GenFlagPrompt
 	var obj string
 	var err error
@@ -101,10 +115,14 @@ func test(proj xgoprojs.Proj, conf *tool.Config, test *gocmd.TestConfig) {
 	if tool.NotFound(err) {
 		fmt.Fprintf(os.Stderr, "gop test %v: not found\n", obj)
 	} else if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		if !diags.Report(err) {
+			fmt.Fprintln(os.Stderr, err)
+		}
 	} else {
 		return
 	}
+	diags.Close() // os.Exit skips deferred calls
+	conf.Overlay.Close()
 	os.Exit(1)
 }
 
@@ -0,0 +1,41 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiagJSONFlag(t *testing.T) {
+	pass := PassTestFlags(Cmd)
+	if err := flag.Parse([]string{"-json", "-diagjson", "-sarif", "out.sarif", "-run", "TestA", "./..."}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { *flagJSON, *flagSARIF = false, "" }()
+
+	// -json is go test's, and -diagjson and -sarif are ours
+	if want := []string{"-json=true", "-run=TestA"}; !reflect.DeepEqual(pass.Args, want) {
+		t.Errorf("passed to go test: %v, want %v", pass.Args, want)
+	}
+	if !*flagJSON || *flagSARIF != "out.sarif" {
+		t.Errorf("-diagjson = %v, -sarif = %q", *flagJSON, *flagSARIF)
+	}
+	if args := flag.Args(); !reflect.DeepEqual(args, []string{"./..."}) {
+		t.Errorf("packages: %v", args)
+	}
+}
@@ -0,0 +1,315 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package watch
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"go/importer"
+	gotoken "go/token"
+	"go/types"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goplus/mod/xgomod"
+	"github.com/goplus/xgo/ast/mod"
+	"github.com/goplus/xgo/parser"
+	"github.com/goplus/xgo/token"
+)
+
+// -----------------------------------------------------------------------------
+
+// pkgGraph is the import graph of the packages under a watched directory,
+// including the imports of their test files.
+type pkgGraph struct {
+	mod  *xgomod.Module
+	pkgs map[string]*pkgNode // dir => package
+}
+
+type pkgNode struct {
+	imports []string
+	xgo     bool   // has XGo files
+	hash    string // see updateHash
+}
+
+func newPkgGraph(root string, mod *xgomod.Module) *pkgGraph {
+	p := &pkgGraph{mod: mod, pkgs: make(map[string]*pkgNode)}
+	filepath.WalkDir(root, func(dir string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if dir != root {
+			name := d.Name()
+			if strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".") || name == "testdata" || hasMod(dir) {
+				return filepath.SkipDir
+			}
+		}
+		p.update(dir)
+		return nil
+	})
+	byPath := make(map[string]*pkgNode, len(p.pkgs))
+	for dir, pkg := range p.pkgs {
+		if pkgPath := p.pkgPath(dir); pkgPath != "" {
+			byPath[pkgPath] = pkg
+		}
+	}
+	pkgPaths := make([]string, 0, len(byPath))
+	for pkgPath := range byPath {
+		pkgPaths = append(pkgPaths, pkgPath)
+	}
+	for pkgPath, hash := range apiHashes(mod.Root(), pkgPaths) {
+		byPath[pkgPath].hash = hash
+	}
+	return p
+}
+
+func hasMod(dir string) bool {
+	_, err := os.Lstat(filepath.Join(dir, "go.mod"))
+	return err == nil
+}
+
+// update reloads the imports of the package in dir.
+func (p *pkgGraph) update(dir string) {
+	pkgs, _ := parser.ParseDirEx(token.NewFileSet(), dir, parser.Config{
+		ClassKind: p.mod.ClassKind,
+		Mode:      parser.ImportsOnly,
+	})
+	node := &pkgNode{}
+	deps := mod.Deps{HandlePkg: func(pkgPath string) {
+		node.imports = append(node.imports, pkgPath)
+	}}
+	for name, pkg := range pkgs {
+		if strings.HasSuffix(name, "_test") {
+			continue
+		}
+		deps.Load(pkg, false)
+		node.xgo = node.xgo || len(pkg.Files) > 0
+	}
+	for name, pkg := range pkgs {
+		if strings.HasSuffix(name, "_test") { // external test package
+			deps.Load(pkg, false)
+		}
+	}
+	if len(pkgs) == 0 {
+		delete(p.pkgs, dir)
+		return
+	}
+	if old, ok := p.pkgs[dir]; ok {
+		node.hash = old.hash
+	}
+	p.pkgs[dir] = node
+}
+
+// updateHash recomputes the hash of the exported API of the package in dir,
+// and reports whether it changed. So it doesn't change if only the bodies of
+// functions change, and the dependents don't need to be generated again. A
+// package which fails to build always counts as changed.
+func (p *pkgGraph) updateHash(dir string) bool {
+	pkg, ok := p.pkgs[dir]
+	if !ok {
+		return false
+	}
+	pkgPath := p.pkgPath(dir)
+	if pkgPath == "" {
+		return false
+	}
+	old := pkg.hash
+	pkg.hash = apiHashes(p.mod.Root(), []string{pkgPath})[pkgPath]
+	return pkg.hash != old || pkg.hash == ""
+}
+
+// apiHashes returns the hashes of the exported API of the Go packages
+// pkgPaths in the module at root, computed from their export data. The hash
+// of a package which fails to build is "".
+func apiHashes(root string, pkgPaths []string) map[string]string {
+	ret := make(map[string]string, len(pkgPaths))
+	if len(pkgPaths) == 0 {
+		return ret
+	}
+	args := append([]string{"list", "-e", "-export", "-deps", "-f", "{{.ImportPath}}\t{{.Export}}"}, pkgPaths...)
+	cmd := exec.Command("go", args...)
+	cmd.Dir = root
+	out, _ := cmd.Output()
+	exports := make(map[string]string) // import path => export data file
+	for _, line := range strings.Split(string(out), "\n") {
+		if pkgPath, file, ok := strings.Cut(line, "\t"); ok && file != "" {
+			exports[pkgPath] = file
+		}
+	}
+	imp := importer.ForCompiler(gotoken.NewFileSet(), "gc", func(pkgPath string) (io.ReadCloser, error) {
+		file, ok := exports[pkgPath]
+		if !ok {
+			return nil, fmt.Errorf("no export data of %s", pkgPath)
+		}
+		return os.Open(file)
+	})
+	for _, pkgPath := range pkgPaths {
+		if _, ok := exports[pkgPath]; !ok {
+			ret[pkgPath] = ""
+			continue
+		}
+		pkg, err := imp.Import(pkgPath)
+		if err != nil {
+			ret[pkgPath] = ""
+			continue
+		}
+		ret[pkgPath] = apiHash(pkg)
+	}
+	return ret
+}
+
+// apiHash returns the hash of the exported objects of pkg, with the types
+// and the methods of all its types, which exported ones may embed.
+func apiHash(pkg *types.Package) string {
+	qf := func(pkg *types.Package) string { return pkg.Path() }
+	var lines []string
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		tn, isType := obj.(*types.TypeName)
+		if !obj.Exported() && !isType {
+			continue
+		}
+		line := types.ObjectString(obj, qf)
+		if c, ok := obj.(*types.Const); ok {
+			line += " = " + c.Val().ExactString()
+		}
+		lines = append(lines, line)
+		if !isType {
+			continue
+		}
+		if named, ok := tn.Type().(*types.Named); ok {
+			for i, n := 0, named.NumMethods(); i < n; i++ {
+				if m := named.Method(i); m.Exported() {
+					lines = append(lines, types.ObjectString(m, qf))
+				}
+			}
+		}
+	}
+	sort.Strings(lines)
+	h := sha256.New()
+	for _, line := range lines {
+		io.WriteString(h, line+"\n")
+	}
+	return base64.RawStdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// pkgPath returns the import path of the package in dir, "" if dir is out of
+// the module.
+func (p *pkgGraph) pkgPath(dir string) string {
+	root := p.mod.Root()
+	if root == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	if rel == "." {
+		return p.mod.Path()
+	}
+	return p.mod.Path() + "/" + filepath.ToSlash(rel)
+}
+
+// dependents returns dirs and the packages depending on them directly or
+// indirectly, in the order of dirs and then sorted.
+func (p *pkgGraph) dependents(dirs []string) []string {
+	affected := make(map[string]bool) // import path => affected
+	for _, dir := range dirs {
+		if pkgPath := p.pkgPath(dir); pkgPath != "" {
+			affected[pkgPath] = true
+		}
+	}
+	ret := append([]string(nil), dirs...)
+	n := len(ret)
+	for changed := true; changed; {
+		changed = false
+		for dir, pkg := range p.pkgs {
+			pkgPath := p.pkgPath(dir)
+			if pkgPath == "" || affected[pkgPath] {
+				continue
+			}
+			for _, imp := range pkg.imports {
+				if affected[imp] {
+					affected[pkgPath], changed = true, true
+					ret = append(ret, dir)
+					break
+				}
+			}
+		}
+	}
+	sort.Strings(ret[n:])
+	return ret
+}
+
+// genOrder returns the packages to generate when dirs change: dirs and the
+// XGo packages depending on them, with dependencies before dependents.
+func (p *pkgGraph) genOrder(dirs []string) []string {
+	changed := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		changed[dir] = true
+	}
+	byPath := make(map[string]string) // import path => dir
+	var todo []string
+	for _, dir := range p.dependents(dirs) {
+		if pkg, ok := p.pkgs[dir]; changed[dir] || ok && pkg.xgo {
+			byPath[p.pkgPath(dir)] = dir
+			todo = append(todo, dir)
+		}
+	}
+	ret := make([]string, 0, len(todo))
+	visited := make(map[string]bool, len(todo))
+	var visit func(dir string)
+	visit = func(dir string) {
+		if visited[dir] {
+			return
+		}
+		visited[dir] = true
+		if pkg, ok := p.pkgs[dir]; ok {
+			for _, imp := range pkg.imports {
+				if dep, ok := byPath[imp]; ok {
+					visit(dep)
+				}
+			}
+		}
+		ret = append(ret, dir)
+	}
+	for _, dir := range todo {
+		visit(dir)
+	}
+	return ret
+}
+
+// importsAny reports whether the package in dir imports any of pkgPaths.
+func (p *pkgGraph) importsAny(dir string, pkgPaths map[string]bool) bool {
+	if pkg, ok := p.pkgs[dir]; ok {
+		for _, imp := range pkg.imports {
+			if pkgPaths[imp] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
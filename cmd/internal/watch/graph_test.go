@@ -0,0 +1,166 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package watch
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func (p *testRebuilder) dirs(rels ...string) []string {
+	dirs := make([]string, len(rels))
+	for i, rel := range rels {
+		dirs[i] = filepath.Join(p.root, filepath.FromSlash(rel))
+	}
+	return dirs
+}
+
+func (p *testRebuilder) rels(dirs []string) []string {
+	rels := make([]string, len(dirs))
+	for i, dir := range dirs {
+		rels[i] = p.rel(dir)
+	}
+	return rels
+}
+
+func TestPkgGraph(t *testing.T) {
+	p := newTestRebuilder(t)
+	writeFiles(t, p.root, map[string]string{
+		"_skip/a.xgo":      "package a\n",
+		"testdata/a.xgo":   "package a\n",
+		"sub/go.mod":       "module example.com/sub\n",
+		"sub/a.xgo":        "package a\n",
+		"lib/lib_test.xgo": "package lib_test\n\nimport \"example.com/app/util\"\n",
+	})
+	g := p.graph
+	g.update(p.dirs("lib")[0])
+	g.update(p.dirs("_skip")[0]) // not in the graph unless it changes
+	if got := p.rels(sortedDirs(g)); !reflect.DeepEqual(got, []string{".", "_skip", "lib", "mid", "util"}) {
+		t.Fatal("packages:", got)
+	}
+	delete(g.pkgs, p.dirs("_skip")[0])
+
+	if got := g.pkgPath(p.dirs("lib/sub")[0]); got != "example.com/app/lib/sub" {
+		t.Fatal("pkgPath:", got)
+	}
+	if got := g.pkgPath(p.root); got != "example.com/app" {
+		t.Fatal("pkgPath:", got)
+	}
+	if got := g.pkgPath(filepath.Dir(p.root)); got != "" {
+		t.Fatal("pkgPath out of the module:", got)
+	}
+
+	tests := []struct {
+		dirs       []string
+		dependents []string
+		genOrder   []string
+	}{
+		{[]string{"lib"}, []string{"lib", ".", "mid", "util"}, []string{"lib", "mid", "."}},
+		{[]string{"mid"}, []string{"mid", "."}, []string{"mid", "."}},
+		{[]string{"."}, []string{"."}, []string{"."}},
+		{[]string{"util"}, []string{"util", ".", "lib", "mid"}, []string{"lib", "util", "mid", "."}}, // a cycle as lib_test imports util
+		{[]string{".", "lib"}, []string{".", "lib", "mid", "util"}, []string{"lib", "mid", "."}},
+	}
+	for _, tt := range tests {
+		if got := p.rels(g.dependents(p.dirs(tt.dirs...))); !reflect.DeepEqual(got, tt.dependents) {
+			t.Errorf("dependents(%v): got %v, want %v", tt.dirs, got, tt.dependents)
+		}
+		if got := p.rels(g.genOrder(p.dirs(tt.dirs...))); !reflect.DeepEqual(got, tt.genOrder) {
+			t.Errorf("genOrder(%v): got %v, want %v", tt.dirs, got, tt.genOrder)
+		}
+	}
+
+	if !g.importsAny(p.dirs("mid")[0], map[string]bool{"example.com/app/lib": true}) {
+		t.Error("importsAny: mid doesn't import lib")
+	}
+	if g.importsAny(p.root, map[string]bool{"example.com/app/lib": true}) {
+		t.Error("importsAny: main imports lib")
+	}
+}
+
+func TestPkgGraphUpdateHash(t *testing.T) {
+	p := newTestRebuilder(t)
+	g, lib := p.graph, p.dirs("lib")[0]
+	if g.updateHash(lib) {
+		t.Fatal("updateHash: changed without changes")
+	}
+	// only the API of the package counts
+	writeFiles(t, p.root, map[string]string{"lib/lib.xgo": "package lib\n\nfunc Hello() string { return \"hello\" }\n"})
+	if err := fakeGenGo(lib); err != nil {
+		t.Fatal(err)
+	}
+	if g.updateHash(lib) {
+		t.Fatal("updateHash: changed after a body-only edit")
+	}
+	writeFiles(t, p.root, map[string]string{"lib/lib.xgo": "package lib\n\nfunc Hello() string { return \"hi\" }\n\nconst N = 1\n"})
+	if err := fakeGenGo(lib); err != nil {
+		t.Fatal(err)
+	}
+	if !g.updateHash(lib) {
+		t.Fatal("updateHash: unchanged after an exported constant is added")
+	}
+	writeFiles(t, p.root, map[string]string{"lib/lib.xgo": "package lib\n\nfunc Hello() string { return \"hi\" }\n\nconst N = 2\n"})
+	if err := fakeGenGo(lib); err != nil {
+		t.Fatal(err)
+	}
+	if !g.updateHash(lib) {
+		t.Fatal("updateHash: unchanged after the value of a constant changes")
+	}
+
+	// a package which fails to build always changes
+	writeFiles(t, p.root, map[string]string{"lib/lib.xgo": "package lib\n\nfunc Hello() string { return 1 }\n"})
+	if err := fakeGenGo(lib); err != nil {
+		t.Fatal(err)
+	}
+	if !g.updateHash(lib) || !g.updateHash(lib) {
+		t.Fatal("updateHash: unchanged after a build error")
+	}
+	writeFiles(t, p.root, map[string]string{"lib/lib.xgo": testModule["lib/lib.xgo"]})
+	if err := fakeGenGo(lib); err != nil {
+		t.Fatal(err)
+	}
+	if !g.updateHash(lib) {
+		t.Fatal("updateHash: unchanged after a build error is fixed")
+	}
+	if err := fakeGenGo(p.dirs("mid")[0]); err != nil { // the same content
+		t.Fatal(err)
+	}
+	if g.updateHash(p.dirs("mid")[0]) {
+		t.Fatal("updateHash: changed after generated the same")
+	}
+
+	// a new package, and a package not found
+	writeFiles(t, p.root, map[string]string{"new/a.go": "package a\n"})
+	g.update(p.dirs("new")[0])
+	if !g.updateHash(p.dirs("new")[0]) {
+		t.Fatal("updateHash: a new package")
+	}
+	if g.updateHash(p.dirs("none")[0]) {
+		t.Fatal("updateHash: a package not found")
+	}
+}
+
+func sortedDirs(g *pkgGraph) []string {
+	dirs := make([]string, 0, len(g.pkgs))
+	for dir := range g.pkgs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package watch
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+
+type none = struct{}
+
+// process runs a program built by `gop watch -run`, and restarts it when a
+// new build is ready.
+type process struct {
+	args  []string
+	grace time.Duration
+
+	mutex sync.Mutex
+	cmd   *exec.Cmd // nil if not running
+	done  chan none // closed when cmd exits
+	bin   string    // binary of the running program, removed when it stops
+}
+
+// restart stops the running program if any, and starts bin.
+func (p *process) restart(bin string) error {
+	p.stop(syscall.SIGTERM)
+
+	cmd := exec.Command(bin, p.args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan none)
+	go func() {
+		err := cmd.Wait()
+		close(done)
+		p.mutex.Lock()
+		running := p.cmd == cmd
+		p.mutex.Unlock()
+		if running { // exited by itself
+			log.Println("Exit", bin+":", exitStatus(err))
+		}
+	}()
+	p.mutex.Lock()
+	p.cmd, p.done, p.bin = cmd, done, bin
+	p.mutex.Unlock()
+	return nil
+}
+
+// stop sends sig to the running program, and kills it if it doesn't exit
+// within the grace period.
+func (p *process) stop(sig os.Signal) {
+	p.mutex.Lock()
+	cmd, done, bin := p.cmd, p.done, p.bin
+	p.cmd, p.done, p.bin = nil, nil, ""
+	p.mutex.Unlock()
+	if cmd == nil {
+		return
+	}
+	defer os.Remove(bin)
+
+	if err := cmd.Process.Signal(sig); err != nil { // eg. not supported on Windows
+		cmd.Process.Kill()
+	}
+	select {
+	case <-done:
+		return
+	case <-time.After(p.grace):
+	}
+	log.Println("Kill", bin+": not exited in", p.grace)
+	cmd.Process.Kill()
+	<-done
+}
+
+// signal forwards sig to the running program.
+func (p *process) signal(sig os.Signal) {
+	p.mutex.Lock()
+	cmd := p.cmd
+	p.mutex.Unlock()
+	if cmd != nil {
+		cmd.Process.Signal(sig)
+	}
+}
+
+func exitStatus(err error) string {
+	if err == nil {
+		return "exit status 0"
+	}
+	return err.Error()
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,175 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package watch
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// envTestProg makes the test binary run as the program started by process,
+// with args `mode file`: it appends "start" and the signals it receives to
+// file, and exits on SIGTERM unless mode is "ignore".
+const envTestProg = "XGO_WATCH_TEST_PROG"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(envTestProg) != "" {
+		runTestProg(os.Args[1:])
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func runTestProg(args []string) {
+	mode, file := args[0], args[1]
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGTERM, syscall.SIGHUP)
+	logf := func(s string) {
+		f, _ := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		fmt.Fprintln(f, s)
+		f.Close()
+	}
+	logf("start")
+	for sig := range c {
+		logf(sig.String())
+		if sig == syscall.SIGTERM && mode != "ignore" {
+			os.Exit(0)
+		}
+	}
+}
+
+// testProgram copies the test binary to dir as a program to be started by
+// process, which removes it when the program stops.
+func testProgram(t *testing.T, dir, name string) string {
+	t.Helper()
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := os.Open(self)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	bin := filepath.Join(dir, name)
+	dst, err := os.OpenFile(bin, os.O_CREATE|os.O_WRONLY, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if _, err = io.Copy(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	return bin
+}
+
+// waitLog waits for file to have the lines.
+func waitLog(t *testing.T, file string, lines ...string) {
+	t.Helper()
+	want := strings.Join(lines, "\n") + "\n"
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		data, _ := os.ReadFile(file)
+		if string(data) == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: got %q, want %q", filepath.Base(file), data, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newTestProcess(t *testing.T, mode string, grace time.Duration) (p *process, dir string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals are not supported on windows")
+	}
+	t.Setenv(envTestProg, "1")
+	dir = t.TempDir()
+	p = &process{args: []string{mode, filepath.Join(dir, "log")}, grace: grace}
+	t.Cleanup(func() { p.stop(os.Kill) })
+	return
+}
+
+func TestProcessRestart(t *testing.T) {
+	p, dir := newTestProcess(t, "term", time.Minute)
+	log := filepath.Join(dir, "log")
+	bin1 := testProgram(t, dir, "app1")
+	if err := p.restart(bin1); err != nil {
+		t.Fatal("restart:", err)
+	}
+	waitLog(t, log, "start")
+	done1 := p.done
+
+	// the new build replaces the running program, which is stopped by SIGTERM
+	bin2 := testProgram(t, dir, "app2")
+	if err := p.restart(bin2); err != nil {
+		t.Fatal("restart:", err)
+	}
+	select {
+	case <-done1:
+	default:
+		t.Fatal("the old program is still running")
+	}
+	if _, err := os.Stat(bin1); !os.IsNotExist(err) {
+		t.Fatal("the old binary isn't removed:", err)
+	}
+	waitLog(t, log, "start", "terminated", "start")
+
+	// other signals are forwarded
+	p.signal(syscall.SIGHUP)
+	waitLog(t, log, "start", "terminated", "start", "hangup")
+
+	p.stop(syscall.SIGTERM)
+	waitLog(t, log, "start", "terminated", "start", "hangup", "terminated")
+	if _, err := os.Stat(bin2); !os.IsNotExist(err) {
+		t.Fatal("the binary isn't removed:", err)
+	}
+	p.stop(syscall.SIGTERM) // not running
+}
+
+func TestProcessKill(t *testing.T) {
+	const grace = 200 * time.Millisecond
+	p, dir := newTestProcess(t, "ignore", grace)
+	log := filepath.Join(dir, "log")
+	if err := p.restart(testProgram(t, dir, "app")); err != nil {
+		t.Fatal("restart:", err)
+	}
+	waitLog(t, log, "start")
+	start := time.Now()
+	p.stop(syscall.SIGTERM)
+	if d := time.Since(start); d < grace {
+		t.Fatal("killed before the grace period:", d)
+	}
+	waitLog(t, log, "start", "terminated")
+}
+
+func TestProcessStartFailed(t *testing.T) {
+	p, dir := newTestProcess(t, "term", time.Minute)
+	if err := p.restart(filepath.Join(dir, "none")); err == nil {
+		t.Fatal("restart: no error")
+	}
+	p.signal(syscall.SIGHUP) // not running
+}
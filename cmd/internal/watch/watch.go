@@ -17,8 +17,15 @@
 package watch
 
 import (
+	"fmt"
 	"log"
+	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"syscall"
+	"time"
 
 	"github.com/goplus/xgo/cmd/internal/base"
 	"github.com/goplus/xgo/tool"
@@ -30,7 +37,7 @@ import (
 
 // gop watch
 var Cmd = &base.Command{
-	UsageLine: "gop watch [-v -gentest] [dir]",
+	UsageLine: "gop watch [-v -gentest -poll interval -quiet duration -test -run -grace duration] [dir [args...]]",
 	Short:     "Monitor code changes in a XGo workspace to generate Go files",
 }
 
@@ -39,6 +46,11 @@ var (
 	verbose    = flag.Bool("v", false, "print verbose information.")
 	debug      = flag.Bool("debug", false, "show all debug information.")
 	genTestPkg = flag.Bool("gentest", false, "generate test package.")
+	poll       = flag.Duration("poll", 0, "poll the file system at the interval instead of using inotify.")
+	quiet      = flag.Duration("quiet", watcher.DefaultQuiet, "wait for no changes for the duration before generating.")
+	runProg    = flag.Bool("run", false, "build and run the program in dir with args, and restart it after each successful generation.")
+	grace      = flag.Duration("grace", 5*time.Second, "how long to wait for the program to exit on SIGTERM before killing it.")
+	testPkgs   = flag.Bool("test", false, "run `gop test` for the changed packages and the packages depending on them.")
 )
 
 func init() {
@@ -64,17 +76,201 @@ func runCmd(cmd *base.Command, args []string) {
 	}
 
 	root, _ := filepath.Abs(args[0])
+	if len(args) > 1 && !*runProg {
+		log.Fatalln("too many arguments:", args[1:])
+	}
+	mod, err := tool.LoadMod(root)
+	if err != nil {
+		log.Fatalln("tool.LoadMod:", err)
+	}
+	b := &rebuilder{graph: newPkgGraph(root, mod), genGo: genGoDir}
+	if *testPkgs {
+		b.test = func(dirs []string) { runTests(root, dirs) }
+	}
+	if *runProg {
+		prog := newApp(root, args[1:])
+		prog.rebuild()
+		go prog.forwardSignals()
+		b.restart = prog.rebuild
+	}
+
 	log.Println("Watch", root)
-	w := watcher.New(root)
+	var w watcher.Runner
+	if *poll > 0 {
+		w = watcher.NewPoller(root, *poll)
+	} else {
+		w = watcher.New(root)
+	}
+	w.SetQuiet(*quiet)
 	go w.Run()
 	for {
-		dir := w.Fetch(true)
+		events := w.FetchEvents()
+		if *verbose {
+			for _, e := range events {
+				log.Println("Event:", e)
+			}
+		}
+		dirs := watcher.Dirs(events)
+		for i, dir := range dirs {
+			dirs[i] = filepath.Clean(w.Root() + dir)
+		}
+		b.changed(dirs)
+	}
+}
+
+// rebuilder reacts to changes of packages: it generates Go files, and then
+// reruns the tests and restarts the program if all packages succeed.
+type rebuilder struct {
+	graph   *pkgGraph
+	genGo   func(dir string) error
+	test    func(dirs []string) // nil if tests aren't rerun
+	restart func()              // nil if no program is run
+}
+
+// changed handles changes of the packages in dirs.
+func (p *rebuilder) changed(dirs []string) {
+	for _, dir := range dirs {
+		p.graph.update(dir)
+	}
+	if !p.genDirs(dirs) { // keep the last good program running
+		return
+	}
+	if p.test != nil {
+		p.test(p.graph.dependents(dirs))
+	}
+	if p.restart != nil {
+		p.restart()
+	}
+}
+
+// genDirs generates Go files for the packages in dirs, and then for the XGo
+// packages depending on them in topological order. A dependent is skipped if
+// the exported API of none of its dependencies changes, see
+// pkgGraph.updateHash: so a dependent of a package whose function bodies are
+// edited isn't generated again. It returns false if any package fails.
+func (p *rebuilder) genDirs(dirs []string) (ok bool) {
+	graph := p.graph
+	changed := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		changed[dir] = true
+	}
+	ok = true
+	pkgChanged := make(map[string]bool) // import path => changed
+	for _, dir := range graph.genOrder(dirs) {
+		if !changed[dir] && !graph.importsAny(dir, pkgChanged) {
+			if *verbose {
+				log.Println("Skip", dir+": dependencies unchanged")
+			}
+			continue
+		}
 		log.Println("GenGo", dir)
-		_, _, err := tool.GenGo(dir, nil, *genTestPkg)
-		if err != nil {
+		if err := p.genGo(dir); err != nil {
+			log.Println(err)
+			ok = false
+			continue
+		}
+		if graph.updateHash(dir) {
+			pkgChanged[graph.pkgPath(dir)] = true
+		}
+	}
+	return
+}
+
+func genGoDir(dir string) error {
+	_, _, err := tool.GenGo(dir, nil, *genTestPkg)
+	return err
+}
+
+// runTests runs `gop test` for packages in dirs.
+func runTests(root string, dirs []string) {
+	conf, err := tool.NewDefaultConf(root, 0)
+	if err != nil {
+		log.Println("tool.NewDefaultConf:", err)
+		return
+	}
+	defer conf.UpdateCache()
+
+	test := conf.NewGoCmdConf()
+	test.Run = runIn(root)
+	for _, dir := range dirs {
+		log.Println("Test", dir)
+		if err := tool.TestDir(dir, conf, test); err != nil {
 			log.Println(err)
 		}
 	}
 }
 
+// runIn returns a gocmd.Config.Run which runs the go command in dir.
+func runIn(dir string) func(cmd *exec.Cmd) error {
+	return func(cmd *exec.Cmd) error {
+		cmd.Dir = dir
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		return cmd.Run()
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// app is the program run by `gop watch -run`.
+type app struct {
+	dir  string
+	tmp  string // directory of the binaries
+	n    int    // number of builds
+	proc *process
+}
+
+func newApp(dir string, args []string) *app {
+	tmp, err := os.MkdirTemp("", "gop-watch-")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return &app{dir: dir, tmp: tmp, proc: &process{args: args, grace: *grace}}
+}
+
+// rebuild builds the program, and restarts it if the build succeeds. The
+// running program is left untouched if the build fails.
+func (p *app) rebuild() {
+	conf, err := tool.NewDefaultConf(p.dir, tool.ConfFlagNoTestFiles)
+	if err != nil {
+		log.Println("tool.NewDefaultConf:", err)
+		return
+	}
+	defer conf.UpdateCache()
+
+	p.n++
+	bin := filepath.Join(p.tmp, fmt.Sprintf("app%d", p.n))
+	if runtime.GOOS == "windows" {
+		bin += ".exe"
+	}
+	build := conf.NewGoCmdConf()
+	build.Flags = []string{"-o", bin}
+	build.Run = runIn(p.dir)
+	log.Println("Build", p.dir)
+	if err = tool.BuildDir(p.dir, conf, build); err != nil {
+		log.Println(err)
+		os.Remove(bin)
+		return
+	}
+	log.Println("Run", p.dir)
+	if err = p.proc.restart(bin); err != nil {
+		log.Println(err)
+	}
+}
+
+// forwardSignals forwards signals to the program. It stops the program and
+// exits on SIGINT or SIGTERM.
+func (p *app) forwardSignals() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	for sig := range c {
+		if sig != os.Interrupt && sig != syscall.SIGTERM {
+			p.proc.signal(sig)
+			continue
+		}
+		p.proc.stop(sig)
+		os.RemoveAll(p.tmp)
+		os.Exit(0)
+	}
+}
+
 // -----------------------------------------------------------------------------
@@ -0,0 +1,188 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package watch
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/goplus/xgo/tool"
+)
+
+// testModule is a module where main imports mid, and mid and util import lib.
+var testModule = map[string]string{
+	"go.mod":       "module example.com/app\n\ngo 1.18\n",
+	"main.xgo":     "package main\n\nimport \"example.com/app/mid\"\n\nfunc main() { println(mid.Greet()) }\n",
+	"mid/mid.xgo":  "package mid\n\nimport \"example.com/app/lib\"\n\nfunc Greet() string { return lib.Hello() }\n",
+	"lib/lib.xgo":  "package lib\n\nfunc Hello() string { return \"hi\" }\n",
+	"util/util.go": "package util\n\nimport \"example.com/app/lib\"\n\nvar S = lib.Hello()\n",
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// fakeGenGo "generates" xgo_autogen.go of a package by copying its only XGo
+// file, which is valid Go code. It fails if the file contains "error". Like
+// tool.GenGo, the file isn't rewritten if its content doesn't change.
+func fakeGenGo(dir string) error {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.xgo"))
+	if len(files) == 0 { // a Go package
+		return nil
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		return err
+	}
+	if strings.Contains(string(data), "error") {
+		return errors.New("failed: " + dir)
+	}
+	gen := filepath.Join(dir, "xgo_autogen.go")
+	if old, err := os.ReadFile(gen); err == nil && bytes.Equal(old, data) {
+		return nil
+	}
+	return os.WriteFile(gen, data, 0644)
+}
+
+// testRebuilder is a rebuilder of a testModule which records what it does.
+type testRebuilder struct {
+	rebuilder
+	root     string
+	gens     []string
+	tests    []string
+	restarts int
+}
+
+func newTestRebuilder(t *testing.T) *testRebuilder {
+	root := t.TempDir()
+	writeFiles(t, root, testModule)
+	for _, dir := range []string{"", "mid", "lib"} {
+		if err := fakeGenGo(filepath.Join(root, dir)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mod, err := tool.LoadMod(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testRebuilder{root: root}
+	p.graph = newPkgGraph(root, mod)
+	p.genGo = func(dir string) error {
+		p.gens = append(p.gens, p.rel(dir))
+		return fakeGenGo(dir)
+	}
+	p.test = func(dirs []string) {
+		for _, dir := range dirs {
+			p.tests = append(p.tests, p.rel(dir))
+		}
+	}
+	p.restart = func() { p.restarts++ }
+	return p
+}
+
+func (p *testRebuilder) rel(dir string) string {
+	rel, _ := filepath.Rel(p.root, dir)
+	return filepath.ToSlash(rel)
+}
+
+// change writes files, and then calls changed with the directories of them.
+func (p *testRebuilder) change(t *testing.T, files map[string]string) {
+	writeFiles(t, p.root, files)
+	var dirs []string
+	for name := range files {
+		dirs = append(dirs, filepath.Dir(filepath.Join(p.root, name)))
+	}
+	sort.Strings(dirs)
+	p.gens, p.tests, p.restarts = nil, nil, 0
+	p.changed(dirs)
+}
+
+func TestRebuilder(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		gens     []string
+		tests    []string
+		restarts int
+	}{
+		{"body changed", map[string]string{
+			"lib/lib.xgo": "package lib\n\nfunc Hello() string { return \"hello\" }\n",
+		}, []string{"lib"}, []string{"lib", ".", "mid", "util"}, 1},
+		{"API changed", map[string]string{
+			"lib/lib.xgo": "package lib\n\nfunc Hello() string { return \"hi\" }\n\nfunc Bye() {}\n",
+		}, []string{"lib", "mid"}, []string{"lib", ".", "mid", "util"}, 1},
+		{"dependent changed", map[string]string{
+			"mid/mid.xgo": "package mid\n\nfunc Greet() string { return \"hi\" }\n\nfunc Bye() {}\n",
+		}, []string{"mid", "."}, []string{"mid", "."}, 1},
+		{"both changed", map[string]string{
+			"lib/lib.xgo": "package lib\n\nfunc Hello() string { return \"hello\" }\n",
+			"mid/mid.xgo": "package mid\n\nfunc Greet() string { return \"hi\" }\n\nfunc Bye() {}\n",
+		}, []string{"lib", "mid", "."}, []string{"lib", "mid", ".", "util"}, 1},
+		{"Go package", map[string]string{
+			"util/util.go": "package util\n\nvar S = 1\n",
+		}, []string{"util"}, []string{"util"}, 1},
+		{"failed", map[string]string{
+			"lib/lib.xgo": "package lib\n\nfunc Hello() string { return \"error\" }\n\nfunc Bye2() {}\n",
+		}, []string{"lib"}, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestRebuilder(t)
+			p.change(t, tt.files)
+			if !reflect.DeepEqual(p.gens, tt.gens) {
+				t.Errorf("generated: got %v, want %v", p.gens, tt.gens)
+			}
+			if !reflect.DeepEqual(p.tests, tt.tests) {
+				t.Errorf("tested: got %v, want %v", p.tests, tt.tests)
+			}
+			if p.restarts != tt.restarts {
+				t.Errorf("restarted %d times, want %d", p.restarts, tt.restarts)
+			}
+		})
+	}
+}
+
+func TestRebuilderKeepGoing(t *testing.T) {
+	p := newTestRebuilder(t)
+	p.test = nil
+	p.change(t, map[string]string{"lib/lib.xgo": "package lib\n\nvar error = 1\n"})
+	if p.restarts != 0 {
+		t.Fatal("restarted after a failure")
+	}
+
+	// the program is restarted once the error is fixed, and mid isn't
+	// generated again as the API of lib is the same as before
+	p.change(t, map[string]string{"lib/lib.xgo": testModule["lib/lib.xgo"]})
+	if !reflect.DeepEqual(p.gens, []string{"lib"}) || p.restarts != 1 {
+		t.Fatalf("after fixed: generated %v, restarted %d times", p.gens, p.restarts)
+	}
+}
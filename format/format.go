@@ -101,12 +101,24 @@ func Node(dst io.Writer, fset *token.FileSet, node any) error {
 // space as src), and the result is indented by the same amount as the first
 // line of src containing code. Imports are not sorted for partial source files.
 func Source(src []byte, class bool, filename ...string) ([]byte, error) {
+	return source(src, class, false, filename)
+}
+
+// SourceFragment formats src like Source, but src may also be a fragment of
+// an XGo source file, eg. a range of lines in a function body. A source whose
+// first code line is indented is formatted as a fragment, keeping its indent,
+// even if it parses as a source file.
+func SourceFragment(src []byte, class bool, filename ...string) ([]byte, error) {
+	return source(src, class, true, filename)
+}
+
+func source(src []byte, class, fragmentOk bool, filename []string) ([]byte, error) {
 	var fname string
 	if filename != nil {
 		fname = filename[0]
 	}
 	fset := token.NewFileSet()
-	file, sourceAdj, indentAdj, err := parse(fset, fname, src, class, true)
+	file, sourceAdj, indentAdj, err := parse(fset, fname, src, class, fragmentOk)
 	if err != nil {
 		return nil, err
 	}
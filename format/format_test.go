@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package format
+
+import (
+	"testing"
+)
+
+func TestSourceFragment(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"file", "x:=1\necho  x\n", "x := 1\necho x\n"},
+		{"func decl", "func f( ) {\n}\n", "func f() {\n}\n"},
+		{"indented decl", "\tfunc f( ) {}\n", "\tfunc f() {}\n"},
+		{"partial stmt list", "\tx:=1\n\tif x>0 {\n\t\techo x\n\t}\n", "\tx := 1\n\tif x > 0 {\n\t\techo x\n\t}\n"},
+		{"unbalanced brace", "\t\techo  x\n\t}\n\ty:=2\n", ""},
+		{"expr", "\t\ta+b*  c\n", "\t\ta + b*c\n"},
+		{"call", "\tfoo( 1,2 )\n", "\tfoo(1, 2)\n"},
+		{"command", "\techo  \"hi\"\n", "\techo \"hi\"\n"},
+		{"comment", "\tx:=1 // one\n", "\tx := 1 // one\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ret, err := SourceFragment([]byte(tt.src), false, "a.xgo")
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("SourceFragment(%q): got %q, want an error", tt.src, ret)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SourceFragment(%q): %v", tt.src, err)
+			}
+			if string(ret) != tt.want {
+				t.Fatalf("SourceFragment(%q):\ngot  %q\nwant %q", tt.src, ret, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceInvalid(t *testing.T) {
+	for _, src := range []string{
+		"\tx := \n",
+		"x := \n",
+		"\tif x {\n",
+		"func f( {\n",
+	} {
+		if ret, err := SourceFragment([]byte(src), false, "a.xgo"); err == nil {
+			t.Errorf("SourceFragment(%q): got %q, want an error", src, ret)
+		}
+	}
+}
+
+// Source formats whole files, fixing their indent, eg. for gop fmt.
+func TestSourceWholeFile(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"	echo  1\n\tx:=2", "echo 1\nx := 2\n"},
+		{"  func f( ) {\n}\n", "func f() {\n}\n"},
+		{"x:=1\n", "x := 1\n"},
+	}
+	for _, tt := range tests {
+		ret, err := Source([]byte(tt.src), false, "a.xgo")
+		if err != nil || string(ret) != tt.want {
+			t.Errorf("Source(%q): got %q, %v, want %q", tt.src, ret, err, tt.want)
+		}
+	}
+	if ret, err := Source([]byte("\tif x {\n"), false, "a.xgo"); err == nil {
+		t.Errorf("Source: got %q, want an error", ret)
+	}
+}
+
+func TestIsIndented(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"x := 1\n", false},
+		{"\tx := 1\n", true},
+		{"  x := 1\n", true},
+		{"\n\n\tx := 1\n", true},
+		{"\t\nx := 1\n", false},
+		{"", false},
+		{" \t", false},
+	}
+	for _, tt := range tests {
+		if got := isIndented([]byte(tt.src)); got != tt.want {
+			t.Errorf("isIndented(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
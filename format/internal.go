@@ -31,8 +31,13 @@ import (
 )
 
 // parse parses src, which was read from the named file,
-// as a Go source file, declaration, or statement list.
-func parse(fset *token.FileSet, filename string, src []byte, class, _ /* fragmentOk */ bool) (
+// as a XGo source file, declaration, or statement list.
+//
+// XGo source files may omit the package clause and have statements at the
+// top level, so a source that parses as a file is a complete source file,
+// unless fragmentOk is set and its first code line is indented, eg. a range
+// of lines in a function body.
+func parse(fset *token.FileSet, filename string, src []byte, class, fragmentOk bool) (
 	file *ast.File,
 	sourceAdj func(src []byte, indent int) []byte,
 	indentAdj int,
@@ -42,8 +47,75 @@ func parse(fset *token.FileSet, filename string, src []byte, class, _ /* fragmen
 	if class {
 		mode |= parser.ParseGoPlusClass
 	}
+	// Try as whole source file.
 	file, err = parser.ParseFile(fset, filename, src, mode)
-	return
+	// If there's no error and the source isn't indented, return. If source
+	// fragments are not ok, stop and return on any error.
+	if !fragmentOk || (err == nil && !isIndented(src)) {
+		return
+	}
+	wholeFile, wholeErr := file, err
+
+	// If this is a declaration or statement list, make it a source file
+	// by inserting a package clause.
+	// Insert using a ';', not a newline, so that the line numbers
+	// in psrc match the ones in src.
+	psrc := append([]byte("package p;"), src...)
+	if file, err = parser.ParseFile(fset, filename, psrc, mode); err == nil {
+		sourceAdj = func(src []byte, indent int) []byte {
+			// Remove the package clause.
+			// Gofmt has turned the ';' into a '\n'.
+			src = src[indent+len("package p\n"):]
+			return bytes.TrimSpace(src)
+		}
+		return
+	}
+
+	// If this is a statement list which is only valid in a function body,
+	// make it a source file by inserting a package clause and turning the
+	// list into a function body. This handles expressions too.
+	// Insert using a ';', not a newline, so that the line numbers
+	// in fsrc match the ones in src. Add an extra '\n' before the '}'
+	// to make sure comments are flushed before the '}'.
+	fsrc := append(append([]byte("package p; func _() {"), src...), '\n', '\n', '}')
+	if file, err = parser.ParseFile(fset, filename, fsrc, mode); err == nil {
+		sourceAdj = func(src []byte, indent int) []byte {
+			// Cap adjusted indent to zero.
+			if indent < 0 {
+				indent = 0
+			}
+			// Remove the wrapping.
+			// Gofmt has turned the "; " into a "\n\n".
+			// There will be two non-blank lines with indent, hence 2*indent.
+			src = src[2*indent+len("package p\n\nfunc _() {"):]
+			// Remove only the "}\n" suffix: remaining whitespaces will be trimmed anyway
+			src = src[:len(src)-len("}\n")]
+			return bytes.TrimSpace(src)
+		}
+		// Gofmt has also indented the function body one level.
+		// Adjust that with indentAdj.
+		indentAdj = -1
+		return
+	}
+
+	// Out of options: use the result of the whole source file.
+	return wholeFile, nil, 0, wholeErr
+}
+
+// isIndented reports whether the first code line of src is indented.
+func isIndented(src []byte) bool {
+	indented := false
+	for _, b := range src {
+		switch b {
+		case ' ', '\t':
+			indented = true
+		case '\n', '\r':
+			indented = false
+		default:
+			return indented
+		}
+	}
+	return false
 }
 
 // format formats the given package file originally obtained from src
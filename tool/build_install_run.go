@@ -39,7 +39,7 @@ func InstallDir(dir string, conf *Config, install *gocmd.InstallConfig, flags ..
 	if err != nil {
 		return errors.NewWith(err, `GenGo(dir, conf, false)`, -2, "tool.GenGo", dir, conf, false)
 	}
-	return gocmd.Install(dir, install)
+	return gocmd.Install(dir, withOverlay(install, conf))
 }
 
 // InstallPkgPath installs a XGo package.
@@ -50,7 +50,7 @@ func InstallPkgPath(workDir, pkgPath string, conf *Config, install *gocmd.Instal
 	}
 	old := chdir(localDir)
 	defer os.Chdir(old)
-	return gocmd.Install(cwdParam(recursively), install)
+	return gocmd.Install(cwdParam(recursively), withOverlay(install, conf))
 }
 
 func cwdParam(recursively bool) string {
@@ -66,7 +66,7 @@ func InstallFiles(files []string, conf *Config, install *gocmd.InstallConfig) (e
 	if err != nil {
 		return errors.NewWith(err, `GenGoFiles("", files, conf)`, -2, "tool.GenGoFiles", "", files, conf)
 	}
-	return gocmd.InstallFiles(files, install)
+	return gocmd.InstallFiles(files, withOverlay(install, conf))
 }
 
 func chdir(dir string) string {
@@ -89,7 +89,7 @@ func BuildDir(dir string, conf *Config, build *gocmd.BuildConfig, flags ...GenFl
 	if err != nil {
 		return errors.NewWith(err, `GenGo(dir, conf, false)`, -2, "tool.GenGo", dir, conf, false)
 	}
-	return gocmd.Build(dir, build)
+	return gocmd.Build(dir, withOverlay(build, conf))
 }
 
 // BuildPkgPath builds a XGo package.
@@ -98,9 +98,9 @@ func BuildPkgPath(workDir, pkgPath string, conf *Config, build *gocmd.BuildConfi
 	if err != nil {
 		return errors.NewWith(err, `GenGoPkgPath(workDir, pkgPath, conf, false)`, -2, "tool.GenGoPkgPath", workDir, pkgPath, conf, false)
 	}
-	old, mod := chdirAndMod(localDir)
-	defer restoreDirAndMod(old, mod)
-	return gocmd.Build(cwdParam(recursively), build)
+	old, mod := chdirAndMod(localDir, conf)
+	defer restoreDirAndMod(old, mod, conf)
+	return gocmd.Build(cwdParam(recursively), withOverlay(build, conf))
 }
 
 // BuildFiles builds specified XGo files.
@@ -109,10 +109,13 @@ func BuildFiles(files []string, conf *Config, build *gocmd.BuildConfig) (err err
 	if err != nil {
 		return errors.NewWith(err, `GenGoFiles("", files, conf)`, -2, "tool.GenGoFiles", "", files, conf)
 	}
-	return gocmd.BuildFiles(files, build)
+	return gocmd.BuildFiles(files, withOverlay(build, conf))
 }
 
-func chdirAndMod(dir string) (old string, mod os.FileMode) {
+func chdirAndMod(dir string, conf *Config) (old string, mod os.FileMode) {
+	if isOverlay(conf) { // dir isn't written
+		return chdir(dir), 0
+	}
 	mod = 0755
 	if info, err := os.Stat(dir); err == nil {
 		mod = info.Mode().Perm()
@@ -122,11 +125,31 @@ func chdirAndMod(dir string) (old string, mod os.FileMode) {
 	return
 }
 
-func restoreDirAndMod(old string, mod os.FileMode) {
-	os.Chmod(".", mod)
+func restoreDirAndMod(old string, mod os.FileMode, conf *Config) {
+	if !isOverlay(conf) {
+		os.Chmod(".", mod)
+	}
 	os.Chdir(old)
 }
 
+func isOverlay(conf *Config) bool {
+	return conf != nil && conf.Overlay != nil
+}
+
+// withOverlay returns a copy of gconf passing the files generated into
+// conf.Overlay to the go command, or gconf itself if not in overlay mode.
+func withOverlay(gconf *gocmd.Config, conf *Config) *gocmd.Config {
+	if !isOverlay(conf) {
+		return gconf
+	}
+	ret := new(gocmd.Config)
+	if gconf != nil {
+		*ret = *gconf
+	}
+	ret.Overlay = conf.Overlay.Files()
+	return ret
+}
+
 // -----------------------------------------------------------------------------
 
 // If no go.mod and used XGo, use GOPROOT as buildDir.
@@ -143,7 +166,7 @@ func RunDir(dir string, args []string, conf *Config, run *gocmd.RunConfig, flags
 	if err != nil {
 		return errors.NewWith(err, `GenGo(dir, conf, false)`, -2, "tool.GenGo", dir, conf, false)
 	}
-	return gocmd.RunDir(getBuildDir(conf), dir, args, run)
+	return gocmd.RunDir(getBuildDir(conf), dir, args, withOverlay(run, conf))
 }
 
 // RunPkgPath runs an application from a XGo package.
@@ -160,7 +183,7 @@ func RunPkgPath(pkgPath string, args []string, chDir bool, conf *Config, run *go
 		defer os.Chdir(old)
 		localDir = "."
 	}
-	return gocmd.RunDir("", localDir, args, run)
+	return gocmd.RunDir("", localDir, args, withOverlay(run, conf))
 }
 
 // RunFiles runs an application from specified XGo files.
@@ -169,7 +192,7 @@ func RunFiles(autogen string, files []string, args []string, conf *Config, run *
 	if err != nil {
 		return errors.NewWith(err, `GenGoFiles(autogen, files, conf)`, -2, "tool.GenGoFiles", autogen, files, conf)
 	}
-	return gocmd.RunFiles(getBuildDir(conf), files, args, run)
+	return gocmd.RunFiles(getBuildDir(conf), files, args, withOverlay(run, conf))
 }
 
 // -----------------------------------------------------------------------------
@@ -180,7 +203,7 @@ func TestDir(dir string, conf *Config, test *gocmd.TestConfig, flags ...GenFlags
 	if err != nil {
 		return errors.NewWith(err, `GenGo(dir, conf, true)`, -2, "tool.GenGo", dir, conf, true)
 	}
-	return gocmd.Test(dir, test)
+	return gocmd.Test(dir, withOverlay(test, conf))
 }
 
 // TestPkgPath tests a XGo package.
@@ -189,9 +212,9 @@ func TestPkgPath(workDir, pkgPath string, conf *Config, test *gocmd.TestConfig,
 	if err != nil {
 		return errors.NewWith(err, `GenGoPkgPath(workDir, pkgPath, conf, false)`, -2, "tool.GenGoPkgPath", workDir, pkgPath, conf, false)
 	}
-	old, mod := chdirAndMod(localDir)
-	defer restoreDirAndMod(old, mod)
-	return gocmd.Test(cwdParam(recursively), test)
+	old, mod := chdirAndMod(localDir, conf)
+	defer restoreDirAndMod(old, mod, conf)
+	return gocmd.Test(cwdParam(recursively), withOverlay(test, conf))
 }
 
 // TestFiles tests specified XGo files.
@@ -200,7 +223,7 @@ func TestFiles(files []string, conf *Config, test *gocmd.TestConfig) (err error)
 	if err != nil {
 		return errors.NewWith(err, `GenGoFiles("", files, conf)`, -2, "tool.GenGoFiles", "", files, conf)
 	}
-	return gocmd.TestFiles(files, test)
+	return gocmd.TestFiles(files, withOverlay(test, conf))
 }
 
 // -----------------------------------------------------------------------------
@@ -0,0 +1,321 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/goplus/gogen"
+	"github.com/goplus/xgo/scanner"
+	"github.com/goplus/xgo/token"
+	"github.com/qiniu/x/errors"
+)
+
+// -----------------------------------------------------------------------------
+
+// SeverityError is the severity of diagnostics of errors.
+const SeverityError = "error"
+
+// Codes of diagnostics.
+const (
+	CodeSyntax   = "syntax"   // syntax errors reported by the parser
+	CodeCompile  = "compile"  // gogen.CodeError, eg. undefined names
+	CodeMismatch = "mismatch" // gogen.MatchError, eg. wrong argument types
+	CodeImport   = "import"   // gogen.ImportError
+	CodeOther    = "other"    // other errors, which have no position
+)
+
+var codeDescs = map[string]string{
+	CodeSyntax:   "Syntax error",
+	CodeCompile:  "Compile error",
+	CodeMismatch: "Type mismatch",
+	CodeImport:   "Import error",
+	CodeOther:    "Error",
+}
+
+// Diagnostic represents an error of XGo code in a machine-readable form, see
+// Config.Diagnostics.
+type Diagnostic struct {
+	Package  string `json:"package,omitempty"` // import path, or directory if out of the module
+	File     string `json:"file,omitempty"`
+	Range    Span   `json:"range"` // End is Start if the end is unknown
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
+// Diagnostics returns the errors in err returned by GenGo, LoadDir, BuildDir
+// and so on as diagnostics. Errors of the go command are skipped, as it has
+// reported them itself.
+func (conf *Config) Diagnostics(err error) []Diagnostic {
+	var ret []Diagnostic
+	conf.diagnose(&ret, err, "")
+	return ret
+}
+
+// diagnose appends the diagnostics of err to ret. dir is the directory of the
+// package err occurs in, taken from the innermost error frame naming it.
+func (conf *Config) diagnose(ret *[]Diagnostic, err error, dir string) {
+	add := func(code string, start, end token.Position, msg string) {
+		*ret = append(*ret, conf.newDiag(dir, code, start, end, msg))
+	}
+	switch e := err.(type) {
+	case nil, *exec.ExitError:
+	case errors.List:
+		for _, v := range e {
+			conf.diagnose(ret, v, dir)
+		}
+	case *errors.Frame:
+		if d := dirOfArgs(e.Args); d != "" {
+			dir = d
+		}
+		conf.diagnose(ret, e.Err, dir)
+	case scanner.ErrorList:
+		for _, v := range e {
+			add(CodeSyntax, v.Pos, v.Pos, v.Msg)
+		}
+	case *scanner.Error:
+		add(CodeSyntax, e.Pos, e.Pos, e.Msg)
+	case *gogen.CodeError:
+		pos := conf.position(ErrorPos(e))
+		add(CodeCompile, pos, pos, e.Msg)
+	case *gogen.MatchError:
+		start := conf.position(ErrorPos(e))
+		end := start
+		if e.Src != nil {
+			end = conf.position(e.Src.End())
+		}
+		add(CodeMismatch, start, end, e.Message(""))
+	case *gogen.ImportError:
+		pos := conf.position(ErrorPos(e))
+		add(CodeImport, pos, pos, strings.TrimSpace(fmt.Sprint(e.Err)))
+	default:
+		add(CodeOther, token.Position{}, token.Position{}, err.Error())
+	}
+}
+
+func (conf *Config) position(pos token.Pos) token.Position {
+	if conf.Fset == nil {
+		return token.Position{}
+	}
+	return conf.Fset.Position(pos)
+}
+
+func (conf *Config) newDiag(dir, code string, start, end token.Position, msg string) Diagnostic {
+	if start.Filename != "" {
+		dir = filepath.Dir(start.Filename)
+	}
+	if end.Filename != start.Filename || end.Line < start.Line {
+		end = start
+	}
+	pkg := dir
+	if dir != "" && conf.Mod != nil {
+		if pkgPath := pkgPathOf(conf.Mod, dir); pkgPath != "" {
+			pkg = pkgPath
+		}
+	}
+	return Diagnostic{
+		Package: pkg,
+		File:    start.Filename,
+		Range: Span{
+			Start: Pos{Line: start.Line, Col: start.Column},
+			End:   Pos{Line: end.Line, Col: end.Column},
+		},
+		Severity: SeverityError,
+		Code:     code,
+		Message:  msg,
+	}
+}
+
+// dirOfArgs returns the directory of the first existing path in the arguments
+// of an error frame, eg. dir of `tool.LoadDir(dir, conf, genTestPkg)`.
+func dirOfArgs(args []interface{}) string {
+	for _, arg := range args {
+		var path string
+		switch v := arg.(type) {
+		case string:
+			path = v
+		case []string:
+			if len(v) > 0 {
+				path = v[0]
+			}
+		}
+		if path == "" {
+			continue
+		}
+		if fi, err := os.Stat(path); err == nil {
+			if !fi.IsDir() {
+				path = filepath.Dir(path)
+			}
+			return path
+		}
+	}
+	return ""
+}
+
+// WriteDiagnostics writes diags to w as a stream of JSON objects, one per
+// line.
+func WriteDiagnostics(w io.Writer, diags []Diagnostic) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, diag := range diags {
+		if err := enc.Encode(diag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifSrcRoot = "%SRCROOT%"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// WriteSARIF writes diags to w as a SARIF 2.1.0 log, eg. for GitHub code
+// scanning. version is the version of XGo. Files in the current directory are
+// written relative to %SRCROOT%, which is the repository root for GitHub.
+func WriteSARIF(w io.Writer, diags []Diagnostic, version string) error {
+	cwd, _ := os.Getwd()
+	driver := sarifDriver{
+		Name:           "xgo",
+		Version:        version,
+		InformationURI: "https://xgo.dev",
+		Rules:          []sarifRule{},
+	}
+	rules := make(map[string]bool)
+	results := make([]sarifResult, 0, len(diags))
+	for _, diag := range diags {
+		if !rules[diag.Code] {
+			rules[diag.Code] = true
+			driver.Rules = append(driver.Rules, sarifRule{
+				ID: diag.Code, ShortDescription: sarifMessage{Text: codeDescs[diag.Code]},
+			})
+		}
+		result := sarifResult{
+			RuleID:  diag.Code,
+			Level:   diag.Severity,
+			Message: sarifMessage{Text: diag.Message},
+		}
+		if diag.Package != "" {
+			result.Properties = map[string]string{"package": diag.Package}
+		}
+		if diag.File != "" {
+			loc := sarifPhysicalLocation{ArtifactLocation: sarifArtifactOf(diag.File, cwd)}
+			if start := diag.Range.Start; start.Line > 0 {
+				loc.Region = &sarifRegion{StartLine: start.Line, StartColumn: start.Col}
+				if end := diag.Range.End; end != start {
+					loc.Region.EndLine, loc.Region.EndColumn = end.Line, end.Col
+				}
+			}
+			result.Locations = []sarifLocation{{PhysicalLocation: loc}}
+		}
+		results = append(results, result)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	})
+}
+
+func sarifArtifactOf(file, cwd string) sarifArtifactLocation {
+	if !filepath.IsAbs(file) {
+		return sarifArtifactLocation{URI: filepath.ToSlash(file), URIBaseID: sarifSrcRoot}
+	}
+	if rel, err := filepath.Rel(cwd, file); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return sarifArtifactLocation{URI: filepath.ToSlash(rel), URIBaseID: sarifSrcRoot}
+	}
+	uri := filepath.ToSlash(file)
+	if !strings.HasPrefix(uri, "/") { // eg. C:/foo on Windows
+		uri = "/" + uri
+	}
+	return sarifArtifactLocation{URI: "file://" + uri}
+}
+
+// -----------------------------------------------------------------------------
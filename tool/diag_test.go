@@ -0,0 +1,270 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tool
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qiniu/x/errors"
+)
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want Diagnostic
+	}{
+		{"syntax", "package a\n\nvar A = )\n", Diagnostic{
+			Range: Span{Pos{3, 9}, Pos{3, 9}}, Code: CodeSyntax,
+			Message: "expected operand, found ')'",
+		}},
+		{"compile", "package a\n\nfunc A() int {\n\treturn undefinedX\n}\n", Diagnostic{
+			Range: Span{Pos{4, 9}, Pos{4, 9}}, Code: CodeCompile,
+			Message: "undefined: undefinedX",
+		}},
+		{"mismatch", "package a\n\nfunc A() string {\n\treturn 1 + 2\n}\n", Diagnostic{
+			Range: Span{Pos{4, 9}, Pos{4, 14}}, Code: CodeMismatch,
+			Message: "cannot use 1 + 2 (type untyped int) as type string in return argument",
+		}},
+		{"import", "package a\n\nimport _ \"example.com/foo/none\"\n", Diagnostic{
+			Range: Span{Pos{3, 8}, Pos{3, 8}}, Code: CodeImport,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTestFiles(t, root, map[string]string{"go.mod": testGoMod, "a/a.xgo": tt.src})
+			conf := newTestConf(t, root, 0)
+			_, _, err := GenGo(filepath.Join(root, "a"), conf, false)
+			if err == nil {
+				t.Fatal("GenGo: no error")
+			}
+			diags := conf.Diagnostics(err)
+			if len(diags) != 1 {
+				t.Fatalf("Diagnostics(%v):\n%+v", err, diags)
+			}
+			got, want := diags[0], tt.want
+			want.Package, want.File, want.Severity = "example.com/foo/a", filepath.Join(root, "a", "a.xgo"), SeverityError
+			if want.Message == "" { // depends on the system
+				if want.Message = got.Message; strings.TrimSpace(got.Message) != got.Message || got.Message == "" {
+					t.Fatalf("Diagnostics: message %q", got.Message)
+				}
+			}
+			if got != want {
+				t.Fatalf("Diagnostics:\n got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+
+	// errors without position, in a package by the error frame, and of the
+	// go command
+	root := t.TempDir()
+	writeTestFiles(t, root, map[string]string{"go.mod": testGoMod, "a/a.xgo": "package a\n"})
+	conf := newTestConf(t, root, 0)
+	dir := filepath.Join(root, "a")
+	err := errors.List{
+		errors.New("no Go files"),
+		errors.NewWith(errors.New("no main"), `LoadDir(dir)`, -2, "tool.LoadDir", dir),
+		&exec.ExitError{},
+	}
+	got := conf.Diagnostics(err)
+	want := []Diagnostic{
+		{Severity: SeverityError, Code: CodeOther, Message: "no Go files"},
+		{Package: "example.com/foo/a", Severity: SeverityError, Code: CodeOther, Message: "no main"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Diagnostics: %+v", got)
+	}
+}
+
+func TestWriteDiagnostics(t *testing.T) {
+	diags := []Diagnostic{
+		{Package: "example.com/foo/a", File: "/m/a/a.xgo", Range: Span{Pos{4, 9}, Pos{4, 14}},
+			Severity: SeverityError, Code: CodeMismatch, Message: "cannot use 1 + 2 (type untyped int) as type string"},
+		{Severity: SeverityError, Code: CodeOther, Message: "<no Go files>"},
+	}
+	var b bytes.Buffer
+	if err := WriteDiagnostics(&b, diags); err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"package":"example.com/foo/a","file":"/m/a/a.xgo","range":{"start":{"line":4,"col":9},"end":{"line":4,"col":14}},"severity":"error","code":"mismatch","message":"cannot use 1 + 2 (type untyped int) as type string"}
+{"range":{"start":{"line":0,"col":0},"end":{"line":0,"col":0}},"severity":"error","code":"other","message":"<no Go files>"}
+`
+	if got := b.String(); got != want {
+		t.Fatalf("WriteDiagnostics:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	root := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	cwd, _ := os.Getwd() // root with symbolic links resolved, eg. on macOS
+	outside := filepath.Join(filepath.Dir(cwd), "b.xgo")
+	diags := []Diagnostic{
+		{Package: "example.com/foo/a", File: filepath.Join(cwd, "a", "a.xgo"), Range: Span{Pos{4, 9}, Pos{4, 14}},
+			Severity: SeverityError, Code: CodeMismatch, Message: "cannot use 1 + 2"},
+		{Package: "example.com/foo/a", File: filepath.Join("a", "a.xgo"), Range: Span{Pos{3, 1}, Pos{3, 1}},
+			Severity: SeverityError, Code: CodeCompile, Message: "undefined: x"},
+		{File: outside, Range: Span{Pos{1, 1}, Pos{1, 1}}, Severity: SeverityError, Code: CodeCompile, Message: "undefined: y"},
+		{Severity: SeverityError, Code: CodeOther, Message: "no Go files"},
+	}
+	var b bytes.Buffer
+	if err := WriteSARIF(&b, diags, "v1.5.0"); err != nil {
+		t.Fatal(err)
+	}
+	const want = `{
+  "version": "2.1.0",
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "runs": [
+    {
+      "tool": {
+        "driver": {
+          "name": "xgo",
+          "version": "v1.5.0",
+          "informationUri": "https://xgo.dev",
+          "rules": [
+            {
+              "id": "mismatch",
+              "shortDescription": {
+                "text": "Type mismatch"
+              }
+            },
+            {
+              "id": "compile",
+              "shortDescription": {
+                "text": "Compile error"
+              }
+            },
+            {
+              "id": "other",
+              "shortDescription": {
+                "text": "Error"
+              }
+            }
+          ]
+        }
+      },
+      "results": [
+        {
+          "ruleId": "mismatch",
+          "level": "error",
+          "message": {
+            "text": "cannot use 1 + 2"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "a/a.xgo",
+                  "uriBaseId": "%SRCROOT%"
+                },
+                "region": {
+                  "startLine": 4,
+                  "startColumn": 9,
+                  "endLine": 4,
+                  "endColumn": 14
+                }
+              }
+            }
+          ],
+          "properties": {
+            "package": "example.com/foo/a"
+          }
+        },
+        {
+          "ruleId": "compile",
+          "level": "error",
+          "message": {
+            "text": "undefined: x"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "a/a.xgo",
+                  "uriBaseId": "%SRCROOT%"
+                },
+                "region": {
+                  "startLine": 3,
+                  "startColumn": 1
+                }
+              }
+            }
+          ],
+          "properties": {
+            "package": "example.com/foo/a"
+          }
+        },
+        {
+          "ruleId": "compile",
+          "level": "error",
+          "message": {
+            "text": "undefined: y"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "file://OUTSIDE"
+                },
+                "region": {
+                  "startLine": 1,
+                  "startColumn": 1
+                }
+              }
+            }
+          ]
+        },
+        {
+          "ruleId": "other",
+          "level": "error",
+          "message": {
+            "text": "no Go files"
+          }
+        }
+      ]
+    }
+  ]
+}
+`
+	uri := filepath.ToSlash(outside)
+	if uri[0] != '/' {
+		uri = "/" + uri
+	}
+	if got := b.String(); got != string(bytes.Replace([]byte(want), []byte("OUTSIDE"), []byte(uri), 1)) {
+		t.Fatalf("WriteSARIF:\n%s", got)
+	}
+
+	// a log without results, so that code scanning closes fixed errors
+	b.Reset()
+	if err := WriteSARIF(&b, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b.Bytes(), []byte(`"rules": []`)) || !bytes.Contains(b.Bytes(), []byte(`"results": []`)) {
+		t.Fatalf("WriteSARIF without results:\n%s", b.String())
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"strings"
 	"syscall"
 
+	"github.com/goplus/gogen"
 	"github.com/goplus/mod/modcache"
 	"github.com/goplus/mod/modfetch"
 	"github.com/goplus/mod/xgomod"
@@ -67,6 +68,9 @@ func genGoDir(dir string, conf *Config, genTestPkg, recursively bool, flags GenF
 		conf = new(Config)
 	}
 	if recursively {
+		if conf.Parallel > 1 && flags&GenFlagSingleFile == 0 {
+			return genGoParallel(dir, conf, genTestPkg, flags)
+		}
 		var (
 			list errors.List
 			fn   func(path string, d fs.DirEntry, err error) error
@@ -156,19 +160,40 @@ func genGoSingleFile(file string, extn int, conf *Config, flags GenFlags) (err e
 	if err != nil {
 		return errors.NewWith(err, `LoadFiles(files, conf)`, -2, "tool.LoadFiles", file)
 	}
+	defer forgetNodeSpans(out)
 	if flags&GenFlagCheckOnly != 0 {
 		return nil
 	}
-	if err := out.WriteFile(autogen); err != nil {
-		return errors.NewWith(err, `out.WriteFile(autogen)`, -2, "(*gogen.Package).WriteFile", out, autogen)
+	if err := writeFile(out, autogen); err != nil {
+		return errors.NewWith(err, `writeFile(out, autogen)`, -2, "tool.writeFile", out, autogen)
 	}
 	return nil
 }
 
 func genGoIn(dir string, conf *Config, genTestPkg bool, flags GenFlags, gen ...*bool) (err error) {
+	if flags&GenFlagCheckOnly == 0 && upToDate(dir, conf, genTestPkg) {
+		if ov := conf.Overlay; ov != nil {
+			mode := genMode(conf, genTestPkg)
+			if !conf.isDep {
+				ov.pin(dir, mode)
+			}
+			if err = ov.restore(dir, mode); err != nil {
+				return
+			}
+			return genOverlayDeps(dir, conf, genTestPkg)
+		}
+		return nil
+	}
 	out, test, err := LoadDir(dir, conf, genTestPkg, (flags&GenFlagPrompt) != 0)
+	defer forgetNodeSpans(out, test)
 	if err != nil {
 		if NotFound(err) { // no XGo source files
+			if flags&GenFlagCheckOnly == 0 {
+				saveManifest(dir, conf, genTestPkg, nil)
+				if conf.Overlay != nil {
+					return genOverlayDeps(dir, conf, genTestPkg)
+				}
+			}
 			return nil
 		}
 		return errors.NewWith(err, `LoadDir(dir, conf, genTestPkg)`, -5, "tool.LoadDir", dir, conf, genTestPkg)
@@ -176,34 +201,86 @@ func genGoIn(dir string, conf *Config, genTestPkg bool, flags GenFlags, gen ...*
 	if flags&GenFlagCheckOnly != 0 {
 		return nil
 	}
-	os.MkdirAll(dir, 0755)
+	write, remove := genWriter(dir, conf, genTestPkg)
 	file := filepath.Join(dir, autoGenFile)
-	err = out.WriteFile(file)
+	err = write(out, file)
 	if err != nil {
-		return errors.NewWith(err, `out.WriteFile(file)`, -2, "(*gogen.Package).WriteFile", out, file)
+		return errors.NewWith(err, `writeFile(out, file)`, -2, "tool.writeFile", out, file)
 	}
 	if gen != nil { // say `xgo_autogen.go generated`
 		*gen[0] = true
 	}
 
 	testFile := filepath.Join(dir, autoGenTestFile)
-	err = out.WriteFile(testFile, testingGoFile)
-	if err != nil && err != syscall.ENOENT {
-		return errors.NewWith(err, `out.WriteFile(testFile, testingGoFile)`, -2, "(*gogen.Package).WriteFile", out, testFile, testingGoFile)
+	err = write(out, testFile, testingGoFile)
+	if err == syscall.ENOENT {
+		remove(testFile)
+	} else if err != nil {
+		return errors.NewWith(err, `writeFile(out, testFile, testingGoFile)`, -2, "tool.writeFile", out, testFile, testingGoFile)
 	}
 
+	testFile = filepath.Join(dir, autoGen2TestFile)
 	if test != nil {
-		testFile = filepath.Join(dir, autoGen2TestFile)
-		err = test.WriteFile(testFile, testingGoFile)
+		err = write(test, testFile, testingGoFile)
 		if err != nil {
-			return errors.NewWith(err, `test.WriteFile(testFile, testingGoFile)`, -2, "(*gogen.Package).WriteFile", test, testFile, testingGoFile)
+			return errors.NewWith(err, `writeFile(test, testFile, testingGoFile)`, -2, "tool.writeFile", test, testFile, testingGoFile)
 		}
 	} else {
+		remove(testFile)
 		err = nil
 	}
+	saveManifest(dir, conf, genTestPkg, out)
 	return
 }
 
+// genOverlayDeps generates or restores the overlay of the packages imported by
+// the package in dir from the same module, as the go command needs them too.
+// They are imported by cl only if the package in dir has XGo files and isn't
+// up to date.
+func genOverlayDeps(dir string, conf *Config, genTestPkg bool) error {
+	imp := conf.Importer
+	if imp == nil || !imp.mod.HasModfile() {
+		return nil
+	}
+	filter := conf.Filter
+	if !genTestPkg && filter == nil {
+		filter = FilterNoTestFiles
+	}
+	for _, pkgPath := range pkgImports(imp.mod, dir, filter) {
+		if pkgPath == imp.mod.Path() {
+			continue
+		}
+		if pkg, e := lookupPkg(imp.mod, pkgPath); e == nil {
+			if pkg.Type == xgomod.PkgtModule || pkg.Type == xgomod.PkgtLocal {
+				if err := imp.genGoExtern(pkg.Dir, false); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// genWriter returns how genGoIn writes and removes the Go files generated for
+// dir: into conf.Overlay if any, or else into dir. Files not generated are
+// removed only from an overlay, as files in dir may be written by users. The
+// overlay of dir is pinned unless it's generated as a dependency.
+func genWriter(dir string, conf *Config, genTestPkg bool) (
+	write func(pkg *gogen.Package, file string, fname ...string) error, remove func(file string)) {
+	if ov := conf.Overlay; ov != nil {
+		mode := genMode(conf, genTestPkg)
+		if !conf.isDep {
+			ov.pin(dir, mode)
+		}
+		write = func(pkg *gogen.Package, file string, fname ...string) error {
+			return ov.writeFile(mode, pkg, file, fname...)
+		}
+		return write, func(file string) { ov.remove(mode, file) }
+	}
+	os.MkdirAll(dir, 0755)
+	return writeFile, func(string) {}
+}
+
 // -----------------------------------------------------------------------------
 
 const (
@@ -218,8 +295,10 @@ func GenGoPkgPath(workDir, pkgPath string, conf *Config, allowExtern bool) (loca
 
 func remotePkgPath(pkgPath string, conf *Config, recursively bool, flags GenFlags) (localDir string, _recursively bool, err error) {
 	remotePkgPathDo(pkgPath, func(dir, _ string) {
-		os.Chmod(dir, modWritable)
-		defer os.Chmod(dir, modReadonly)
+		if !isOverlay(conf) {
+			os.Chmod(dir, modWritable)
+			defer os.Chmod(dir, modReadonly)
+		}
 		localDir = dir
 		_recursively = recursively
 		err = genGoDir(dir, conf, false, recursively, flags)
@@ -250,7 +329,7 @@ func GenGoPkgPathEx(workDir, pkgPath string, conf *Config, allowExtern bool, fla
 		return
 	}
 	localDir = pkg.Dir
-	if pkg.Type == xgomod.PkgtExtern {
+	if pkg.Type == xgomod.PkgtExtern && !isOverlay(conf) {
 		os.Chmod(localDir, modWritable)
 		defer os.Chmod(localDir, modReadonly)
 	}
@@ -291,9 +370,11 @@ func GenGoFiles(autogen string, files []string, conf *Config) (outFiles []string
 		err = errors.NewWith(err, `LoadFiles(files, conf)`, -2, "tool.LoadFiles", files, conf)
 		return
 	}
-	err = out.WriteFile(autogen)
+	defer forgetNodeSpans(out)
+	write, _ := genWriter(".", conf, false)
+	err = write(out, autogen)
 	if err != nil {
-		err = errors.NewWith(err, `out.WriteFile(autogen)`, -2, "(*gogen.Package).WriteFile", out, autogen)
+		err = errors.NewWith(err, `writeFile(out, autogen)`, -2, "tool.writeFile", out, autogen)
 	}
 	outFiles = []string{autogen}
 	return
@@ -20,6 +20,7 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
+	"go/constant"
 	"go/token"
 	"go/types"
 	"io"
@@ -30,7 +31,10 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 
+	"github.com/goplus/gogen"
 	"github.com/goplus/gogen/packages"
 	"github.com/goplus/gogen/packages/cache"
 	"github.com/goplus/mod/env"
@@ -41,16 +45,39 @@ import (
 
 // -----------------------------------------------------------------------------
 
-// Importer represents a XGo importer.
+// Importer represents a XGo importer. It is safe for concurrent use.
 type Importer struct {
 	impFrom *packages.Importer
 	mod     *xgomod.Module
 	xgo     *env.XGo
 	fset    *token.FileSet
 
+	impMutex sync.Mutex // protects impFrom, which isn't safe for concurrent use
+	genDirs  sync.Map   // dir => *sync.Mutex, see lockDir
+	inits    sync.Map   // *types.Package => *sync.Once, see initXGoPkg
+	parallel int32      // set by genGoParallel, see initXGoPkg
+	overlay  *Overlay   // see Config.Overlay
+	srcMap   bool       // see Config.SourceMap
+
 	Flags GenFlags // can change this for loading XGo modules
 }
 
+// modMutex protects the xgomod.Module objects, which aren't safe for
+// concurrent use.
+var modMutex sync.Mutex
+
+func lookupPkg(mod *xgomod.Module, pkgPath string) (*xgomod.Package, error) {
+	modMutex.Lock()
+	defer modMutex.Unlock()
+	return mod.Lookup(pkgPath)
+}
+
+func (p *Importer) importFrom(pkgPath, dir string) (*types.Package, error) {
+	p.impMutex.Lock()
+	defer p.impMutex.Unlock()
+	return p.impFrom.ImportFrom(pkgPath, dir, 0)
+}
+
 // NewImporter creates a XGo Importer.
 func NewImporter(mod *xgomod.Module, xgo *env.XGo, fset *token.FileSet) *Importer {
 	const (
@@ -73,11 +100,21 @@ func NewImporter(mod *xgomod.Module, xgo *env.XGo, fset *token.FileSet) *Importe
 
 func (p *Importer) SetTags(tags string) {
 	p.impFrom.SetTags(tags)
-	if c, ok := p.impFrom.Cache().(*cache.Impl); ok {
+	switch c := p.impFrom.Cache().(type) {
+	case *cache.Impl:
 		c.SetTags(tags)
+	case *overlayCache:
+		c.tags = tags
 	}
 }
 
+// setOverlay makes p import the packages of the module with the Go files
+// generated into ov, see overlayCache.
+func (p *Importer) setOverlay(ov *Overlay) {
+	p.overlay = ov
+	p.impFrom.SetCache(&overlayCache{ov: ov, tags: p.impFrom.Tags()})
+}
+
 // CacheFile returns file path of the cache.
 func (p *Importer) CacheFile() string {
 	cacheDir, _ := os.UserCacheDir()
@@ -98,15 +135,16 @@ func (p *Importer) CacheFile() string {
 	return cacheDir + hash + fname
 }
 
-// Cache returns the cache object.
+// Cache returns the cache object, or nil in overlay mode.
 func (p *Importer) Cache() *cache.Impl {
-	return p.impFrom.Cache().(*cache.Impl)
+	c, _ := p.impFrom.Cache().(*cache.Impl)
+	return c
 }
 
 // PkgHash calculates hash value for a package.
 // It is required by cache.New func.
 func (p *Importer) PkgHash(pkgPath string, self bool) string {
-	if pkg, e := p.mod.Lookup(pkgPath); e == nil {
+	if pkg, e := lookupPkg(p.mod, pkgPath); e == nil {
 		switch pkg.Type {
 		case xgomod.PkgtStandard:
 			return cache.HashSkip
@@ -128,11 +166,60 @@ func (p *Importer) PkgHash(pkgPath string, self bool) string {
 
 const (
 	xgoMod = "github.com/goplus/xgo"
+	gopMod = "github.com/goplus/gop" // the module required by xgomod.Module.SaveWithXGoMod
 	xMod   = "github.com/qiniu/x"
 )
 
 // Import imports a Go/XGo package.
 func (p *Importer) Import(pkgPath string) (pkg *types.Package, err error) {
+	if pkg, err = p.doImport(pkgPath); err == nil && atomic.LoadInt32(&p.parallel) != 0 {
+		p.initXGoPkg(pkg)
+	}
+	return
+}
+
+// setParallel makes p initialize the XGo packages it imports, as p is shared
+// by packages compiled in parallel, see initXGoPkg.
+func (p *Importer) setParallel() {
+	atomic.StoreInt32(&p.parallel, 1)
+}
+
+const (
+	xgoPackage = "GopPackage"   // see gogen.Package.initGopPkg
+	xgoPkgInit = "__gop_inited" // ditto
+)
+
+// initXGoPkg initializes an imported XGo package and the XGo packages it
+// depends on as gogen does on importing them, see gogen.InitThisGopPkg. The
+// imported packages are shared by the packages compiled in parallel, so they
+// are initialized here once before being returned by Import, instead of by
+// gogen, which would modify their scopes concurrently. It is only used after
+// setParallel, otherwise gogen initializes them lazily as usual.
+func (p *Importer) initXGoPkg(pkg *types.Package) {
+	once, _ := p.inits.LoadOrStore(pkg, new(sync.Once))
+	once.(*sync.Once).Do(func() {
+		scope := pkg.Scope()
+		obj := scope.Lookup(xgoPackage)
+		if obj == nil || scope.Lookup(xgoPkgInit) != nil { // not a XGo package, or initialized
+			return
+		}
+		scope.Insert(types.NewConst(
+			token.NoPos, pkg, xgoPkgInit, types.Typ[types.UntypedBool], constant.MakeBool(true),
+		))
+		deps, ok := obj.(*types.Const)
+		if !ok {
+			return
+		}
+		gogen.InitThisGopPkg(pkg)
+		if v := deps.Val(); v.Kind() == constant.String {
+			for _, dep := range strings.Split(constant.StringVal(v), ",") {
+				p.Import(dep)
+			}
+		}
+	})
+}
+
+func (p *Importer) doImport(pkgPath string) (pkg *types.Package, err error) {
 	if strings.HasPrefix(pkgPath, xgoMod) {
 		if suffix := pkgPath[len(xgoMod):]; suffix == "" || suffix[0] == '/' {
 			xgoRoot := p.xgo.Root
@@ -141,14 +228,14 @@ func (p *Importer) Import(pkgPath string) (pkg *types.Package, err error) {
 					return
 				}
 			}
-			return p.impFrom.ImportFrom(pkgPath, xgoRoot, 0)
+			return p.importFrom(pkgPath, xgoRoot)
 		}
 	}
 	if isPkgInMod(pkgPath, xMod) {
-		return p.impFrom.ImportFrom(pkgPath, p.xgo.Root, 0)
+		return p.importFrom(pkgPath, p.xgo.Root)
 	}
 	if mod := p.mod; mod.HasModfile() {
-		ret, e := mod.Lookup(pkgPath)
+		ret, e := lookupPkg(mod, pkgPath)
 		if e != nil {
 			return nil, e
 		}
@@ -167,7 +254,7 @@ func (p *Importer) Import(pkgPath string) (pkg *types.Package, err error) {
 				defer os.Chmod(modDir, modReadonly)
 				os.WriteFile(goModfile, defaultGoMod(ret.ModPath), 0644)
 			}
-			return p.impFrom.ImportFrom(pkgPath, ret.ModDir, 0)
+			return p.importFrom(pkgPath, ret.ModDir)
 		case xgomod.PkgtModule, xgomod.PkgtLocal:
 			if pkgPath == p.mod.Path() {
 				break
@@ -176,13 +263,30 @@ func (p *Importer) Import(pkgPath string) (pkg *types.Package, err error) {
 				return
 			}
 		case xgomod.PkgtStandard:
-			return p.impFrom.ImportFrom(pkgPath, p.xgo.Root, 0)
+			return p.importFrom(pkgPath, p.xgo.Root)
 		}
 	}
+	p.impMutex.Lock()
+	defer p.impMutex.Unlock()
 	return p.impFrom.Import(pkgPath)
 }
 
+// lockDir locks the generation of the package in dir, so that it isn't
+// generated by genGoExtern and genGoParallel at the same time.
+func (p *Importer) lockDir(dir string) (unlock func()) {
+	dir, _ = filepath.Abs(dir)
+	mutex, _ := p.genDirs.LoadOrStore(dir, new(sync.Mutex))
+	mutex.(*sync.Mutex).Lock()
+	return mutex.(*sync.Mutex).Unlock
+}
+
 func (p *Importer) genGoExtern(dir string, isExtern bool) (err error) {
+	defer p.lockDir(dir)()
+
+	conf := &Config{XGo: p.xgo, Importer: p, Fset: p.fset, Overlay: p.overlay, SourceMap: p.srcMap, isDep: true}
+	if p.overlay != nil { // generate or restore the overlay, see genGoIn
+		return genGoIn(dir, conf, false, p.Flags)
+	}
 	genfile := filepath.Join(dir, autoGenFile)
 	if _, err = os.Lstat(genfile); err != nil { // no xgo_autogen.go
 		if isExtern {
@@ -190,7 +294,7 @@ func (p *Importer) genGoExtern(dir string, isExtern bool) (err error) {
 			defer os.Chmod(dir, modReadonly)
 		}
 		gen := false
-		err = genGoIn(dir, &Config{XGo: p.xgo, Importer: p, Fset: p.fset}, false, p.Flags, &gen)
+		err = genGoIn(dir, conf, false, p.Flags, &gen)
 		if err != nil {
 			return
 		}
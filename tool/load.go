@@ -21,10 +21,13 @@ import (
 	"io/fs"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/goplus/gogen"
 	"github.com/goplus/mod/env"
+	"github.com/goplus/mod/modload"
 	"github.com/goplus/mod/xgomod"
 	"github.com/goplus/xgo/ast"
 	"github.com/goplus/xgo/cl"
@@ -130,8 +133,23 @@ type Config struct {
 	// CacheFile specifies the file path of the cache.
 	CacheFile string
 
+	// Parallel is the number of packages GenGo generates in parallel for
+	// "dir/...". Default is 1.
+	Parallel int
+
+	// SourceMap = true means to save the source map of each generated Go
+	// file in the user cache, see LoadSourceMap. The go commands run by the config
+	// returned by NewGoCmdConf report XGo positions by source maps then.
+	SourceMap bool
+
+	// If not nil, Go files are generated into Overlay instead of the source
+	// directories, and go.mod isn't updated. See ConfFlagOverlay.
+	Overlay *Overlay
+
 	IgnoreNotatedError bool
 	DontUpdateGoMod    bool
+
+	isDep bool // generating a dependency for Importer, see Overlay.pin
 }
 
 // ConfFlags represents configuration flags.
@@ -142,6 +160,8 @@ const (
 	ConfFlagDontUpdateGoMod
 	ConfFlagNoTestFiles
 	ConfFlagNoCacheFile
+	ConfFlagOverlay
+	ConfFlagSourceMap
 )
 
 // NewDefaultConf creates a dfault configuration for common cases.
@@ -160,14 +180,21 @@ func NewDefaultConf(dir string, flags ConfFlags, tags ...string) (conf *Config,
 		XGo: xgo, Fset: fset, Mod: mod, Importer: imp,
 		IgnoreNotatedError: flags&ConfFlagIgnoreNotatedError != 0,
 		DontUpdateGoMod:    flags&ConfFlagDontUpdateGoMod != 0,
+		SourceMap:          flags&ConfFlagSourceMap != 0,
 	}
-	if flags&ConfFlagNoCacheFile == 0 {
+	if flags&ConfFlagOverlay != 0 {
+		if conf.Overlay, err = NewOverlay(); err != nil {
+			return nil, errors.NewWith(err, `NewOverlay()`, -2, "tool.NewOverlay")
+		}
+		imp.setOverlay(conf.Overlay)
+	} else if flags&ConfFlagNoCacheFile == 0 {
 		conf.CacheFile = imp.CacheFile()
 		imp.Cache().Load(conf.CacheFile)
 	}
 	if flags&ConfFlagNoTestFiles != 0 {
 		conf.Filter = FilterNoTestFiles
 	}
+	imp.srcMap = conf.SourceMap
 	return
 }
 
@@ -177,9 +204,13 @@ func (conf *Config) NewGoCmdConf() *gocmd.Config {
 			os.Setenv("GOP_GOCMD", cl.Name)
 		}
 	}
-	return &gocmd.Config{
+	ret := &gocmd.Config{
 		XGo: conf.XGo,
 	}
+	if conf.SourceMap {
+		ret.MapPos = conf.newPosMapper()
+	}
+	return ret
 }
 
 // UpdateCache updates the cache.
@@ -277,6 +308,7 @@ func LoadDir(dir string, conf *Config, genTestPkg bool, promptGenGo ...bool) (ou
 		Importer:     imp,
 		LookupClass:  mod.LookupClass,
 	}
+	spans := setNodeSpans(clConf, conf)
 
 	for name, pkg := range pkgs {
 		if strings.HasSuffix(name, "_test") {
@@ -309,6 +341,7 @@ func LoadDir(dir string, conf *Config, genTestPkg bool, promptGenGo ...bool) (ou
 	if genTestPkg && pkgTest != nil {
 		test, err = cl.NewPackage("", pkgTest, clConf)
 	}
+	keepNodeSpans(spans, out, test)
 	afterLoad(mod, xgo, out, test, conf)
 	return
 }
@@ -317,23 +350,64 @@ func afterLoad(mod *xgomod.Module, xgo *env.XGo, out, test *gogen.Package, conf
 	if mod.Path() == xgoMod { // nothing to do for XGo itself
 		return
 	}
-	updateMod := !conf.DontUpdateGoMod && mod.HasModfile()
-	if updateMod || conf.XGoDeps != nil {
+	checkMod := !conf.DontUpdateGoMod && mod.HasModfile()
+	if checkMod || conf.XGoDeps != nil {
 		flags := checkGopDeps(out)
 		if conf.XGoDeps != nil { // for `xgo run`
 			*conf.XGoDeps = flags
 		}
-		if updateMod {
+		if checkMod {
 			if test != nil {
 				flags |= checkGopDeps(test)
 			}
-			if flags != 0 {
-				mod.SaveWithXGoMod(xgo, flags)
+			if flags == 0 {
+				return
+			}
+			if conf.Overlay != nil { // go.mod isn't written in overlay mode
+				warnModNotUpdated(mod, flags)
+				return
 			}
+			modMutex.Lock()
+			mod.SaveWithXGoMod(xgo, flags)
+			modMutex.Unlock()
 		}
 	}
 }
 
+// modsWarned records the modules warned by warnModNotUpdated.
+var modsWarned sync.Map // module root => true
+
+// warnModNotUpdated warns once per module that go.mod of mod doesn't require
+// the modules the generated files depend on, see checkGopDeps, as it isn't
+// updated in overlay mode.
+func warnModNotUpdated(mod *xgomod.Module, flags int) {
+	missing := missingXGoDeps(mod, flags)
+	if len(missing) == 0 {
+		return
+	}
+	if _, warned := modsWarned.LoadOrStore(mod.Root(), true); warned {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "warning: %s doesn't require %s, and isn't updated in overlay mode\n",
+		filepath.Join(mod.Root(), "go.mod"), strings.Join(missing, ", "))
+}
+
+// missingXGoDeps returns the modules required by flags, see checkGopDeps,
+// which go.mod of mod doesn't require.
+func missingXGoDeps(mod *xgomod.Module, flags int) (missing []string) {
+	required := make(map[string]bool)
+	for _, r := range mod.File.Require {
+		required[r.Mod.Path] = true
+	}
+	if flags&modload.FlagDepModXGo != 0 && !required[xgoMod] && !required[gopMod] {
+		missing = append(missing, xgoMod)
+	}
+	if flags&modload.FlagDepModX != 0 && !required[xMod] {
+		missing = append(missing, xMod)
+	}
+	return
+}
+
 func checkGopDeps(pkg *gogen.Package) (flags int) {
 	pkg.ForEachFile(func(fname string, file *gogen.File) {
 		flags |= file.CheckGopDeps(pkg)
@@ -397,11 +471,14 @@ func LoadFiles(dir string, files []string, conf *Config) (out *gogen.Package, er
 			Importer:     imp,
 			LookupClass:  mod.LookupClass,
 		}
+		spans := setNodeSpans(clConf, conf)
 		out, err = cl.NewPackage("", pkg, clConf)
 		if err != nil {
 			if conf.IgnoreNotatedError {
 				err = ignNotatedErrs(err, pkg, fset)
 			}
+		} else {
+			keepNodeSpans(spans, out)
 		}
 		break
 	}
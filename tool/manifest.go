@@ -0,0 +1,285 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tool
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/goplus/gogen"
+	"github.com/goplus/gogen/packages/cache"
+)
+
+// -----------------------------------------------------------------------------
+
+// genManifest records how xgo_autogen.go of a package was generated, so that
+// genGoIn can skip the package if none of its inputs changes.
+type genManifest struct {
+	Mode       string            `json:"mode"`              // see genMode
+	Hash       string            `json:"hash"`              // dirHash of the package after generation
+	Deps       map[string]string `json:"deps"`              // import path => PkgHash of the packages it depends on
+	XGoDeps    int               `json:"xgodeps"`           // see Config.XGoDeps
+	Outputs    map[string]string `json:"outputs"`           // generated file name => outputHash
+	SourceMaps []string          `json:"srcmaps,omitempty"` // generated files with a source map, see sourceMapFile
+}
+
+const (
+	// manifestUsedUpdate is how often the mtime of a used manifest is updated.
+	manifestUsedUpdate = time.Hour
+
+	// manifestTrimAge is how long a manifest is kept after it's last used.
+	manifestTrimAge = 5 * 24 * time.Hour
+
+	// manifestTrimInterval is how often the manifests are trimmed.
+	manifestTrimInterval = 24 * time.Hour
+)
+
+// genMode returns the options that affect the output of genGoIn.
+func genMode(conf *Config, genTestPkg bool) string {
+	var tags string
+	if conf.Importer != nil {
+		tags = conf.Importer.impFrom.Tags()
+	}
+	return fmt.Sprintf("tags=%s test=%v notest=%v ignnotated=%v overlay=%v srcmap=%v",
+		tags, genTestPkg, conf.Filter != nil, conf.IgnoreNotatedError,
+		conf.Overlay != nil, conf.SourceMap)
+}
+
+// manifestFile returns the path of the manifest of the package in dir
+// generated in mode, see genMode.
+func manifestFile(dir, mode string) string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	dir, _ = filepath.Abs(dir)
+	h := sha256.Sum256([]byte(dir + "\n" + mode))
+	return filepath.Join(cacheDir, "xgo-build", "gengo", base64.RawURLEncoding.EncodeToString(h[:]))
+}
+
+// genFiles are the files generated by genGoIn.
+var genFiles = []string{autoGenFile, autoGenTestFile, autoGen2TestFile}
+
+// outputFile returns the path of the generated file fname of dir, in
+// conf.Overlay if any.
+func outputFile(dir, fname, mode string, conf *Config) string {
+	if conf.Overlay != nil {
+		return filepath.Join(conf.Overlay.genDir(dir, mode), fname)
+	}
+	return filepath.Join(dir, fname)
+}
+
+// outputHash returns the hash of the generated file fname of dir, or "" if
+// it doesn't exist.
+func outputHash(dir, fname, mode string, conf *Config) string {
+	b, err := os.ReadFile(outputFile(dir, fname, mode, conf))
+	if err != nil {
+		return ""
+	}
+	h := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+// upToDate reports whether the package in dir was generated with the same
+// options, and neither it nor any package it depends on changes since then.
+func upToDate(dir string, conf *Config, genTestPkg bool) bool {
+	imp := conf.Importer
+	if imp == nil {
+		return false
+	}
+	mode := genMode(conf, genTestPkg)
+	file := manifestFile(dir, mode)
+	if file == "" {
+		return false
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return false
+	}
+	var m genManifest
+	if json.Unmarshal(b, &m) != nil || m.Mode != mode {
+		return false
+	}
+	if dirHash(imp.mod, imp.xgo, dir, true) != m.Hash {
+		return false
+	}
+	for _, fname := range genFiles { // eg. removed by `gop clean`, or edited
+		if outputHash(dir, fname, mode, conf) != m.Outputs[fname] {
+			return false
+		}
+	}
+	for pkgPath, hash := range m.Deps {
+		if imp.PkgHash(pkgPath, false) != hash {
+			return false
+		}
+	}
+	now := time.Now()
+	for _, fname := range m.SourceMaps { // eg. trimmed from the cache
+		mapFile := sourceMapFile(outputFile(dir, fname, mode, conf))
+		if mtime := fileModTime(mapFile); mtime.IsZero() {
+			return false
+		} else if now.Sub(mtime) > manifestUsedUpdate {
+			os.Chtimes(mapFile, now, now)
+		}
+	}
+	if conf.XGoDeps != nil {
+		*conf.XGoDeps = m.XGoDeps
+	}
+	if now.Sub(fileModTime(file)) > manifestUsedUpdate {
+		os.Chtimes(file, now, now)
+	}
+	return true
+}
+
+// saveManifest saves the manifest of the package in dir generated as out, or
+// of a directory without XGo files if out is nil. The dependencies are the
+// packages imported by its files and their dependencies. Nothing is saved if
+// a dependency can't be fingerprinted.
+func saveManifest(dir string, conf *Config, genTestPkg bool, out *gogen.Package) {
+	imp := conf.Importer
+	if imp == nil {
+		return
+	}
+	mode := genMode(conf, genTestPkg)
+	file := manifestFile(dir, mode)
+	if file == "" {
+		return
+	}
+	m := &genManifest{
+		Mode:    mode,
+		Hash:    dirHash(imp.mod, imp.xgo, dir, true),
+		Deps:    make(map[string]string),
+		Outputs: make(map[string]string),
+	}
+	for _, fname := range genFiles {
+		if hash := outputHash(dir, fname, mode, conf); hash != "" {
+			m.Outputs[fname] = hash
+			if conf.SourceMap && fileExists(sourceMapFile(outputFile(dir, fname, mode, conf))) {
+				m.SourceMaps = append(m.SourceMaps, fname)
+			}
+		}
+	}
+	var pkgs []*types.Package
+	if out != nil {
+		m.XGoDeps = checkGopDeps(out)
+		filter := conf.Filter
+		if !genTestPkg && filter == nil {
+			filter = FilterNoTestFiles
+		}
+		for _, pkgPath := range pkgImports(imp.mod, dir, filter) {
+			pkg, err := imp.Import(pkgPath)
+			if err != nil {
+				os.Remove(file)
+				return
+			}
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	seen := make(map[*types.Package]bool)
+	for len(pkgs) > 0 {
+		pkg := pkgs[len(pkgs)-1]
+		pkgs = pkgs[:len(pkgs)-1]
+		if seen[pkg] {
+			continue
+		}
+		seen[pkg] = true
+		pkgs = append(pkgs, pkg.Imports()...)
+		switch hash := imp.PkgHash(pkg.Path(), false); hash {
+		case cache.HashSkip:
+		case cache.HashInvalid:
+			os.Remove(file)
+			return
+		default:
+			m.Deps[pkg.Path()] = hash
+		}
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	os.MkdirAll(filepath.Dir(file), 0755)
+	os.WriteFile(file, b, 0644)
+	trimCacheDir(filepath.Dir(file), time.Now())
+}
+
+// trimCacheDir removes the files in dir, manifests or source maps, which
+// aren't used for manifestTrimAge, at most once per manifestTrimInterval.
+func trimCacheDir(dir string, now time.Time) {
+	stamp := filepath.Join(dir, "trim.txt")
+	if now.Sub(fileModTime(stamp)) < manifestTrimInterval {
+		return
+	}
+	if err := os.WriteFile(stamp, nil, 0644); err != nil {
+		return
+	}
+	os.Chtimes(stamp, now, now)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.Name() == "trim.txt" || e.IsDir() {
+			continue
+		}
+		file := filepath.Join(dir, e.Name())
+		if now.Sub(fileModTime(file)) > manifestTrimAge {
+			os.Remove(file)
+		}
+	}
+}
+
+// fileModTime returns the mtime of file, or the zero time if it doesn't exist.
+func fileModTime(file string) time.Time {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
+// writeFile writes the file fname of pkg to file, see gogen.Package.WriteFile.
+// The file isn't rewritten if its content doesn't change, so that its mtime
+// and the build cache of go stay unchanged.
+func writeFile(pkg *gogen.Package, file string, fname ...string) error {
+	return writeGoFile(pkg, file, file, fname...)
+}
+
+// writeGoFile writes the file fname of pkg to gen, and its source map if
+// any, see writeSourceMap. file is the path of gen seen by the go command.
+func writeGoFile(pkg *gogen.Package, gen, file string, fname ...string) error {
+	var b bytes.Buffer
+	b.WriteString(gogen.GeneratedHeader)
+	if err := pkg.WriteTo(&b, fname...); err != nil {
+		return err
+	}
+	if err := writeSourceMap(pkg, fname, gen, file, b.Bytes()); err != nil {
+		return err
+	}
+	if old, err := os.ReadFile(gen); err == nil && bytes.Equal(old, b.Bytes()) {
+		return nil
+	}
+	return os.WriteFile(gen, b.Bytes(), 0666)
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,216 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tool
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestConf(t *testing.T, root string, flags ConfFlags, tags ...string) *Config {
+	t.Helper()
+	conf, err := NewDefaultConf(root, flags|ConfFlagNoCacheFile, tags...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Overlay != nil {
+		t.Cleanup(func() { conf.Overlay.Close() })
+	}
+	return conf
+}
+
+// manifestTest generates the package c of a testModule, and then changes
+// something.
+type manifestTest struct {
+	name   string
+	flags  ConfFlags
+	change func(t *testing.T, root string, conf *Config)
+	tags   []string // tags of upToDate
+	test   bool     // genTestPkg of upToDate
+	want   bool
+}
+
+// editFile rewrites file with data, keeping its size and mtime.
+func editFile(t *testing.T, file string, edit func(b []byte)) {
+	t.Helper()
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edit(b)
+	if err = os.WriteFile(file, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chtimes(file, fi.ModTime(), fi.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// lastByte changes the last byte of a Go file, a newline, to a space.
+func lastByte(b []byte) {
+	b[len(b)-1] = ' '
+}
+
+func TestUpToDate(t *testing.T) {
+	later := func(file string) {
+		mtime := time.Now().Add(time.Second)
+		os.Chtimes(file, mtime, mtime)
+	}
+	tests := []manifestTest{
+		{"unchanged", 0, nil, nil, false, true},
+		{"source edited", 0, func(t *testing.T, root string, _ *Config) {
+			writeTestFiles(t, root, map[string]string{
+				"c/c.xgo": "package c\n\nfunc C() int { return 5 }\n",
+			})
+			later(filepath.Join(root, "c/c.xgo"))
+		}, nil, false, false},
+		{"dependency edited", 0, func(t *testing.T, root string, _ *Config) {
+			writeTestFiles(t, root, map[string]string{
+				"a/a.xgo": "package a\n\nfunc A() int { return 2 }\n",
+			})
+			later(filepath.Join(root, "a/a.xgo"))
+		}, nil, false, false},
+		{"generated file deleted", 0, func(t *testing.T, root string, _ *Config) {
+			os.Remove(filepath.Join(root, "c", autoGenFile))
+		}, nil, false, false},
+		{"generated file edited", 0, func(t *testing.T, root string, _ *Config) {
+			editFile(t, filepath.Join(root, "c", autoGenFile), lastByte)
+		}, nil, false, false},
+		{"test file generated", 0, func(t *testing.T, root string, _ *Config) {
+			writeTestFiles(t, root, map[string]string{"c/" + autoGenTestFile: "package c\n"})
+		}, nil, false, false},
+		{"tags changed", 0, nil, []string{"foo"}, false, false},
+		{"test mode changed", 0, nil, nil, true, false},
+		{"overlay unchanged", ConfFlagOverlay, nil, nil, false, true},
+		{"overlay file deleted", ConfFlagOverlay, func(t *testing.T, root string, conf *Config) {
+			os.Remove(filepath.Join(conf.Overlay.genDir(filepath.Join(root, "c"), genMode(conf, false)), autoGenFile))
+		}, nil, false, false},
+		{"overlay file edited", ConfFlagOverlay, func(t *testing.T, root string, conf *Config) {
+			editFile(t, filepath.Join(conf.Overlay.genDir(filepath.Join(root, "c"), genMode(conf, false)), autoGenFile), lastByte)
+		}, nil, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTestFiles(t, root, testModule)
+			dir := filepath.Join(root, "c")
+			conf := newTestConf(t, root, tt.flags)
+			if _, _, err := GenGo(dir, conf, false); err != nil {
+				t.Fatal("GenGo:", err)
+			}
+			if tt.change != nil {
+				tt.change(t, root, conf)
+			}
+			conf2 := newTestConf(t, root, tt.flags&^ConfFlagOverlay, tt.tags...)
+			conf2.Overlay = conf.Overlay
+			if got := upToDate(dir, conf2, tt.test); got != tt.want {
+				t.Fatalf("upToDate: got %v, want %v", got, tt.want)
+			}
+			if tt.want {
+				return
+			}
+			// up to date once generated again
+			if _, _, err := GenGo(dir, conf2, tt.test); err != nil {
+				t.Fatal("GenGo:", err)
+			}
+			if !upToDate(dir, conf2, tt.test) {
+				t.Fatal("upToDate: false after generated")
+			}
+		})
+	}
+}
+
+func TestUpToDateOutputs(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, testModule)
+	dir := filepath.Join(root, "b")
+	conf := newTestConf(t, root, 0)
+	if _, _, err := GenGo(dir, conf, true); err != nil {
+		t.Fatal("GenGo:", err)
+	}
+	m := readManifest(t, dir, conf, true)
+	for _, fname := range genFiles {
+		if m.Outputs[fname] == "" {
+			t.Errorf("%s: not recorded", fname)
+		}
+	}
+
+	// the output of a deleted generated file is generated again
+	os.Remove(filepath.Join(dir, autoGen2TestFile))
+	if _, _, err := GenGo(dir, newTestConf(t, root, 0), true); err != nil {
+		t.Fatal("GenGo:", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, autoGen2TestFile)); err != nil {
+		t.Fatal("not generated again:", err)
+	}
+}
+
+func readManifest(t *testing.T, dir string, conf *Config, genTestPkg bool) *genManifest {
+	t.Helper()
+	b, err := os.ReadFile(manifestFile(dir, genMode(conf, genTestPkg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m genManifest
+	if err = json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	return &m
+}
+
+func TestTrimCacheDir(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	files := map[string]time.Time{
+		"used":   now.Add(-time.Hour),
+		"unused": now.Add(-manifestTrimAge - time.Hour),
+	}
+	for name, mtime := range files {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(file, mtime, mtime)
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+
+	trimCacheDir(dir, now)
+	if !exists("used") || exists("unused") || !exists("trim.txt") {
+		t.Fatal("trimCacheDir: wrong manifests removed")
+	}
+
+	// not trimmed again within manifestTrimInterval
+	later := now.Add(manifestTrimAge)
+	trimCacheDir(dir, now.Add(time.Hour))
+	if !exists("used") {
+		t.Fatal("trimCacheDir: trimmed twice in an interval")
+	}
+	trimCacheDir(dir, later)
+	if exists("used") {
+		t.Fatal("trimCacheDir: not trimmed after an interval")
+	}
+}
@@ -0,0 +1,246 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tool
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/goplus/gogen"
+)
+
+// -----------------------------------------------------------------------------
+
+// Overlay holds the Go files generated in overlay mode. Instead of writing
+// xgo_autogen.go and friends into the source directories, they are written to
+// a cache directory and passed to the go command by its -overlay flag, so the
+// source directories are never modified.
+//
+// The files of a directory are kept apart per generation mode, see genMode,
+// so that generating in one mode doesn't overwrite the files of another. The
+// go commands run by Importer are passed the -overlay flag explicitly, see
+// overlayCache. It is safe for concurrent use.
+type Overlay struct {
+	root string // cache directory of the generated files
+	file string // overlay JSON file, see save
+
+	mutex sync.Mutex
+	files map[string]string // source path => generated file
+	pins  map[string]string // directory => mode of its files, see pin
+}
+
+// NewOverlay creates an Overlay storing generated files in the user cache
+// directory. Call Close to remove its overlay JSON file.
+func NewOverlay() (*Overlay, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp("", "xgo-overlay*.json")
+	if err != nil {
+		return nil, err
+	}
+	p := &Overlay{
+		root:  filepath.Join(cacheDir, "xgo-build", "overlay"),
+		file:  f.Name(),
+		files: make(map[string]string),
+		pins:  make(map[string]string),
+	}
+	f.Close()
+	if err = p.save(); err != nil {
+		os.Remove(p.file)
+		return nil, err
+	}
+	return p, nil
+}
+
+// Close removes the overlay JSON file. The generated files are kept for
+// later builds.
+func (p *Overlay) Close() error {
+	if p == nil {
+		return nil
+	}
+	return os.Remove(p.file)
+}
+
+// Files returns a copy of the mapping from source paths to generated files,
+// see gocmd.Config.Overlay.
+func (p *Overlay) Files() map[string]string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	ret := make(map[string]string, len(p.files))
+	for k, v := range p.files {
+		ret[k] = v
+	}
+	return ret
+}
+
+// genFile returns the generated file replacing the source file.
+func (p *Overlay) genFile(file string) (gen string, ok bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	gen, ok = p.files[file]
+	return
+}
+
+// genDir returns the cache directory of the files generated for dir in mode,
+// see genMode.
+func (p *Overlay) genDir(dir, mode string) string {
+	dir, _ = filepath.Abs(dir)
+	h := sha256.Sum256([]byte(dir + "\n" + mode))
+	return filepath.Join(p.root, base64.RawURLEncoding.EncodeToString(h[:]))
+}
+
+// pin makes the go command see the files of dir generated in mode, even if
+// the package is generated in another mode later, eg. as a dependency of its
+// external test package. Files generated in another mode before are seen
+// until the files of mode are added.
+func (p *Overlay) pin(dir, mode string) {
+	dir, _ = filepath.Abs(dir)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.pins[dir] = mode
+}
+
+// writeFile writes the file fname of pkg as the source file generated in
+// mode, see writeFile.
+func (p *Overlay) writeFile(mode string, pkg *gogen.Package, file string, fname ...string) error {
+	file, _ = filepath.Abs(file)
+	gen := filepath.Join(p.genDir(filepath.Dir(file), mode), filepath.Base(file))
+	os.MkdirAll(filepath.Dir(gen), 0755)
+	if err := writeGoFile(pkg, gen, file, fname...); err != nil {
+		return err
+	}
+	return p.add(mode, file, gen)
+}
+
+// remove removes the source file generated in mode from the overlay.
+func (p *Overlay) remove(mode, file string) error {
+	file, _ = filepath.Abs(file)
+	os.Remove(filepath.Join(p.genDir(filepath.Dir(file), mode), filepath.Base(file)))
+	return p.forget(mode, file)
+}
+
+// forget removes the source file generated in mode from the overlay JSON
+// file.
+func (p *Overlay) forget(mode, file string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, ok := p.files[file]; !ok || p.pinned(file, mode) {
+		return nil
+	}
+	delete(p.files, file)
+	return p.save()
+}
+
+// restore adds the files generated for dir in mode by a previous build, and
+// removes the files of dir not generated then.
+func (p *Overlay) restore(dir, mode string) error {
+	dir, _ = filepath.Abs(dir)
+	genDir := p.genDir(dir, mode)
+	for _, fname := range genFiles {
+		file, gen := filepath.Join(dir, fname), filepath.Join(genDir, fname)
+		_, err := os.Lstat(gen)
+		if err == nil {
+			err = p.add(mode, file, gen)
+		} else {
+			err = p.forget(mode, file)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Overlay) add(mode, file, gen string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.files[file] == gen || p.pinned(file, mode) {
+		return nil
+	}
+	p.files[file] = gen
+	return p.save()
+}
+
+// pinned reports whether the files of the directory of file are pinned to
+// another mode than mode, see pin. It's called with p.mutex held.
+func (p *Overlay) pinned(file, mode string) bool {
+	pin, ok := p.pins[filepath.Dir(file)]
+	return ok && pin != mode
+}
+
+// save writes the overlay JSON file, see `go help build`.
+func (p *Overlay) save() error {
+	b, err := json.Marshal(struct{ Replace map[string]string }{p.files})
+	if err != nil {
+		return err
+	}
+	tmp := p.file + ".tmp"
+	if err = os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.file)
+}
+
+// -----------------------------------------------------------------------------
+
+// overlayCache is the cache of Importer in overlay mode, see packages.Cache.
+// It finds the export data of packages by `go list -export` with the -overlay
+// flag of an Overlay, so that they're compiled with the generated files. The
+// export data isn't saved for later builds, see Config.UpdateCache.
+type overlayCache struct {
+	ov   *Overlay
+	tags string
+
+	expfiles sync.Map // pkgPath => export file
+}
+
+// Find finds the export data of the package pkgPath imported in dir.
+func (p *overlayCache) Find(dir, pkgPath string) (f io.ReadCloser, err error) {
+	expfile, ok := p.expfiles.Load(pkgPath)
+	if !ok {
+		args := []string{"list", "-f={{.Export}}", "-export", "-overlay=" + p.ov.file}
+		if p.tags != "" {
+			args = append(args, "-tags="+p.tags)
+		}
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command("go", append(args, pkgPath)...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		cmd.Dir = dir
+		if err = cmd.Run(); err != nil {
+			if stderr.Len() > 0 {
+				err = errors.New(stderr.String())
+			}
+			return
+		}
+		expfile, _ = p.expfiles.LoadOrStore(pkgPath, strings.TrimSpace(stdout.String()))
+	}
+	return os.Open(expfile.(string))
+}
+
+// -----------------------------------------------------------------------------
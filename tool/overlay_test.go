@@ -0,0 +1,178 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tool
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/goplus/mod/modload"
+)
+
+// overlayFiles returns the names of the files in the overlay of conf, and
+// checks they are generated in mode, see genMode.
+func overlayFiles(t *testing.T, dir string, conf *Config, genTestPkg bool) (names []string) {
+	t.Helper()
+	genDir := conf.Overlay.genDir(dir, genMode(conf, genTestPkg))
+	for file, gen := range conf.Overlay.Files() {
+		if filepath.Dir(file) != dir {
+			continue
+		}
+		if filepath.Dir(gen) != genDir {
+			t.Errorf("%s: generated in %s, want %s", file, gen, genDir)
+		}
+		names = append(names, filepath.Base(file))
+	}
+	sort.Strings(names)
+	return
+}
+
+func TestOverlayModes(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, testModule)
+	dir := filepath.Join(root, "b")
+	goflags := os.Getenv("GOFLAGS")
+	allFiles := []string{autoGenFile, autoGen2TestFile, autoGenTestFile}
+
+	// test, build, and then test again, each by a new command
+	conf := newTestConf(t, root, ConfFlagOverlay)
+	if os.Getenv("GOFLAGS") != goflags {
+		t.Fatal("NewOverlay: GOFLAGS changed:", os.Getenv("GOFLAGS"))
+	}
+	if _, _, err := GenGo(dir, conf, true); err != nil {
+		t.Fatal("GenGo test:", err)
+	}
+	if got := overlayFiles(t, dir, conf, true); !reflect.DeepEqual(got, allFiles) {
+		t.Fatal("test: overlay", got)
+	}
+
+	conf = newTestConf(t, root, ConfFlagOverlay)
+	if _, _, err := GenGo(dir, conf, false); err != nil {
+		t.Fatal("GenGo build:", err)
+	}
+	if got := overlayFiles(t, dir, conf, false); !reflect.DeepEqual(got, []string{autoGenFile, autoGenTestFile}) {
+		t.Fatal("build: overlay", got)
+	}
+
+	conf = newTestConf(t, root, ConfFlagOverlay)
+	if !upToDate(dir, conf, true) {
+		t.Fatal("test again: not up to date")
+	}
+	wd, _ := os.Getwd()
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := TestDir(dir, conf, conf.NewGoCmdConf()); err != nil {
+		t.Fatal("TestDir:", err)
+	}
+	if got := overlayFiles(t, dir, conf, true); !reflect.DeepEqual(got, allFiles) {
+		t.Fatal("test again: overlay", got)
+	}
+	if got := generated(t, root); len(got) != 0 {
+		t.Fatal("generated into the source directories:", keys(got))
+	}
+}
+
+func TestOverlayPin(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, testModule)
+	dir := filepath.Join(root, "b")
+	conf := newTestConf(t, root, ConfFlagOverlay)
+	test, build := genMode(conf, true), genMode(conf, false)
+	if _, _, err := GenGo(dir, conf, true); err != nil {
+		t.Fatal("GenGo:", err)
+	}
+
+	// b is generated in build mode too, as c imported by its external test
+	// package imports b, but the files of the test mode are kept
+	if err := conf.Overlay.restore(dir, build); err != nil {
+		t.Fatal("restore:", err)
+	}
+	if got := overlayFiles(t, dir, conf, true); len(got) != 3 {
+		t.Fatal("pinned:", got)
+	}
+
+	// the overlay of a new command isn't pinned
+	conf = newTestConf(t, root, ConfFlagOverlay)
+	ov := conf.Overlay
+	if err := ov.restore(dir, test); err != nil {
+		t.Fatal("restore:", err)
+	}
+	if got := overlayFiles(t, dir, conf, true); len(got) != 3 {
+		t.Fatal("restore test:", got)
+	}
+	if err := ov.restore(dir, build); err != nil {
+		t.Fatal("restore:", err)
+	}
+	if got := overlayFiles(t, dir, conf, false); !reflect.DeepEqual(got, []string{autoGenFile, autoGenTestFile}) {
+		t.Fatal("restore build:", got)
+	}
+}
+
+func TestMissingXGoDeps(t *testing.T) {
+	const (
+		xgo = modload.FlagDepModXGo
+		x   = modload.FlagDepModX
+	)
+	tests := []struct {
+		requires string
+		flags    int
+		want     []string
+	}{
+		{"", 0, nil},
+		{"", xgo | x, []string{xgoMod, xMod}},
+		{"require github.com/qiniu/x v1.13.0\n", xgo | x, []string{xgoMod}},
+		{"require github.com/goplus/xgo v1.5.0\n", xgo | x, []string{xMod}},
+		{"require github.com/goplus/gop v1.2.0\n", xgo, nil},
+		{"require github.com/qiniu/x v1.13.0\n", x, nil},
+	}
+	for _, tt := range tests {
+		root := t.TempDir()
+		writeTestFiles(t, root, map[string]string{"go.mod": testGoMod + tt.requires})
+		mod, err := LoadMod(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := missingXGoDeps(mod, tt.flags); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("missingXGoDeps(%q, %d): got %v, want %v", tt.requires, tt.flags, got, tt.want)
+		}
+	}
+}
+
+func TestOverlayGoModUnchanged(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, map[string]string{
+		"go.mod": testGoMod,
+		"a/a.xgo": "package a\n\nimport \"strconv\"\n\nfunc A(s string) (int, error) {\n" +
+			"\treturn strconv.Atoi(s)?, nil\n}\n",
+	})
+	conf := newTestConf(t, root, ConfFlagOverlay)
+	if _, _, err := GenGo(filepath.Join(root, "a"), conf, false); err != nil {
+		t.Fatal("GenGo:", err)
+	}
+	b, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil || string(b) != testGoMod {
+		t.Fatalf("go.mod: %q, %v", b, err)
+	}
+	if _, warned := modsWarned.Load(root); !warned {
+		t.Fatal("go.mod isn't updated, but not warned")
+	}
+}
@@ -0,0 +1,284 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tool
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goplus/mod/xgomod"
+	"github.com/goplus/xgo/parser"
+	"github.com/goplus/xgo/token"
+	"github.com/goplus/xgo/x/xgoenv"
+	"github.com/qiniu/x/errors"
+
+	astmod "github.com/goplus/xgo/ast/mod"
+)
+
+// -----------------------------------------------------------------------------
+
+// ErrImportCycle is returned by GenGo if packages generated in parallel
+// import each other.
+var ErrImportCycle = errors.New("import cycle not allowed")
+
+// genGoParallel generates Go files for the packages under dir, conf.Parallel
+// packages at a time. A package is generated after the packages it imports
+// from the same directory tree, including the imports of its test files if
+// genTestPkg is true. Errors are reported in path order.
+func genGoParallel(dir string, conf *Config, genTestPkg bool, flags GenFlags) (err error) {
+	mod := conf.Mod
+	if mod == nil {
+		if mod, err = LoadMod(dir); err != nil {
+			return errors.NewWith(err, `LoadMod(dir)`, -2, "tool.LoadMod", dir)
+		}
+	}
+	if conf.Importer == nil { // the packages share an Importer, see Importer.lockDir
+		c := *conf
+		if c.XGo == nil {
+			c.XGo = xgoenv.Get()
+		}
+		if c.Fset == nil {
+			c.Fset = token.NewFileSet()
+		}
+		c.Mod, c.Importer = mod, NewImporter(mod, c.XGo, c.Fset)
+		conf = &c
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return errors.NewWith(err, `filepath.Abs(dir)`, -2, "filepath.Abs", dir)
+	}
+	var jobs []parallelJob
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			if strings.HasPrefix(d.Name(), "_") || (path != dir && hasMod(path)) { // skip _
+				return filepath.SkipDir
+			}
+			imports, xtest := pkgImportsEx(mod, path, conf.Filter)
+			if !genTestPkg || len(xtest) == 0 {
+				jobs = append(jobs, parallelJob{dir: path, test: genTestPkg, imports: imports})
+			} else {
+				jobs = append(jobs,
+					parallelJob{dir: path, imports: imports},
+					parallelJob{dir: path, test: true, xtest: true, imports: xtest})
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return errors.NewWith(err, `filepath.WalkDir(dir, fn)`, -2, "filepath.WalkDir", dir)
+	}
+
+	n := len(jobs)
+	waiting := make([]int, n)        // number of dependencies not generated yet
+	users := make([][]int, n)        // jobs depending on jobs[i]
+	index := make(map[string]int, n) // import path => index of the first job of the package
+	for i, job := range jobs {
+		if pkgPath := pkgPathOf(mod, job.dir); pkgPath != "" && !job.xtest {
+			index[pkgPath] = i
+		}
+	}
+	deps := make([][]int, n) // jobs which jobs[i] depends on
+	depends := func(i, j int) {
+		users[j] = append(users[j], i)
+		deps[i] = append(deps[i], j)
+		waiting[i]++
+	}
+	for i, job := range jobs {
+		if job.xtest {
+			depends(i, i-1)
+		}
+		for _, imp := range job.imports {
+			if j, ok := index[imp]; ok && j != i && !(job.xtest && j == i-1) {
+				depends(i, j)
+			}
+		}
+	}
+
+	if cycle := importCycle(deps, waiting, users); cycle != nil {
+		names := make([]string, len(cycle))
+		for k, i := range cycle {
+			names[k] = pkgPathOf(mod, jobs[i].dir)
+			if jobs[i].xtest {
+				names[k] += "_test"
+			}
+		}
+		err = fmt.Errorf("%w: %s", ErrImportCycle, strings.Join(names, " -> "))
+		if flags&GenFlagPrintError != 0 {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return
+	}
+
+	errs := make([]error, n)
+	ready := make(chan int, n)
+	done := make(chan int)
+	for i := range jobs {
+		if waiting[i] == 0 {
+			ready <- i
+		}
+	}
+	imp := conf.Importer
+	imp.setParallel()
+	for w := 0; w < conf.Parallel; w++ {
+		go func() {
+			for i := range ready {
+				job := jobs[i]
+				switch {
+				case !job.xtest:
+					unlock := imp.lockDir(job.dir)
+					errs[i] = genGoIn(job.dir, conf, job.test, flags)
+					unlock()
+				case errs[i-1] == nil: // not locked, see parallelJob
+					errs[i] = genGoIn(job.dir, conf, true, flags)
+				}
+				done <- i
+			}
+		}()
+	}
+
+	var list errors.List
+	finished := make([]bool, n)
+	next := 0 // the next job to report errors in path order
+	for k := 0; k < n; k++ {
+		i := <-done
+		finished[i] = true
+		for _, u := range users[i] {
+			if waiting[u]--; waiting[u] == 0 {
+				ready <- u
+			}
+		}
+		for ; next < n && finished[next]; next++ {
+			if e := errs[next]; e != nil && notIgnNotated(e, conf) {
+				if flags&GenFlagPrintError != 0 {
+					fmt.Fprintln(os.Stderr, e)
+				}
+				list.Add(e)
+			}
+		}
+	}
+	close(ready)
+	return list.ToError()
+}
+
+// importCycle returns a cycle of the jobs, which depend on deps, starting and
+// ending with the same job, or nil if there is no cycle. waiting and users are
+// as in genGoParallel.
+func importCycle(deps [][]int, waiting []int, users [][]int) []int {
+	n := len(deps)
+	left := append([]int(nil), waiting...) // dependencies not visited yet
+	queue := make([]int, 0, n)
+	for i := range left {
+		if left[i] == 0 {
+			queue = append(queue, i)
+		}
+	}
+	for k := 0; k < len(queue); k++ {
+		for _, u := range users[queue[k]] {
+			if left[u]--; left[u] == 0 {
+				queue = append(queue, u)
+			}
+		}
+	}
+	if len(queue) == n {
+		return nil
+	}
+	// each job left depends on another job left, so following them from any
+	// job leads to a cycle
+	start := 0
+	for left[start] == 0 {
+		start++
+	}
+	pos := make(map[int]int) // job => position in path
+	var path []int
+	for i := start; ; {
+		if k, ok := pos[i]; ok {
+			return append(path[k:], i)
+		}
+		pos[i] = len(path)
+		path = append(path, i)
+		for _, j := range deps[i] {
+			if left[j] > 0 {
+				i = j
+				break
+			}
+		}
+	}
+}
+
+// parallelJob is a job of genGoParallel, which generates the package in dir.
+//
+// The external test package of a package may import packages importing the
+// package, so it can't be generated with the package: the package is
+// generated without it first, and then the test job generates the package
+// again with it after the packages it imports. The test job doesn't lock the
+// package, whose Go files are generated by then, as importing its imports may
+// go back to it, see genOverlayDeps.
+type parallelJob struct {
+	dir     string
+	test    bool     // genTestPkg of genGoIn
+	xtest   bool     // the test job of an external test package
+	imports []string // the imports of the package, or the external test package
+}
+
+// pkgPathOf returns the import path of the package in dir, "" if dir is out of
+// mod.
+func pkgPathOf(mod *xgomod.Module, dir string) string {
+	if !mod.HasModfile() {
+		return ""
+	}
+	dir, _ = filepath.Abs(dir)
+	rel, err := filepath.Rel(mod.Root(), dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	if rel == "." {
+		return mod.Path()
+	}
+	return mod.Path() + "/" + filepath.ToSlash(rel)
+}
+
+// pkgImports returns the packages imported by the files in dir passing
+// through filter.
+func pkgImports(mod *xgomod.Module, dir string, filter func(fs.FileInfo) bool) []string {
+	imports, xtest := pkgImportsEx(mod, dir, filter)
+	return append(imports, xtest...)
+}
+
+// pkgImportsEx is like pkgImports, but returns the imports of the external
+// test package, whose name ends with _test, separately.
+func pkgImportsEx(mod *xgomod.Module, dir string, filter func(fs.FileInfo) bool) (imports, xtest []string) {
+	pkgs, _ := parser.ParseDirEx(token.NewFileSet(), dir, parser.Config{
+		ClassKind: mod.ClassKind,
+		Filter:    filter,
+		Mode:      parser.ImportsOnly,
+	})
+	for name, pkg := range pkgs {
+		ret := &imports
+		if strings.HasSuffix(name, "_test") {
+			ret = &xtest
+		}
+		deps := astmod.Deps{HandlePkg: func(pkgPath string) {
+			*ret = append(*ret, pkgPath)
+		}}
+		deps.Load(pkg, false)
+	}
+	return
+}
+
+// -----------------------------------------------------------------------------
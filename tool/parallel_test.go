@@ -0,0 +1,253 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tool
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("XGOROOT") == "" {
+		dir, _ := os.Getwd()
+		os.Setenv("XGOROOT", filepath.Dir(dir))
+	}
+	// manifests and overlays are saved in a temporary user cache directory,
+	// but not the build cache of go
+	if os.Getenv("GOCACHE") == "" {
+		if out, err := exec.Command("go", "env", "GOCACHE").Output(); err == nil {
+			os.Setenv("GOCACHE", strings.TrimSpace(string(out)))
+		}
+	}
+	cacheDir, err := os.MkdirTemp("", "xgo-tool-test")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CACHE_HOME", cacheDir)
+	os.Setenv("HOME", cacheDir) // for darwin
+	code := m.Run()
+	os.RemoveAll(cacheDir)
+	os.Exit(code)
+}
+
+const testGoMod = "module example.com/foo\n\ngo 1.18\n"
+
+// testModule is a module of packages importing each other: d imports c, c
+// imports a and b, b imports a, and the external test package of b imports c.
+var testModule = map[string]string{
+	"go.mod":        testGoMod,
+	"a/a.xgo":       "package a\n\nfunc A() int { return 1 }\n",
+	"b/b.xgo":       "package b\n\nimport \"example.com/foo/a\"\n\nfunc B() int { return a.A() + 1 }\n",
+	"b/b_test.xgo":  "package b\n\nfunc testB() int { return B() }\n",
+	"b/bx_test.xgo": "package b_test\n\nimport (\n\t\"testing\"\n\n\t\"example.com/foo/b\"\n\t\"example.com/foo/c\"\n)\n\nfunc TestB(t *testing.T) {\n\tif b.B() != c.C()-3 {\n\t\tt.Fatal(b.B())\n\t}\n}\n",
+	"c/c.xgo":       "package c\n\nimport (\n\t\"example.com/foo/a\"\n\t\"example.com/foo/b\"\n)\n\nfunc C() int { return a.A() + b.B() + 2 }\n",
+	"d/d.xgo":       "package d\n\nimport \"example.com/foo/c\"\n\nfunc D() int { return c.C() * 2 }\n",
+	"e/e.go":        "package e\n\nimport \"example.com/foo/a\"\n\nvar E = a.A()\n",
+	"f/f.xgo":       "package f\n\nfunc F() int { return 6 }\n",
+	"_skip/s.xgo":   "package s\n\nfunc S() int { return undefined }\n",
+}
+
+func writeTestFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// generated returns the Go files generated under root, as name => content.
+func generated(t *testing.T, root string) map[string]string {
+	t.Helper()
+	ret := make(map[string]string)
+	filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err == nil && strings.HasPrefix(fi.Name(), "xgo_autogen") {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rel, _ := filepath.Rel(root, path)
+			ret[filepath.ToSlash(rel)] = strings.ReplaceAll(string(b), root, "$ROOT")
+		}
+		return nil
+	})
+	return ret
+}
+
+func keys(m map[string]string) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func genGoTree(t *testing.T, root string, parallel int, genTestPkg bool) (map[string]string, error) {
+	t.Helper()
+	conf, err := NewDefaultConf(root, ConfFlagNoCacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Parallel = parallel
+	_, _, err = GenGo(root+"/...", conf, genTestPkg)
+	return generated(t, root), err
+}
+
+func TestGenGoParallel(t *testing.T) {
+	for _, genTestPkg := range []bool{false, true} {
+		serial, parallel := t.TempDir(), t.TempDir()
+		writeTestFiles(t, serial, testModule)
+		writeTestFiles(t, parallel, testModule)
+		want, err := genGoTree(t, serial, 1, genTestPkg)
+		if err != nil {
+			t.Fatal("GenGo:", err)
+		}
+		got, err := genGoTree(t, parallel, 8, genTestPkg)
+		if err != nil {
+			t.Fatal("GenGo -p 8:", err)
+		}
+		if !reflect.DeepEqual(keys(got), keys(want)) {
+			t.Fatalf("genTestPkg=%v: generated %v, want %v", genTestPkg, keys(got), keys(want))
+		}
+		for name, data := range want {
+			if got[name] != data {
+				t.Errorf("genTestPkg=%v: %s:\n%s\nwant:\n%s", genTestPkg, name, got[name], data)
+			}
+		}
+		if _, ok := got["b/xgo_autogen2_test.go"]; ok != genTestPkg {
+			t.Errorf("genTestPkg=%v: external test package generated: %v", genTestPkg, ok)
+		}
+	}
+}
+
+// errorLines returns the lines of err which aren't of the errors stack.
+func errorLines(err error) (ret []string) {
+	for _, line := range strings.Split(err.Error(), "\n") {
+		if strings.HasSuffix(line, ".xgo") || strings.Contains(line, ".xgo:") && !strings.HasPrefix(line, "\t") {
+			ret = append(ret, line)
+		}
+	}
+	return
+}
+
+func TestGenGoParallelErrors(t *testing.T) {
+	for _, genTestPkg := range []bool{false, true} {
+		serial, parallel := t.TempDir(), t.TempDir()
+		for _, root := range []string{serial, parallel} {
+			writeTestFiles(t, root, testModule)
+			writeTestFiles(t, root, map[string]string{
+				"a/a.xgo": "package a\n\nfunc A() int { return undefinedA }\n",
+				"f/f.xgo": "package f\n\nfunc F() int { return undefinedF }\n",
+			})
+		}
+		_, want := genGoTree(t, serial, 1, genTestPkg)
+		_, got := genGoTree(t, parallel, 8, genTestPkg)
+		if want == nil || got == nil {
+			t.Fatalf("genTestPkg=%v: GenGo: %v, %v", genTestPkg, want, got)
+		}
+		// errors are reported in path order
+		if w, g := errorLines(want), errorLines(got); !reflect.DeepEqual(g, w) {
+			t.Errorf("genTestPkg=%v: errors:\n%s\nwant:\n%s", genTestPkg, strings.Join(g, "\n"), strings.Join(w, "\n"))
+		}
+	}
+}
+
+func TestLockDir(t *testing.T) {
+	conf, err := NewDefaultConf(".", ConfFlagNoCacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imp := conf.Importer
+	unlock := imp.lockDir(".")
+	locked := make(chan bool)
+	go func() {
+		abs, _ := filepath.Abs(".")
+		unlock := imp.lockDir(abs) // the same directory
+		locked <- true
+		unlock()
+	}()
+	select {
+	case <-locked:
+		t.Fatal("lockDir: a directory is locked twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	<-locked
+}
+
+func TestGenGoParallelImportCycle(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, map[string]string{
+		"go.mod":  testGoMod,
+		"a/a.xgo": "package a\n\nimport \"example.com/foo/b\"\n\nfunc A() int { return b.B() }\n",
+		"b/b.xgo": "package b\n\nimport \"example.com/foo/c\"\n\nfunc B() int { return c.C() }\n",
+		"c/c.xgo": "package c\n\nimport \"example.com/foo/b\"\n\nfunc C() int { return b.B() }\n",
+	})
+	done := make(chan error, 1)
+	go func() {
+		_, err := genGoTree(t, root, 8, false)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrImportCycle) {
+			t.Fatal("GenGo:", err)
+		}
+		const want = "example.com/foo/b -> example.com/foo/c -> example.com/foo/b"
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("GenGo: %v, want a cycle %s", err, want)
+		}
+	case <-time.After(time.Minute):
+		t.Fatal("GenGo: deadlock")
+	}
+}
+
+func TestImporterInitXGoPkg(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, map[string]string{
+		"go.mod": testGoMod,
+		"g/g.go": "package g\n\nconst GopPackage = true\n\nfunc G() int { return 1 }\n",
+	})
+	conf := newTestConf(t, root, 0)
+	imp := conf.Importer
+	pkg, err := imp.Import("example.com/foo/g")
+	if err != nil {
+		t.Fatal("Import:", err)
+	}
+	if pkg.Scope().Lookup(xgoPkgInit) != nil {
+		t.Fatal("Import: initialized without setParallel")
+	}
+	imp.setParallel()
+	if pkg, err = imp.Import("example.com/foo/g"); err != nil {
+		t.Fatal("Import:", err)
+	}
+	if pkg.Scope().Lookup(xgoPkgInit) == nil {
+		t.Fatal("Import: not initialized after setParallel")
+	}
+}
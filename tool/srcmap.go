@@ -0,0 +1,454 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tool
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goplus/gogen"
+	"github.com/goplus/xgo/cl"
+	"github.com/goplus/xgo/token"
+	"github.com/goplus/xgo/x/gocmd"
+)
+
+// -----------------------------------------------------------------------------
+
+// SourceMap maps the statements, functions, calls, selectors and identifiers
+// of a generated Go file to the XGo code they are generated from. See
+// Config.SourceMap.
+type SourceMap struct {
+	File     string    `json:"file"`     // the generated Go file
+	Sources  []string  `json:"sources"`  // the XGo files
+	Segments []Segment `json:"segments"` // sorted by Go.Start
+}
+
+// Segment maps a span of the generated Go file to a span of an XGo file.
+type Segment struct {
+	Go     Span `json:"go"`
+	Source int  `json:"src"` // index of the XGo file in SourceMap.Sources
+	XGo    Span `json:"xgo"`
+}
+
+// Span represents the range [Start, End) of a file.
+type Span struct {
+	Start Pos `json:"start"`
+	End   Pos `json:"end"`
+}
+
+// Pos represents a position of a file. Line and Col are 1-based, and Col
+// counts bytes.
+type Pos struct {
+	Line int `json:"line"`
+	Col  int `json:"col"`
+}
+
+func (p Pos) before(q Pos) bool {
+	return p.Line < q.Line || p.Line == q.Line && p.Col < q.Col
+}
+
+// contains reports whether pos is in the span. A position without column,
+// eg. of a stack frame, is in the span if its line is.
+func (p Span) contains(pos Pos) bool {
+	if pos.Col == 0 {
+		return p.Start.Line <= pos.Line && pos.Line <= p.End.Line
+	}
+	return !pos.before(p.Start) && pos.before(p.End)
+}
+
+// sourceMapFile returns the path of the source map of the generated Go file
+// gen. Source maps are saved in the user cache, never next to the files.
+func sourceMapFile(gen string) string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	gen, _ = filepath.Abs(gen)
+	h := sha256.Sum256([]byte(gen))
+	return filepath.Join(cacheDir, "xgo-build", "srcmap", base64.RawURLEncoding.EncodeToString(h[:])+".map")
+}
+
+// LoadSourceMap loads the source map of the generated Go file.
+func LoadSourceMap(goFile string) (*SourceMap, error) {
+	mapFile := sourceMapFile(goFile)
+	if mapFile == "" {
+		return nil, os.ErrNotExist
+	}
+	b, err := os.ReadFile(mapFile)
+	if err != nil {
+		return nil, err
+	}
+	ret := new(SourceMap)
+	if err = json.Unmarshal(b, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ToXGo maps a position of the generated Go file to the innermost XGo node
+// it is generated from, see mapPos. pos.Filename is ignored, and pos.Column
+// may be 0 if unknown. The Column of the result is 0 if it can't be mapped.
+func (p *SourceMap) ToXGo(pos token.Position) (ret token.Position, ok bool) {
+	at := Pos{pos.Line, pos.Column}
+	seg := p.innermost(func(seg *Segment) *Span {
+		return &seg.Go
+	}, at)
+	if seg == nil {
+		return
+	}
+	to := mapPos(seg.Go, seg.XGo, at)
+	return token.Position{Filename: p.Sources[seg.Source], Line: to.Line, Column: to.Col}, true
+}
+
+// ToGo maps a position of an XGo file to the Go code generated from the
+// innermost node containing it, see mapPos. pos.Column may be 0 if unknown.
+// The Column of the result is 0 if it can't be mapped.
+func (p *SourceMap) ToGo(pos token.Position) (ret token.Position, ok bool) {
+	src := p.source(pos.Filename)
+	if src < 0 {
+		return
+	}
+	at := Pos{pos.Line, pos.Column}
+	seg := p.innermost(func(seg *Segment) *Span {
+		if seg.Source != src {
+			return nil
+		}
+		return &seg.XGo
+	}, at)
+	if seg == nil {
+		return
+	}
+	to := mapPos(seg.XGo, seg.Go, at)
+	return token.Position{Filename: p.File, Line: to.Line, Column: to.Col}, true
+}
+
+// mapPos maps pos in the span from to the span to. pos keeps its offset from
+// the start of the span if it is on the first line of from and the result is
+// still in to, otherwise only the first line of to is known, and the column
+// is 0.
+func mapPos(from, to Span, pos Pos) Pos {
+	if pos.Col > 0 && pos.Line == from.Start.Line {
+		ret := Pos{to.Start.Line, to.Start.Col + pos.Col - from.Start.Col}
+		if ret == to.Start || to.contains(ret) {
+			return ret
+		}
+	}
+	return Pos{to.Start.Line, 0}
+}
+
+func (p *SourceMap) source(file string) int {
+	file = filepath.Clean(file)
+	for i, src := range p.Sources {
+		if src == file {
+			return i
+		}
+	}
+	if !filepath.IsAbs(file) { // match by the path relative to the module root
+		for i, src := range p.Sources {
+			if strings.HasSuffix(src, string(filepath.Separator)+file) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// innermost returns the segment of the smallest span containing pos. span
+// returns the span of a segment to check, nil to skip it.
+func (p *SourceMap) innermost(span func(seg *Segment) *Span, pos Pos) (ret *Segment) {
+	var best *Span
+	for i := range p.Segments {
+		seg := &p.Segments[i]
+		if s := span(seg); s != nil && s.contains(pos) {
+			if best == nil || !s.Start.before(best.Start) && !best.End.before(s.End) {
+				ret, best = seg, s
+			}
+		}
+	}
+	return
+}
+
+// newPosMapper returns a gocmd.PosMapper mapping positions of the Go files
+// generated by conf to XGo positions by their source maps. It also corrects
+// the paths of XGo files the go command resolves wrongly, see xgoFile. Any
+// other position is kept.
+func (conf *Config) newPosMapper() gocmd.PosMapper {
+	var maps sync.Map  // Go file => *SourceMap, nil if none
+	var files sync.Map // file => the XGo file it stands for, "" if none
+	sourceMap := func(file string) *SourceMap {
+		v, ok := maps.Load(file)
+		if !ok {
+			gen := file
+			if ov := conf.Overlay; ov != nil {
+				if f, ok := ov.genFile(file); ok {
+					gen = f
+				}
+			}
+			m, err := LoadSourceMap(gen)
+			if err != nil {
+				m = nil
+			}
+			v, _ = maps.LoadOrStore(file, m)
+		}
+		return v.(*SourceMap)
+	}
+	return func(pos gotoken.Position) (gotoken.Position, bool) {
+		file := pos.Filename
+		if !strings.HasSuffix(file, ".go") {
+			v, ok := files.Load(file)
+			if !ok {
+				v, _ = files.LoadOrStore(file, conf.xgoFile(file, sourceMap))
+			}
+			if pos.Filename = v.(string); pos.Filename == "" {
+				return pos, false
+			}
+			return pos, true
+		}
+		if m := sourceMap(file); m != nil {
+			return m.ToXGo(pos)
+		}
+		return pos, false
+	}
+}
+
+// xgoFile returns the XGo file that the nonexistent file stands for, "" if
+// none. File line comments name XGo files relative to the module root, see
+// cl.Config.RelativeBase, but the go command takes them as relative to the
+// directory of the generated Go file. So the XGo file is root/rel where file
+// is dir/rel, and it's a source of a Go file generated in dir.
+func (conf *Config) xgoFile(file string, sourceMap func(goFile string) *SourceMap) string {
+	root := conf.Mod.Root()
+	if root == "" || !strings.HasPrefix(file, root+string(filepath.Separator)) {
+		return ""
+	}
+	if _, err := os.Lstat(file); err == nil {
+		return ""
+	}
+	rel := file[len(root)+1:]
+	for {
+		i := strings.IndexByte(rel, filepath.Separator)
+		if i < 0 {
+			return ""
+		}
+		rel = rel[i+1:]
+		dir, ret := file[:len(file)-len(rel)-1], filepath.Join(root, rel)
+		for _, fname := range genFiles {
+			if m := sourceMap(filepath.Join(dir, fname)); m != nil && m.hasSource(ret) {
+				return ret
+			}
+		}
+	}
+}
+
+func (p *SourceMap) hasSource(file string) bool {
+	for _, src := range p.Sources {
+		if src == file {
+			return true
+		}
+	}
+	return false
+}
+
+func fileExists(file string) bool {
+	fi, err := os.Stat(file)
+	return err == nil && !fi.IsDir()
+}
+
+// -----------------------------------------------------------------------------
+
+// nodeSpans collects the XGo spans of the nodes of a package by the file
+// line comments of their Go code, or their Go expressions, see
+// cl.Config.NodeSpan.
+type nodeSpans struct {
+	spans map[goast.Node]nodeSpan
+}
+
+type nodeSpan struct {
+	file string // the XGo file
+	span Span
+}
+
+// pkgNodeSpans holds the nodeSpans of the packages loaded with
+// Config.SourceMap set, until their Go files are written.
+var pkgNodeSpans sync.Map // *gogen.Package => *nodeSpans
+
+// setNodeSpans makes clConf collect the XGo spans of nodes if conf.SourceMap
+// is set.
+func setNodeSpans(clConf *cl.Config, conf *Config) *nodeSpans {
+	if !conf.SourceMap {
+		return nil
+	}
+	spans := &nodeSpans{spans: make(map[goast.Node]nodeSpan)}
+	clConf.NodeSpan = spans.add
+	return spans
+}
+
+// keepNodeSpans keeps spans for the packages loaded with them until their Go
+// files are written, see writeSourceMap and forgetNodeSpans.
+func keepNodeSpans(spans *nodeSpans, pkgs ...*gogen.Package) {
+	if spans != nil {
+		for _, pkg := range pkgs {
+			if pkg != nil {
+				pkgNodeSpans.Store(pkg, spans)
+			}
+		}
+	}
+}
+
+func forgetNodeSpans(pkgs ...*gogen.Package) {
+	for _, pkg := range pkgs {
+		if pkg != nil {
+			pkgNodeSpans.Delete(pkg)
+		}
+	}
+}
+
+func (p *nodeSpans) add(node goast.Node, start, end token.Position) {
+	file, err := filepath.Abs(start.Filename)
+	if err != nil || !start.IsValid() || !end.IsValid() {
+		return
+	}
+	p.spans[node] = nodeSpan{file, Span{Pos{start.Line, start.Column}, Pos{end.Line, end.Column}}}
+}
+
+// sourceMap returns the source map of the generated Go file fname of pkg,
+// whose content is src. The nodes of the printed AST of the file which may be
+// mapped are the same as the ones parsed from src, in the same order, so the
+// Go spans of the nodes are found by parsing src.
+func (p *nodeSpans) sourceMap(pkg *gogen.Package, fname []string, file string, src []byte) *SourceMap {
+	commented := pkg.CommentedASTFile(fname...)
+	if commented == nil {
+		return nil
+	}
+	fset := gotoken.NewFileSet()
+	f, err := goparser.ParseFile(fset, file, src, goparser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+	root, ok := commented.Node.(goast.Node)
+	if !ok {
+		return nil
+	}
+	printed, parsed := mappedNodes(root), mappedNodes(f)
+	if len(printed) != len(parsed) {
+		return nil
+	}
+	ret := &SourceMap{File: file}
+	sources := make(map[string]int)
+	for i, node := range printed {
+		if reflect.TypeOf(node) != reflect.TypeOf(parsed[i]) {
+			return nil
+		}
+		var key goast.Node // see cl.Config.NodeSpan
+		switch node := node.(type) {
+		case *goast.FuncDecl:
+			if node.Doc != nil {
+				key = node.Doc
+			}
+		case goast.Stmt:
+			if comments := commented.CommentedStmts[node]; comments != nil {
+				key = comments
+			}
+		default:
+			key = node
+		}
+		span, ok := p.spans[key]
+		if !ok || key == nil {
+			continue
+		}
+		idx, ok := sources[span.file]
+		if !ok {
+			idx = len(ret.Sources)
+			sources[span.file] = idx
+			ret.Sources = append(ret.Sources, span.file)
+		}
+		goStart, goEnd := fset.PositionFor(parsed[i].Pos(), false), fset.PositionFor(parsed[i].End(), false)
+		ret.Segments = append(ret.Segments, Segment{
+			Go:     Span{Pos{goStart.Line, goStart.Column}, Pos{goEnd.Line, goEnd.Column}},
+			Source: idx,
+			XGo:    span.span,
+		})
+	}
+	sort.SliceStable(ret.Segments, func(i, j int) bool {
+		return ret.Segments[i].Go.Start.before(ret.Segments[j].Go.Start)
+	})
+	return ret
+}
+
+// mappedNodes returns the statements, functions, calls, selectors and
+// identifiers of root in depth-first order, which are the nodes a source map
+// may map.
+func mappedNodes(root goast.Node) (ret []goast.Node) {
+	goast.Inspect(root, func(node goast.Node) bool {
+		switch node.(type) {
+		case goast.Stmt, *goast.FuncDecl, *goast.CallExpr, *goast.SelectorExpr, *goast.Ident:
+			ret = append(ret, node)
+		}
+		return true
+	})
+	return
+}
+
+// writeSourceMap writes the source map of the generated Go file fname of
+// pkg, whose content is src, to sourceMapFile(gen) if pkg is loaded with
+// Config.SourceMap set, or else removes the stale one. file is the path of
+// the generated Go file seen by the go command.
+func writeSourceMap(pkg *gogen.Package, fname []string, gen, file string, src []byte) error {
+	mapFile := sourceMapFile(gen)
+	if mapFile == "" {
+		return nil
+	}
+	v, ok := pkgNodeSpans.Load(pkg)
+	if !ok {
+		os.Remove(mapFile)
+		return nil
+	}
+	file, _ = filepath.Abs(file)
+	m := v.(*nodeSpans).sourceMap(pkg, fname, file, src)
+	if m == nil {
+		os.Remove(mapFile)
+		return nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if old, err := os.ReadFile(mapFile); err == nil && bytes.Equal(old, b) {
+		now := time.Now()
+		return os.Chtimes(mapFile, now, now)
+	}
+	dir := filepath.Dir(mapFile)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	defer trimCacheDir(dir, time.Now())
+	return os.WriteFile(mapFile, b, 0666)
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,283 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tool
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goplus/xgo/token"
+)
+
+// genSourceMap generates the Go files of a package of files with source maps,
+// and returns the source map of xgo_autogen.go with its content.
+func genSourceMap(t *testing.T, files map[string]string, flags ConfFlags) (root string, m *SourceMap, src string) {
+	t.Helper()
+	root = t.TempDir()
+	writeTestFiles(t, root, map[string]string{"go.mod": testGoMod})
+	writeTestFiles(t, root, files)
+	conf := newTestConf(t, root, flags|ConfFlagSourceMap)
+	if _, _, err := GenGo(root, conf, false); err != nil {
+		t.Fatal("GenGo:", err)
+	}
+	file := filepath.Join(root, autoGenFile)
+	m, err := LoadSourceMap(file)
+	if err != nil {
+		t.Fatal("LoadSourceMap:", err)
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root, m, string(b)
+}
+
+// codeAt returns the code of src starting at pos.
+func codeAt(src string, pos token.Position) string {
+	lines := strings.Split(src, "\n")
+	if pos.Line < 1 || pos.Line > len(lines) || pos.Column < 1 || pos.Column > len(lines[pos.Line-1])+1 {
+		return ""
+	}
+	return lines[pos.Line-1][pos.Column-1:]
+}
+
+func TestSourceMapRoundTrip(t *testing.T) {
+	const lib = "func double(x int) int {\n\treturn x * 2\n}\n"
+	tests := []struct {
+		name      string
+		file      string // the XGo file of the position
+		line, col int    // the start of an XGo statement or function
+		goCode    string // the Go code generated from it
+	}{
+		{"function", "main.xgo", 1, 1, "func greet(name string) {"},
+		{"nested statement", "main.xgo", 2, 2, "msg := \"hi \" + name"},
+		{"statements of a line", "main.xgo", 2, 23, "fmt.Println(msg)"},
+		{"statement", "main.xgo", 5, 1, "fmt.Println(double(1))"},
+		{"loop", "main.xgo", 7, 1, "for"},
+		{"loop body", "main.xgo", 8, 2, "fmt.Println(x)"},
+		{"another file", "lib.xgo", 2, 2, "return x * 2"},
+	}
+	root, m, src := genSourceMap(t, map[string]string{
+		"main.xgo": "func greet(name string) {\n\tmsg := \"hi \" + name; echo msg\n}\n\n" +
+			"echo double(1)\n\nfor x in [1, 2] {\n\techo x\n}\n",
+		"lib.xgo": lib,
+	}, 0)
+	for _, tt := range tests {
+		xgoPos := token.Position{Filename: filepath.Join(root, tt.file), Line: tt.line, Column: tt.col}
+		goPos, ok := m.ToGo(xgoPos)
+		if !ok {
+			t.Errorf("%s: ToGo(%v): not found", tt.name, xgoPos)
+			continue
+		}
+		if code := codeAt(src, goPos); !strings.HasPrefix(code, tt.goCode) {
+			t.Errorf("%s: ToGo(%v) = %v: %q, want %q", tt.name, xgoPos, goPos, code, tt.goCode)
+		}
+		if back, ok := m.ToXGo(goPos); !ok || back != xgoPos {
+			t.Errorf("%s: ToXGo(%v) = %v, %v, want %v", tt.name, goPos, back, ok, xgoPos)
+		}
+		goPos.Column = 0 // eg. a position of a stack frame
+		if back, ok := m.ToXGo(goPos); !ok || back.Filename != xgoPos.Filename || back.Line != xgoPos.Line {
+			t.Errorf("%s: ToXGo(%v) = %v, %v, want line %d", tt.name, goPos, back, ok, xgoPos.Line)
+		}
+	}
+
+	// positions not mapped
+	if pos, ok := m.ToXGo(token.Position{Line: 1, Column: 1}); ok {
+		t.Error("ToXGo: the header is mapped to", pos)
+	}
+	if pos, ok := m.ToGo(token.Position{Filename: filepath.Join(root, "none.xgo"), Line: 3, Column: 1}); ok {
+		t.Error("ToGo: a file not generated is mapped to", pos)
+	}
+	if pos, ok := m.ToGo(token.Position{Filename: "lib.xgo", Line: 2, Column: 2}); !ok || !strings.HasPrefix(codeAt(src, pos), "return x * 2") {
+		t.Error("ToGo: a relative file name isn't mapped:", pos, ok)
+	}
+}
+
+// goPosOf returns the position of the first occurrence of sub in the first
+// line of src containing code.
+func goPosOf(t *testing.T, src, code, sub string) token.Position {
+	t.Helper()
+	for i, line := range strings.Split(src, "\n") {
+		if j := strings.Index(line, code); j >= 0 {
+			if k := strings.Index(line[j:], sub); k >= 0 {
+				return token.Position{Line: i + 1, Column: j + k + 1}
+			}
+		}
+	}
+	t.Fatalf("%q not found in the Go code:\n%s", code, src)
+	return token.Position{}
+}
+
+func TestSourceMapColumns(t *testing.T) {
+	root, m, src := genSourceMap(t, map[string]string{
+		"main.xgo": "func double(x int) int {\n\treturn x * 2\n}\n\n" +
+			"echo  double(1)\n\na, b := 1, 2\necho a\n",
+	}, 0)
+	file := filepath.Join(root, "main.xgo")
+	tests := []struct {
+		name      string
+		code, sub string // sub in the Go code
+		line, col int    // the XGo position, col 0 if unknown
+	}{
+		{"call", "fmt.Println(double(1))", "double", 5, 7},
+		{"argument", "fmt.Println(double(1))", "1))", 5, 14},
+		{"identifier", "return x * 2", "x", 2, 9},
+		{"binary expression", "return x * 2", "2", 2, 13},
+		{"assignment", "a, b := 1, 2", "b", 7, 4},
+		{"signature", "func double", "int", 1, 15},
+	}
+	for _, tt := range tests {
+		goPos := goPosOf(t, src, tt.code, tt.sub)
+		got, ok := m.ToXGo(goPos)
+		if want := (token.Position{Filename: file, Line: tt.line, Column: tt.col}); !ok || got != want {
+			t.Errorf("%s: ToXGo(%v) = %v, %v, want %v", tt.name, goPos, got, ok, want)
+		}
+	}
+
+	// the column of a line after the start of a function isn't known
+	goPos := goPosOf(t, src, "return x * 2", "return")
+	goPos.Line, goPos.Column = goPos.Line+1, 1 // the closing brace
+	if got, ok := m.ToXGo(goPos); !ok || got != (token.Position{Filename: file, Line: 1}) {
+		t.Errorf("ToXGo(%v) = %v, %v, want line 1 without column", goPos, got, ok)
+	}
+}
+
+func TestSourceMapSegments(t *testing.T) {
+	_, m, src := genSourceMap(t, map[string]string{
+		"main.xgo": "import \"strconv\"\n\nfunc atoi(s string) (int, error) {\n" +
+			"\treturn strconv.Atoi(s)?, nil\n}\n\necho atoi(\"1\")\n",
+	}, 0)
+	// every segment maps the start of Go code to the start of an XGo node
+	for _, seg := range m.Segments {
+		goPos := token.Position{Line: seg.Go.Start.Line, Column: seg.Go.Start.Col}
+		if codeAt(src, goPos) == "" {
+			t.Errorf("segment %v: no Go code", seg)
+		}
+		if seg.XGo.End.before(seg.XGo.Start) || seg.Go.End.before(seg.Go.Start) {
+			t.Errorf("segment %v: bad span", seg)
+		}
+	}
+	// Go statements generated from the same XGo statement map back to it
+	pos := token.Position{Filename: m.Sources[0], Line: 4, Column: 2}
+	n := 0
+	for _, seg := range m.Segments {
+		if seg.XGo.Start == (Pos{pos.Line, pos.Column}) {
+			n++
+		}
+	}
+	if n < 2 {
+		t.Errorf("error wrap: %d Go statements mapped, want more", n)
+	}
+}
+
+func TestSourceMapOff(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, map[string]string{"go.mod": testGoMod, "main.xgo": "echo 1\n"})
+	conf := newTestConf(t, root, 0)
+	if _, _, err := GenGo(root, conf, false); err != nil {
+		t.Fatal("GenGo:", err)
+	}
+	if _, err := LoadSourceMap(filepath.Join(root, autoGenFile)); err == nil {
+		t.Error("a source map is saved")
+	}
+	if conf.NewGoCmdConf().MapPos != nil {
+		t.Error("NewGoCmdConf: positions mapped")
+	}
+}
+
+func TestPosMapper(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, map[string]string{
+		"go.mod":       testGoMod,
+		"sub/a.xgo":    "echo 1\n\necho 2\n",
+		"sub/data.txt": "data\n",
+		"main.go":      "package main\n",
+	})
+	conf := newTestConf(t, root, ConfFlagSourceMap)
+	dir := filepath.Join(root, "sub")
+	if _, _, err := GenGo(dir, conf, false); err != nil {
+		t.Fatal("GenGo:", err)
+	}
+	m, err := LoadSourceMap(filepath.Join(dir, autoGenFile))
+	if err != nil {
+		t.Fatal("LoadSourceMap:", err)
+	}
+	seg := m.Segments[len(m.Segments)-1]
+	xgoFile := filepath.Join(dir, "a.xgo")
+	tests := []struct {
+		name string
+		pos  token.Position
+		want token.Position
+		ok   bool
+	}{
+		{"generated Go file",
+			token.Position{Filename: filepath.Join(dir, autoGenFile), Line: seg.Go.Start.Line, Column: seg.Go.Start.Col},
+			token.Position{Filename: xgoFile, Line: 3, Column: 1}, true},
+		{"XGo file resolved wrongly",
+			token.Position{Filename: filepath.Join(dir, "sub", "a.xgo"), Line: 3, Column: 6},
+			token.Position{Filename: xgoFile, Line: 3, Column: 6}, true},
+		{"Go file without source map",
+			token.Position{Filename: filepath.Join(root, "main.go"), Line: 1, Column: 1},
+			token.Position{Filename: filepath.Join(root, "main.go"), Line: 1, Column: 1}, false},
+		{"not a source",
+			token.Position{Filename: filepath.Join(dir, "sub", "data.txt"), Line: 1},
+			token.Position{Filename: filepath.Join(dir, "sub", "data.txt"), Line: 1}, false},
+		{"not generated in the directory",
+			token.Position{Filename: filepath.Join(root, "other", "sub", "a.xgo"), Line: 1},
+			token.Position{Filename: filepath.Join(root, "other", "sub", "a.xgo"), Line: 1}, false},
+	}
+	mapPos := conf.newPosMapper()
+	for _, tt := range tests {
+		got, ok := mapPos(tt.pos)
+		if ok != tt.ok || ok && got != tt.want {
+			t.Errorf("%s: mapPos(%v) = %v, %v, want %v, %v", tt.name, tt.pos, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSourceMapCache(t *testing.T) {
+	root, _, _ := genSourceMap(t, map[string]string{"main.xgo": "echo 1\n"}, 0)
+	filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err == nil && strings.HasSuffix(path, ".map") {
+			t.Error("source map saved into the source directory:", path)
+		}
+		return nil
+	})
+	file := filepath.Join(root, autoGenFile)
+	mapFile := sourceMapFile(file)
+	if _, err := os.Stat(mapFile); err != nil {
+		t.Fatal("source map not in the cache:", err)
+	}
+
+	// a source map trimmed from the cache is generated again
+	conf := newTestConf(t, root, ConfFlagSourceMap)
+	if !upToDate(root, conf, false) {
+		t.Fatal("upToDate: false")
+	}
+	os.Remove(mapFile)
+	if upToDate(root, conf, false) {
+		t.Fatal("upToDate: true without the source map")
+	}
+	if _, _, err := GenGo(root, conf, false); err != nil {
+		t.Fatal("GenGo:", err)
+	}
+	if _, err := LoadSourceMap(file); err != nil {
+		t.Fatal("LoadSourceMap:", err)
+	}
+}
@@ -36,6 +36,14 @@ var printFuncs = [][2]string{
 	{"Sprintln", "sprintln"},
 }
 
+// FmtToBuiltin rewrites the function of call v, which is a print function of
+// package fmt, eg. fmt.Println, to the XGo builtin, eg. echo. The caller must
+// make sure the selector v.Fun refers to package fmt. It doesn't modify v.Fun.
+func FmtToBuiltin(v *ast.CallExpr) bool {
+	sel, ok := v.Fun.(*ast.SelectorExpr)
+	return ok && fmtToBuiltin(&importCtx{pkgPath: "fmt"}, sel.Sel, &v.Fun)
+}
+
 func fmtToBuiltin(ctx *importCtx, sel *ast.Ident, ref *ast.Expr) bool {
 	if ctx.pkgPath == "fmt" {
 		for _, fns := range printFuncs {
@@ -54,6 +62,12 @@ func fmtToBuiltin(ctx *importCtx, sel *ast.Ident, ref *ast.Expr) bool {
 
 // -----------------------------------------------------------------------------
 
+// CommandStyle makes call v, which is a statement, in command style, eg.
+// echo(x) to echo x.
+func CommandStyle(v *ast.CallExpr) {
+	commandStyleFirst(v)
+}
+
 func commandStyleFirst(v *ast.CallExpr) {
 	switch v.Fun.(type) {
 	case *ast.Ident, *ast.SelectorExpr:
@@ -74,6 +88,14 @@ func fncallStartingLowerCase(v *ast.CallExpr) {
 
 // -----------------------------------------------------------------------------
 
+// FuncLitToLambda converts function literal v to a lambda expression, eg.
+// func(x int) int { return x * x } to x => x * x. It returns nil if v has
+// named results. It doesn't modify v.
+func FuncLitToLambda(v *ast.FuncLit) (ret ast.Expr) {
+	funcLitToLambdaExpr(v, &ret)
+	return
+}
+
 func funcLitToLambdaExpr(v *ast.FuncLit, ret *ast.Expr) {
 	nres, named := checkResult(v.Type.Results)
 	if len(named) > 0 {
@@ -111,3 +133,75 @@ func checkResult(v *ast.FieldList) (nres int, named []*ast.Ident) {
 }
 
 // -----------------------------------------------------------------------------
+
+// ErrReturnToErrWrap converts an assignment and the following if statement
+//
+//	x, err := f()
+//	if err != nil {
+//		return nil, err
+//	}
+//
+// to x := f()?, and returns nil if they don't match the pattern. The results
+// returned before err must be zero values. The caller must make sure err isn't
+// used elsewhere. It doesn't modify the statements.
+func ErrReturnToErrWrap(assign *ast.AssignStmt, ifStmt *ast.IfStmt) ast.Stmt {
+	n := len(assign.Lhs)
+	if len(assign.Rhs) != 1 || ifStmt.Init != nil || ifStmt.Else != nil {
+		return nil
+	}
+	call, ok := assign.Rhs[0].(*ast.CallExpr)
+	if !ok {
+		return nil
+	}
+	errVar, ok := assign.Lhs[n-1].(*ast.Ident)
+	if !ok || errVar.Name == "_" || !isErrNotNil(ifStmt.Cond, errVar.Name) {
+		return nil
+	}
+	if len(ifStmt.Body.List) != 1 {
+		return nil
+	}
+	ret, ok := ifStmt.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) == 0 || !isIdent(ret.Results[len(ret.Results)-1], errVar.Name) {
+		return nil
+	}
+	for _, v := range ret.Results[:len(ret.Results)-1] {
+		if !isZeroValue(v) {
+			return nil
+		}
+	}
+	x := &ast.ErrWrapExpr{X: call, Tok: token.QUESTION, TokPos: call.End()}
+	lhs := assign.Lhs[:n-1]
+	for _, v := range lhs {
+		if !isIdent(v, "_") {
+			return &ast.AssignStmt{Lhs: lhs, TokPos: assign.TokPos, Tok: assign.Tok, Rhs: []ast.Expr{x}}
+		}
+	}
+	return &ast.ExprStmt{X: x}
+}
+
+func isErrNotNil(cond ast.Expr, name string) bool {
+	if v, ok := cond.(*ast.BinaryExpr); ok && v.Op == token.NEQ {
+		return isIdent(v.X, name) && isIdent(v.Y, "nil")
+	}
+	return false
+}
+
+func isIdent(v ast.Expr, name string) bool {
+	id, ok := v.(*ast.Ident)
+	return ok && id.Name == name
+}
+
+func isZeroValue(v ast.Expr) bool {
+	switch v := v.(type) {
+	case *ast.Ident:
+		return v.Name == "nil" || v.Name == "false"
+	case *ast.BasicLit:
+		switch v.Value {
+		case "0", `""`, "``", "0.0":
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------------------------------------------
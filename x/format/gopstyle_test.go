@@ -17,7 +17,13 @@
 package format
 
 import (
+	"bytes"
 	"testing"
+
+	"github.com/goplus/xgo/ast"
+	"github.com/goplus/xgo/format"
+	"github.com/goplus/xgo/parser"
+	"github.com/goplus/xgo/token"
 )
 
 func testFormat(t *testing.T, name string, src, expect string) {
@@ -533,3 +539,34 @@ demo4 100, func(n1, n2 int) (a, b int) {
 }, 100
 `)
 }
+
+func TestErrReturnToErrWrap(t *testing.T) {
+	test := func(name, src, expect string) {
+		t.Run(name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, name, "func _() {\n"+src+"\n}", 0)
+			if err != nil {
+				t.Fatal("parser.ParseFile:", err)
+			}
+			list := f.Decls[0].(*ast.FuncDecl).Body.List
+			var ret string
+			if stmt := ErrReturnToErrWrap(list[0].(*ast.AssignStmt), list[1].(*ast.IfStmt)); stmt != nil {
+				var buf bytes.Buffer
+				if err = format.Node(&buf, fset, stmt); err != nil {
+					t.Fatal("format.Node:", err)
+				}
+				ret = buf.String()
+			}
+			if ret != expect {
+				t.Fatalf("%s => Expect: %q, Got: %q\n", name, expect, ret)
+			}
+		})
+	}
+	test("return err", "err := f()\nif err != nil {\n\treturn err\n}", "f()?")
+	test("return zero", "x, err := f()\nif err != nil {\n\treturn 0, \"\", nil, err\n}", "x := f()?")
+	test("blank", "_, err := f()\nif err != nil {\n\treturn err\n}", "f()?")
+	test("not zero", "x, err := f()\nif err != nil {\n\treturn 1, err\n}", "")
+	test("wrap err", "x, err := f()\nif err != nil {\n\treturn fmt.Errorf(\"f: %w\", err)\n}", "")
+	test("else", "err := f()\nif err != nil {\n\treturn err\n} else {\n}", "")
+	test("other var", "err := f()\nif e != nil {\n\treturn e\n}", "")
+}
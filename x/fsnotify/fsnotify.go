@@ -22,6 +22,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
@@ -47,31 +48,68 @@ type FSChanged interface {
 	EntryDeleted(name string, isDir bool)
 }
 
+// FSChangedEx is implemented by an FSChanged which wants to know the kind of
+// changes: FileChangedEx is called instead of FileChanged, and EntryRenamed
+// is called for an entry renamed to another name instead of EntryDeleted.
+type FSChangedEx interface {
+	FSChanged
+	FileChangedEx(name string, created bool)
+	EntryRenamed(name string, isDir bool)
+}
+
 type Ignore = func(name string, isDir bool) bool
 
 // -----------------------------------------------------------------------------------------
 
+// Watcher watches a directory tree by inotify (or the like of the OS). If
+// inotify is unavailable or exhausted, eg. max_user_watches is reached, it
+// falls back to polling.
 type Watcher struct {
-	w *fsnotify.Watcher
+	w    *fsnotify.Watcher
+	poll *poller
 }
 
 func New() Watcher {
 	w, err := fsnotify.NewWatcher()
 	if err != nil {
-		log.Panicln("[FATAL] fsnotify.NewWatcher:", err)
+		log.Println("[WARN] fsnotify.NewWatcher:", err, "- fall back to polling")
+		w = nil
 	}
-	return Watcher{w}
+	return Watcher{w, new(poller)}
+}
+
+// NewPoller returns a Watcher which polls the file system every interval,
+// which works for file systems that send no events, eg. some network file
+// systems. Files are compared by size and mtime, and by content only if those
+// are ambiguous, eg. a file touched after it is hashed isn't changed.
+func NewPoller(interval time.Duration) Watcher {
+	return Watcher{nil, &poller{interval: interval}}
 }
 
 func (p Watcher) Run(root string, fc FSChanged, ignore Ignore) error {
+	if p.w == nil {
+		return p.poll.run(root, fc, ignore)
+	}
 	go p.watchLoop(root, fc, ignore)
-	return watchRecursive(p.w, root)
+	if err := watchRecursive(p.w, root); err != nil {
+		return p.fallback(root, fc, ignore, err)
+	}
+	return nil
+}
+
+// fallback stops inotify and starts polling when adding a watch failed.
+func (p Watcher) fallback(root string, fc FSChanged, ignore Ignore, err error) error {
+	log.Println("[WARN] fsnotify:", err, "- fall back to polling")
+	p.w.Close()
+	return p.poll.run(root, fc, ignore)
 }
 
 func (p Watcher) watchLoop(root string, fc FSChanged, ignore Ignore) {
 	const (
 		eventModify = fsnotify.Write | fsnotify.Create
+		eventRemove = fsnotify.Remove | fsnotify.Rename
 	)
+	fcEx, _ := fc.(FSChangedEx)
 	for {
 		select {
 		case event, ok := <-p.w.Events:
@@ -81,7 +119,7 @@ func (p Watcher) watchLoop(root string, fc FSChanged, ignore Ignore) {
 			if debugEvent {
 				log.Println("==> event:", event)
 			}
-			if (event.Op & fsnotify.Remove) != 0 {
+			if (event.Op & eventRemove) != 0 {
 				e := p.w.Remove(event.Name)
 				name, err := filepath.Rel(root, event.Name)
 				if err != nil {
@@ -92,6 +130,8 @@ func (p Watcher) watchLoop(root string, fc FSChanged, ignore Ignore) {
 				name = filepath.ToSlash(name)
 				if ignore != nil && ignore(name, isDir) {
 					continue
+				} else if fcEx != nil && (event.Op&fsnotify.Remove) == 0 {
+					fcEx.EntryRenamed(name, isDir)
 				} else {
 					fc.EntryDeleted(name, isDir)
 				}
@@ -107,9 +147,15 @@ func (p Watcher) watchLoop(root string, fc FSChanged, ignore Ignore) {
 					continue
 				} else if isDir {
 					if (event.Op & fsnotify.Create) != 0 {
-						watchRecursive(p.w, event.Name)
+						err := watchRecursive(p.w, event.Name)
 						fc.DirAdded(name)
+						if err != nil {
+							go p.fallback(root, fc, ignore, err)
+							return
+						}
 					}
+				} else if fcEx != nil {
+					fcEx.FileChangedEx(name, (event.Op&fsnotify.Create) != 0)
 				} else {
 					fc.FileChanged(name)
 				}
@@ -124,6 +170,9 @@ func (p Watcher) watchLoop(root string, fc FSChanged, ignore Ignore) {
 }
 
 func (p *Watcher) Close() error {
+	if p.poll != nil {
+		p.poll.close()
+	}
 	if w := p.w; w != nil {
 		p.w = nil
 		return w.Close()
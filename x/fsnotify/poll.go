@@ -0,0 +1,248 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fsnotify
+
+import (
+	"crypto/sha256"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// -----------------------------------------------------------------------------------------
+
+// DefaultPollInterval is the default interval of polling the file system.
+const DefaultPollInterval = time.Second
+
+// mtimeSlack is how long after its modification time a file may still be
+// modified without changing its mtime, because of the coarse mtime
+// granularity of some file systems.
+const mtimeSlack = 2 * time.Second
+
+type fileState struct {
+	mtime  time.Time
+	size   int64
+	hash   [sha256.Size]byte
+	hashed bool // hash is valid
+	isDir  bool
+}
+
+// sameAs reports whether a file is unchanged from its old state. Its content
+// is compared only if both states are hashed, or else its mtime is.
+func (st *fileState) sameAs(old *fileState) bool {
+	if st.isDir || old.isDir {
+		return st.isDir == old.isDir
+	}
+	if st.size != old.size {
+		return false
+	}
+	if st.hashed && old.hashed {
+		return st.hash == old.hash
+	}
+	return st.mtime.Equal(old.mtime)
+}
+
+// poller watches a directory tree by scanning it periodically.
+type poller struct {
+	interval time.Duration
+
+	mutex sync.Mutex
+	stop  chan none // nil if not running
+}
+
+type none = struct{}
+
+func (p *poller) run(root string, fc FSChanged, ignore Ignore) error {
+	files, err := p.scan(root, ignore, nil)
+	if err != nil {
+		return err
+	}
+	p.mutex.Lock()
+	if p.stop != nil {
+		p.mutex.Unlock()
+		return nil
+	}
+	stop := make(chan none)
+	p.stop = stop
+	p.mutex.Unlock()
+
+	interval := p.interval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			next, err := p.scan(root, ignore, files)
+			if err != nil {
+				log.Println("[ERROR] fsnotify poll:", err)
+				continue
+			}
+			notify(fc, files, next)
+			files = next
+		}
+	}()
+	return nil
+}
+
+func (p *poller) close() {
+	p.mutex.Lock()
+	if p.stop != nil {
+		close(p.stop)
+		p.stop = nil
+	}
+	p.mutex.Unlock()
+}
+
+// scan returns the states of entries under root, keyed by names relative to
+// root with slashes. Ignored directories are skipped. Files are compared by
+// size and mtime first, so the content of a file is hashed only if they are
+// ambiguous: the file may be modified without changing its mtime, or its mtime
+// changes without its size and its old content is hashed, eg. it is touched.
+func (p *poller) scan(root string, ignore Ignore, prev map[string]*fileState) (map[string]*fileState, error) {
+	now := time.Now()
+	files := make(map[string]*fileState)
+	err := filepath.WalkDir(root, func(entry string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if entry != root && os.IsNotExist(err) { // removed while scanning
+				return nil
+			}
+			return err
+		}
+		if entry == root {
+			return nil
+		}
+		name, _ := filepath.Rel(root, entry)
+		name = filepath.ToSlash(name)
+		isDir := d.IsDir()
+		if ignore != nil && ignore(name, isDir) {
+			if isDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isDir {
+			files[name] = &fileState{isDir: true}
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		st := &fileState{mtime: fi.ModTime(), size: fi.Size()}
+		old, ok := prev[name]
+		ok = ok && !old.isDir && old.size == st.size
+		recent := now.Sub(st.mtime) <= mtimeSlack
+		if ok && old.mtime.Equal(st.mtime) && !recent {
+			st.hash, st.hashed = old.hash, old.hashed
+		} else if recent || ok && old.hashed {
+			if st.hash, err = hashFile(entry); err != nil {
+				return nil
+			}
+			st.hashed = true
+		}
+		files[name] = st
+		return nil
+	})
+	return files, err
+}
+
+func hashFile(file string) (ret [sha256.Size]byte, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err == nil {
+		copy(ret[:], h.Sum(nil))
+	}
+	return
+}
+
+// notify reports the changes from the old states to the new ones to fc.
+func notify(fc FSChanged, old, new map[string]*fileState) {
+	fcEx, _ := fc.(FSChangedEx)
+	var added []string // directories added
+	inAdded := func(name string) bool {
+		for _, dir := range added {
+			if strings.HasPrefix(name, dir+"/") {
+				return true
+			}
+		}
+		return false
+	}
+	for _, name := range sortedNames(new) {
+		st := new[name]
+		ost, ok := old[name]
+		if ok && st.sameAs(ost) {
+			continue
+		}
+		if inAdded(name) { // reported by DirAdded
+			continue
+		}
+		if ok && ost.isDir != st.isDir {
+			fc.EntryDeleted(name, ost.isDir)
+			ok = false
+		}
+		if debugEvent {
+			log.Println("==> poll:", name, "changed")
+		}
+		switch {
+		case st.isDir:
+			added = append(added, name)
+			fc.DirAdded(name)
+		case fcEx != nil:
+			fcEx.FileChangedEx(name, !ok)
+		default:
+			fc.FileChanged(name)
+		}
+	}
+	deleted := sortedNames(old)
+	for i := len(deleted) - 1; i >= 0; i-- { // files before their directories
+		name := deleted[i]
+		if _, ok := new[name]; !ok {
+			if debugEvent {
+				log.Println("==> poll:", name, "deleted")
+			}
+			fc.EntryDeleted(name, old[name].isDir)
+		}
+	}
+}
+
+func sortedNames(files map[string]*fileState) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// -----------------------------------------------------------------------------------------
@@ -0,0 +1,224 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fsnotify
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	mutex sync.Mutex
+	calls []string
+}
+
+func (p *recorder) add(call string) {
+	p.mutex.Lock()
+	p.calls = append(p.calls, call)
+	p.mutex.Unlock()
+}
+
+func (p *recorder) get() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]string(nil), p.calls...)
+}
+
+func (p *recorder) FileChanged(name string) { p.add("change " + name) }
+func (p *recorder) DirAdded(name string)    { p.add("adddir " + name) }
+func (p *recorder) EntryDeleted(name string, isDir bool) {
+	if isDir {
+		p.add("deldir " + name)
+	} else {
+		p.add("delete " + name)
+	}
+}
+
+type recorderEx struct {
+	recorder
+}
+
+func (p *recorderEx) FileChangedEx(name string, created bool) {
+	if created {
+		p.add("create " + name)
+	} else {
+		p.add("modify " + name)
+	}
+}
+
+func (p *recorderEx) EntryRenamed(name string, isDir bool) { p.add("rename " + name) }
+
+func file(size int64, mtime int64, hash ...byte) *fileState {
+	st := &fileState{size: size, mtime: time.Unix(mtime, 0)}
+	if hash != nil {
+		st.hash[0], st.hashed = hash[0], true
+	}
+	return st
+}
+
+var dir = &fileState{isDir: true}
+
+func TestNotify(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new map[string]*fileState
+		want     []string
+		wantEx   []string
+	}{
+		{"unchanged",
+			map[string]*fileState{"a": file(1, 1), "d": dir},
+			map[string]*fileState{"a": file(1, 1), "d": dir},
+			nil, nil},
+		{"size",
+			map[string]*fileState{"a": file(1, 1)},
+			map[string]*fileState{"a": file(2, 1)},
+			[]string{"change a"}, []string{"modify a"}},
+		{"mtime",
+			map[string]*fileState{"a": file(1, 1)},
+			map[string]*fileState{"a": file(1, 2)},
+			[]string{"change a"}, []string{"modify a"}},
+		{"touched",
+			map[string]*fileState{"a": file(1, 1, 7)},
+			map[string]*fileState{"a": file(1, 2, 7)},
+			nil, nil},
+		{"content",
+			map[string]*fileState{"a": file(1, 1, 7)},
+			map[string]*fileState{"a": file(1, 1, 8)},
+			[]string{"change a"}, []string{"modify a"}},
+		{"created",
+			map[string]*fileState{},
+			map[string]*fileState{"a": file(1, 1)},
+			[]string{"change a"}, []string{"create a"}},
+		{"dir added",
+			map[string]*fileState{},
+			map[string]*fileState{"d": dir, "d/a": file(1, 1), "d/e": dir, "e": file(1, 1)},
+			[]string{"adddir d", "change e"}, []string{"adddir d", "create e"}},
+		{"deleted",
+			map[string]*fileState{"d": dir, "d/a": file(1, 1), "e": file(1, 1)},
+			map[string]*fileState{},
+			[]string{"delete e", "delete d/a", "deldir d"}, []string{"delete e", "delete d/a", "deldir d"}},
+		{"file to dir",
+			map[string]*fileState{"a": file(1, 1)},
+			map[string]*fileState{"a": dir},
+			[]string{"delete a", "adddir a"}, []string{"delete a", "adddir a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc := new(recorder)
+			notify(fc, tt.old, tt.new)
+			if got := fc.get(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("notify: got %v, want %v", got, tt.want)
+			}
+			fcEx := new(recorderEx)
+			notify(fcEx, tt.old, tt.new)
+			if got := fcEx.get(); !reflect.DeepEqual(got, tt.wantEx) {
+				t.Errorf("notify FSChangedEx: got %v, want %v", got, tt.wantEx)
+			}
+		})
+	}
+}
+
+func TestScanHashes(t *testing.T) {
+	root := t.TempDir()
+	past := time.Now().Add(-time.Hour)
+	writeFile := func(name, data string, mtime time.Time) {
+		t.Helper()
+		file := filepath.Join(root, name)
+		if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if !mtime.IsZero() {
+			if err := os.Chtimes(file, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	writeFile("old", "old", past)
+	writeFile("new", "new", time.Time{})
+	os.Mkdir(filepath.Join(root, "_ignored"), 0755)
+	writeFile("_ignored/x", "x", time.Time{})
+
+	p := new(poller)
+	ignore := func(name string, isDir bool) bool { return strings.HasPrefix(name, "_") }
+	files, err := p.scan(root, ignore, nil)
+	if err != nil {
+		t.Fatal("scan:", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("scan: got %d entries", len(files))
+	}
+	if files["old"].hashed {
+		t.Fatal("scan: a file not modified recently is hashed")
+	}
+	if !files["new"].hashed {
+		t.Fatal("scan: a file modified recently isn't hashed")
+	}
+
+	// same size and mtime: modified without changing its mtime
+	writeFile("new", "NEW", files["new"].mtime)
+	// touched with the same content: changed as it was not hashed
+	writeFile("old", "old", past.Add(time.Minute))
+	next, err := p.scan(root, ignore, files)
+	if err != nil {
+		t.Fatal("scan:", err)
+	}
+	fc := new(recorderEx)
+	notify(fc, files, next)
+	if got := fc.get(); !reflect.DeepEqual(got, []string{"modify new", "modify old"}) {
+		t.Fatal("notify:", got)
+	}
+
+	files = next
+	writeFile("new", "NEW", time.Now().Add(time.Second))
+	if next, err = p.scan(root, ignore, files); err != nil {
+		t.Fatal("scan:", err)
+	}
+	fc = new(recorderEx)
+	notify(fc, files, next)
+	if got := fc.get(); len(got) != 0 {
+		t.Fatal("notify touched file:", got)
+	}
+}
+
+func TestPoller(t *testing.T) {
+	root := t.TempDir()
+	w := NewPoller(10 * time.Millisecond)
+	fc := new(recorderEx)
+	if err := w.Run(root, fc, nil); err != nil {
+		t.Fatal("Run:", err)
+	}
+	defer w.Close()
+	if err := os.WriteFile(filepath.Join(root, "a.xgo"), []byte("echo 1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if got := fc.get(); len(got) > 0 {
+			if got[0] != "create a.xgo" {
+				t.Fatal("events:", got)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("no event")
+}
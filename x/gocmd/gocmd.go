@@ -17,6 +17,7 @@
 package gocmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
@@ -32,6 +33,16 @@ type Config struct {
 	GoCmd string
 	Flags []string
 	Run   func(cmd *exec.Cmd) error
+
+	// Overlay maps source file paths to the files replacing them, see the
+	// -overlay flag of the go command. Paths should be absolute.
+	Overlay map[string]string
+
+	// MapPos, if not nil, rewrites positions of generated Go files in the
+	// stderr of the go command and in the stdout of `go test`, eg. compile
+	// errors and panic stack traces of tests. The output of the program run
+	// by RunFiles is kept as is.
+	MapPos PosMapper
 }
 
 // -----------------------------------------------------------------------------
@@ -48,9 +59,18 @@ func doWithArgs(dir, op string, conf *Config, args ...string) (err error) {
 	exargs[0] = op
 	exargs = appendLdflags(exargs, conf.XGo)
 	exargs = append(exargs, conf.Flags...)
+	if len(conf.Overlay) > 0 {
+		overlay, e := writeOverlay(conf.Overlay)
+		if e != nil {
+			return e
+		}
+		defer os.Remove(overlay)
+		exargs = append(exargs, "-overlay="+overlay)
+	}
 	exargs = append(exargs, args...)
 	cmd := exec.Command(goCmd, exargs...)
 	cmd.Dir = dir
+	defer mapOutput(cmd, conf, op == "test")()
 	run := conf.Run
 	if run == nil {
 		run = runCmd
@@ -58,10 +78,36 @@ func doWithArgs(dir, op string, conf *Config, args ...string) (err error) {
 	return run(cmd)
 }
 
+// writeOverlay writes the overlay JSON file of replace to a temporary file.
+func writeOverlay(replace map[string]string) (file string, err error) {
+	b, err := json.Marshal(struct{ Replace map[string]string }{replace})
+	if err != nil {
+		return
+	}
+	f, err := os.CreateTemp("", "overlay*.json")
+	if err != nil {
+		return
+	}
+	file = f.Name()
+	if _, err = f.Write(b); err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		os.Remove(file)
+	}
+	return
+}
+
 func runCmd(cmd *exec.Cmd) (err error) {
 	cmd.Stdin = os.Stdin
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
+	if cmd.Stderr == nil { // see mapOutput
+		cmd.Stderr = os.Stderr
+	}
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
 	return cmd.Run()
 }
 
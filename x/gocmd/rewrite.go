@@ -0,0 +1,134 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocmd
+
+import (
+	"bytes"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// -----------------------------------------------------------------------------
+
+// PosMapper maps a position reported by the go command or by a program to
+// the XGo position it stands for, eg. a position of a generated Go file. It
+// returns false to keep pos, eg. if pos.Filename is not a file it has a
+// source map of. pos.Filename is absolute. The Column of the result is 0 if
+// the column can't be mapped.
+type PosMapper = func(pos token.Position) (token.Position, bool)
+
+// posRegexp matches `file.ext:line` and `file.ext:line:col` in the output of
+// the go command and in stack traces, eg. `\t/path/xgo_autogen.go:12 +0x1d`.
+// A match is only a candidate: its text is kept unless PosMapper maps it.
+var posRegexp = regexp.MustCompile(`((?:[A-Za-z]:)?[^\s:()"'\[\]]+\.\w+):(\d+)(?::(\d+))?`)
+
+// posWriter rewrites the positions in each line written to it by mapPos, and
+// writes the line to w.
+type posWriter struct {
+	w      io.Writer
+	mapPos PosMapper
+	cwd    string
+
+	mutex sync.Mutex
+	buf   []byte // the incomplete last line
+}
+
+func newPosWriter(w io.Writer, mapPos PosMapper) *posWriter {
+	cwd, _ := os.Getwd()
+	return &posWriter{w: w, mapPos: mapPos, cwd: cwd}
+}
+
+func (p *posWriter) Write(b []byte) (n int, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.buf = append(p.buf, b...)
+	if i := bytes.LastIndexByte(p.buf, '\n'); i >= 0 {
+		_, err = p.w.Write(p.rewrite(p.buf[:i+1]))
+		p.buf = append(p.buf[:0], p.buf[i+1:]...)
+	}
+	return len(b), err
+}
+
+// Flush writes the incomplete last line if any.
+func (p *posWriter) Flush() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if len(p.buf) == 0 {
+		return nil
+	}
+	_, err := p.w.Write(p.rewrite(p.buf))
+	p.buf = p.buf[:0]
+	return err
+}
+
+func (p *posWriter) rewrite(b []byte) []byte {
+	return posRegexp.ReplaceAllFunc(b, func(m []byte) []byte {
+		sub := posRegexp.FindSubmatch(m)
+		file := string(sub[1])
+		line, _ := strconv.Atoi(string(sub[2]))
+		col := 0
+		if sub[3] != nil {
+			col, _ = strconv.Atoi(string(sub[3]))
+		}
+		abs := file
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(p.cwd, abs)
+		}
+		pos, ok := p.mapPos(token.Position{Filename: abs, Line: line, Column: col})
+		if !ok {
+			return m
+		}
+		if !filepath.IsAbs(file) { // keep it relative as the go command does
+			if rel, err := filepath.Rel(p.cwd, pos.Filename); err == nil && !isParent(rel) {
+				pos.Filename = rel
+			}
+		}
+		return []byte(pos.String()) // file:line if the column isn't mapped
+	})
+}
+
+func isParent(rel string) bool {
+	return rel == ".." || len(rel) > 2 && rel[:3] == ".."+string(filepath.Separator)
+}
+
+// mapOutput makes the output of cmd pass through a posWriter if conf.MapPos
+// is set. stdout is rewritten too if it is the output of tests. Call the
+// returned function after cmd exits.
+func mapOutput(cmd *exec.Cmd, conf *Config, stdout bool) (flush func()) {
+	if conf == nil || conf.MapPos == nil {
+		return func() {}
+	}
+	stderr := newPosWriter(os.Stderr, conf.MapPos)
+	cmd.Stderr = stderr
+	if !stdout {
+		return func() { stderr.Flush() }
+	}
+	out := newPosWriter(os.Stdout, conf.MapPos)
+	cmd.Stdout = out
+	return func() {
+		out.Flush()
+		stderr.Flush()
+	}
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gocmd
+
+import (
+	"bytes"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testMapper maps the lines of the Go files named xgo_autogen.go to the
+// lines of app.xgo in the same directory, 10 lines less. The columns of the
+// lines after 40 can't be mapped.
+func testMapper(pos token.Position) (token.Position, bool) {
+	if filepath.Base(pos.Filename) != "xgo_autogen.go" || pos.Line <= 10 {
+		return pos, false
+	}
+	pos.Filename = filepath.Join(filepath.Dir(pos.Filename), "app.xgo")
+	pos.Line -= 10
+	if pos.Line > 30 {
+		pos.Column = 0
+	}
+	return pos, true
+}
+
+func TestPosWriter(t *testing.T) {
+	cwd, _ := os.Getwd()
+	abs := filepath.Join(cwd, "xgo_autogen.go")
+	tests := []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{"compile errors", []string{
+			"# example.com/app\n./xgo_autogen.go:12:5: undefined: x\n./xgo_autogen.go:15:2: x declared and not used\n",
+		}, "# example.com/app\napp.xgo:2:5: undefined: x\napp.xgo:5:2: x declared and not used\n"},
+		{"panic stack", []string{
+			"panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t" + abs + ":21 +0x1d\nexit status 2\n",
+		}, "panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t" + filepath.Join(cwd, "app.xgo") + ":11 +0x1d\nexit status 2\n"},
+		{"partial lines", []string{
+			"./xgo_", "autogen.go:1", "2:5: undefined", ": x\n./xgo_autogen.go:1", "3:1: end",
+		}, "app.xgo:2:5: undefined: x\napp.xgo:3:1: end"},
+		{"column not mapped", []string{
+			"./xgo_autogen.go:42:7: undefined: y\n",
+		}, "app.xgo:32: undefined: y\n"},
+		{"no match", []string{
+			"ok  \texample.com/app\t0.01s\n./xgo_autogen.go:3:1: header\n./main.go:12:1: Go code\nhttp://localhost:8080\nsee doc.txt:12, v1.2:3\n",
+		}, "ok  \texample.com/app\t0.01s\n./xgo_autogen.go:3:1: header\n./main.go:12:1: Go code\nhttp://localhost:8080\nsee doc.txt:12, v1.2:3\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			w := newPosWriter(&b, testMapper)
+			for _, s := range tt.writes {
+				if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+					t.Fatalf("Write(%q): %d, %v", s, n, err)
+				}
+				if out := b.String(); out != "" && !strings.HasSuffix(out, "\n") {
+					t.Fatalf("Write(%q): an incomplete line written: %q", s, out)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal("Flush:", err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// captureStderr returns what f writes to os.Stderr.
+func captureStderr(t *testing.T, f func()) string {
+	t.Helper()
+	file, err := os.CreateTemp(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	stderr := os.Stderr
+	os.Stderr = file
+	defer func() { os.Stderr = stderr }()
+	f()
+	b, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRunFilesMapPos(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "xgo_autogen.go")
+	conf := &RunConfig{XGo: &XGoEnv{Root: dir}, MapPos: testMapper}
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	// the output of the program is kept
+	src := "package main\n\nimport \"os\"\n\nfunc main() {\n" +
+		"\tos.Stderr.WriteString(\"./xgo_autogen.go:12:1: program output\\n\")\n}\n"
+	if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	out := captureStderr(t, func() { err = RunFiles("", []string{file}, nil, conf) })
+	if err != nil {
+		t.Fatal("RunFiles:", err, out)
+	}
+	if out != "./xgo_autogen.go:12:1: program output\n" {
+		t.Fatalf("program output: %q", out)
+	}
+
+	// errors of the go command are mapped
+	src = "package main\n\nfunc main() {\n" + strings.Repeat("\n", 9) + "\tundefinedX()\n}\n"
+	if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out = captureStderr(t, func() { err = RunFiles("", []string{file}, nil, conf) })
+	if err == nil {
+		t.Fatal("RunFiles: no error")
+	}
+	if want := "app.xgo:3:2: undefined: undefinedX"; !strings.Contains(out, want) {
+		t.Fatalf("compile error: %q, want %q", out, want)
+	}
+}
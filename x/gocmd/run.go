@@ -20,6 +20,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"syscall"
 )
@@ -44,6 +45,19 @@ func RunDir(buildDir, dir string, args []string, conf *RunConfig) (err error) {
 			}
 		}
 	}
+	if conf != nil && len(conf.Overlay) > 0 { // files only existing in the overlay
+		absDir, _ := filepath.Abs(dir)
+		var extra []string
+		for file := range conf.Overlay {
+			if filepath.Dir(file) == absDir && filterRunFname(filepath.Base(file)) {
+				if _, e := os.Lstat(file); e != nil {
+					extra = append(extra, filepath.Join(dir, filepath.Base(file)))
+				}
+			}
+		}
+		sort.Strings(extra)
+		files = append(files, extra...)
+	}
 	return RunFiles(buildDir, files, args, conf)
 }
 
@@ -61,6 +75,9 @@ func RunFiles(buildDir string, files []string, args []string, conf *RunConfig) (
 	if len(files) == 0 {
 		return syscall.ENOENT
 	}
+	if buildDir == "" && conf != nil && conf.MapPos != nil {
+		buildDir = "." // map errors of the go command, but not the output of the program
+	}
 	if buildDir == "" {
 		args = append(files, args...)
 		return doWithArgs("", "run", conf, args...)
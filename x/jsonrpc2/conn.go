@@ -27,6 +27,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -69,6 +70,14 @@ type ConnectionOptions struct {
 	// while serving the connection, such as protocol errors or invariant
 	// violations. (If nil, internal errors result in panics.)
 	OnInternalError func(error)
+	// Interceptors wrap the Preempter, Handler, Call and Notify of the
+	// connection. The
+	// first one is the outermost.
+	Interceptors []Interceptor
+	// MethodTimeouts limits how long an incoming call of a method may take
+	// from when it is read, the key "" is for other methods. The context of
+	// the call is cancelled when it times out, so a Handler must honor it.
+	MethodTimeouts map[string]time.Duration
 }
 
 // Connection manages the jsonrpc2 protocol, connecting responses back to their
@@ -84,7 +93,10 @@ type Connection struct {
 
 	writer chan Writer // 1-buffered; stores the writer when not in use
 
-	handler Handler
+	handler  Handler
+	caller   CallFunc
+	notifier NotifyFunc
+	timeouts map[string]time.Duration
 
 	onInternalError func(error)
 	onDone          func()
@@ -211,6 +223,29 @@ type incomingRequest struct {
 	*Request // the request being processed
 	ctx      context.Context
 	cancel   context.CancelFunc
+	batch    *incomingBatch // the batch of a call request, if any
+	start    time.Time      // when the request was read
+}
+
+// incomingBatch collects the responses to the calls of an incoming Batch, which
+// are sent back in one Batch when all the calls are done.
+type incomingBatch struct {
+	mutex   sync.Mutex
+	pending int // calls not responded yet
+	resps   Batch
+}
+
+// add adds the response to a call of the batch, and returns the responses
+// once the last call is done.
+func (b *incomingBatch) add(resp *Response) Batch {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.resps = append(b.resps, resp)
+	b.pending--
+	if b.pending > 0 {
+		return nil
+	}
+	return b.resps
 }
 
 // newConnection creates a new connection and runs it.
@@ -231,6 +266,7 @@ func newConnection(bindCtx context.Context, rwc io.ReadWriteCloser, binder Binde
 		writer: make(chan Writer, 1),
 		onDone: onDone,
 	}
+	c.caller, c.notifier = c.call, c.notify
 	// It's tempting to set a finalizer on c to verify that the state has gone
 	// idle when the connection becomes unreachable. Unfortunately, the Binder
 	// interface makes that unsafe: it allows the Handler to close over the
@@ -246,6 +282,23 @@ func newConnection(bindCtx context.Context, rwc io.ReadWriteCloser, binder Binde
 	if c.handler == nil {
 		c.handler = defaultHandler{}
 	}
+	preempter := options.Preempter
+	for i := len(options.Interceptors) - 1; i >= 0; i-- {
+		ic := options.Interceptors[i]
+		if ic.Handle != nil {
+			c.handler = ic.Handle(c.handler)
+		}
+		if ic.Preempt != nil && preempter != nil {
+			preempter = ic.Preempt(preempter)
+		}
+		if ic.Call != nil {
+			c.caller = ic.Call(c.caller)
+		}
+		if ic.Notify != nil {
+			c.notifier = ic.Notify(c.notifier)
+		}
+	}
+	c.timeouts = options.MethodTimeouts
 	c.onInternalError = options.OnInternalError
 	if c.onInternalError == nil {
 		c.onInternalError = defaultHandleError
@@ -267,7 +320,7 @@ func newConnection(bindCtx context.Context, rwc io.ReadWriteCloser, binder Binde
 		// (If the Binder closed the Connection already, this should error out and
 		// return almost immediately.)
 		s.reading = true
-		go c.readIncoming(ctx, reader, options.Preempter)
+		go c.readIncoming(ctx, reader, preempter)
 	})
 	return c
 }
@@ -275,7 +328,11 @@ func newConnection(bindCtx context.Context, rwc io.ReadWriteCloser, binder Binde
 // Notify invokes the target method but does not wait for a response.
 // The params will be marshaled to JSON before sending over the wire, and will
 // be handed to the method invoked.
-func (c *Connection) Notify(ctx context.Context, method string, params any) (err error) {
+func (c *Connection) Notify(ctx context.Context, method string, params any) error {
+	return c.notifier(ctx, method, params)
+}
+
+func (c *Connection) notify(ctx context.Context, method string, params any) (err error) {
 	attempted := false
 
 	defer func() {
@@ -326,14 +383,20 @@ func (c *Connection) Notify(ctx context.Context, method string, params any) (err
 // You do not have to wait for the response, it can just be ignored if not needed.
 // If sending the call failed, the response will be ready and have the error in it.
 func (c *Connection) Call(ctx context.Context, method string, params any) *AsyncCall {
+	return c.caller(ctx, method, params)
+}
+
+func (c *Connection) call(ctx context.Context, method string, params any) *AsyncCall {
 	if debugCall {
 		log.Println("Call", method, "params:", params)
 	}
 	// Generate a new request identifier.
 	id := Int64ID(atomic.AddInt64(&c.seq, 1))
 	ac := &AsyncCall{
-		id:    id,
-		ready: make(chan struct{}),
+		id:     id,
+		method: method,
+		start:  time.Now(),
+		ready:  make(chan struct{}),
 	}
 	// When this method returns, either ac is retired, or the request has been
 	// written successfully and the call is awaiting a response (to be provided by
@@ -364,7 +427,9 @@ func (c *Connection) Call(ctx context.Context, method string, params any) *Async
 	if debugCall {
 		log.Println("Connection.write", call.ID, call.Method, err)
 	}
-	if err != nil {
+	if err == nil {
+		c.cancelOnDone(ctx, ac)
+	} else {
 		// Sending failed. We will never get a response, so deliver a fake one if it
 		// wasn't already retired by the connection breaking.
 		c.updateInFlight(func(s *inFlightState) {
@@ -381,8 +446,103 @@ func (c *Connection) Call(ctx context.Context, method string, params any) *Async
 	return ac
 }
 
+// BatchCall is a call sent in a batch by CallBatch.
+type BatchCall struct {
+	Method string
+	Params any
+}
+
+// CallBatch invokes the target methods in one batch, and returns an AsyncCall
+// for each of the calls, in the same order. The peer may handle the calls of a
+// batch in any order, and responds them all at once. The Call interceptors of
+// the connection are not applied to the calls of a batch.
+// If sending the batch failed, the responses will be ready and have the error
+// in them.
+func (c *Connection) CallBatch(ctx context.Context, calls []BatchCall) []*AsyncCall {
+	acs := make([]*AsyncCall, len(calls))
+	batch := make(Batch, 0, len(calls))
+	for i, bc := range calls {
+		id := Int64ID(atomic.AddInt64(&c.seq, 1))
+		ac := &AsyncCall{id: id, method: bc.Method, start: time.Now(), ready: make(chan struct{})}
+		acs[i] = ac
+		call, err := NewCall(id, bc.Method, bc.Params)
+		if err != nil {
+			ac.retire(&Response{ID: id, Error: fmt.Errorf("marshaling call parameters: %w", err)})
+			continue
+		}
+		batch = append(batch, call)
+	}
+	if len(batch) == 0 {
+		return acs
+	}
+
+	var err error
+	c.updateInFlight(func(s *inFlightState) {
+		err = s.shuttingDown(ErrClientClosing)
+		if err != nil {
+			return
+		}
+		if s.outgoingCalls == nil {
+			s.outgoingCalls = make(map[ID]*AsyncCall)
+		}
+		for _, ac := range acs {
+			if !ac.IsReady() {
+				s.outgoingCalls[ac.id] = ac
+			}
+		}
+	})
+	if err != nil {
+		for _, ac := range acs {
+			if !ac.IsReady() {
+				ac.retire(&Response{ID: ac.id, Error: err})
+			}
+		}
+		return acs
+	}
+
+	err = c.write(ctx, batch)
+	if debugCall {
+		log.Println("Connection.write batch", len(batch), err)
+	}
+	if err == nil {
+		for _, ac := range acs {
+			c.cancelOnDone(ctx, ac)
+		}
+	} else {
+		// Sending failed: deliver fake responses to the calls that weren't
+		// already retired by the connection breaking.
+		c.updateInFlight(func(s *inFlightState) {
+			for _, ac := range acs {
+				if s.outgoingCalls[ac.id] == ac {
+					delete(s.outgoingCalls, ac.id)
+					ac.retire(&Response{ID: ac.id, Error: err})
+				}
+			}
+		})
+	}
+	return acs
+}
+
+// cancelOnDone sends a $/cancelRequest notification for the call ac if ctx is
+// done before the response arrives. The call still waits for the response.
+func (c *Connection) cancelOnDone(ctx context.Context, ac *AsyncCall) {
+	done := ctx.Done()
+	if done == nil {
+		return
+	}
+	go func() {
+		select {
+		case <-done:
+			c.Notify(notDone{ctx}, MethodCancelRequest, &CancelParams{ID: ac.id.value})
+		case <-ac.ready:
+		}
+	}()
+}
+
 type AsyncCall struct {
 	id       ID
+	method   string
+	start    time.Time     // when the call was made
 	ready    chan struct{} // closed after response has been set
 	response *Response
 }
@@ -480,6 +640,59 @@ func (c *Connection) Cancel(id ID) {
 	}
 }
 
+// InFlightRequest describes a request in flight, see InFlight.
+type InFlightRequest struct {
+	ID       any           `json:"id"`
+	Method   string        `json:"method"`
+	Duration time.Duration `json:"duration"` // how long it is in flight
+	Queued   bool          `json:"queued,omitempty"`
+}
+
+// InFlightStats is a snapshot of the requests in flight of a Connection.
+type InFlightStats struct {
+	Incoming      []InFlightRequest `json:"incoming"` // calls only
+	Outgoing      []InFlightRequest `json:"outgoing"` // calls only
+	Notifications int               `json:"notifications"`
+	Queued        int               `json:"queued"` // requests waiting for the Handler
+	Closing       bool              `json:"closing,omitempty"`
+}
+
+// InFlight returns a snapshot of the requests in flight, which is useful to
+// find out stuck requests.
+func (c *Connection) InFlight() *InFlightStats {
+	now := time.Now()
+	ret := new(InFlightStats)
+	c.updateInFlight(func(s *inFlightState) {
+		queued := make(map[*incomingRequest]bool, len(s.handlerQueue))
+		for _, req := range s.handlerQueue {
+			queued[req] = true
+		}
+		for id, req := range s.incomingByID {
+			ret.Incoming = append(ret.Incoming, InFlightRequest{
+				ID: id.value, Method: req.Method, Duration: now.Sub(req.start), Queued: queued[req],
+			})
+		}
+		for id, ac := range s.outgoingCalls {
+			ret.Outgoing = append(ret.Outgoing, InFlightRequest{
+				ID: id.value, Method: ac.method, Duration: now.Sub(ac.start),
+			})
+		}
+		ret.Notifications = s.incoming - len(s.incomingByID)
+		ret.Queued = len(s.handlerQueue)
+		ret.Closing = s.connClosing
+	})
+	sortInFlight(ret.Incoming)
+	sortInFlight(ret.Outgoing)
+	return ret
+}
+
+// sortInFlight sorts requests from the longest in flight.
+func sortInFlight(reqs []InFlightRequest) {
+	sort.Slice(reqs, func(i, j int) bool {
+		return reqs[i].Duration > reqs[j].Duration
+	})
+}
+
 // Wait blocks until the connection is fully closed, but does not close it.
 func (c *Connection) Wait() error {
 	if Verbose {
@@ -530,21 +743,10 @@ func (c *Connection) readIncoming(ctx context.Context, reader Reader, preempter
 			c.acceptRequest(ctx, msg, n, preempter)
 
 		case *Response:
-			if Verbose {
-				log.Println("==> readIncoming Response:", msg.ID)
-			}
-			c.updateInFlight(func(s *inFlightState) {
-				if ac, ok := s.outgoingCalls[msg.ID]; ok {
-					delete(s.outgoingCalls, msg.ID)
-					ac.retire(msg)
-				} else {
-					// TODO: How should we report unexpected responses?
-					_ = 0
-				}
-			})
-			if Verbose {
-				log.Println("==> readIncoming: updateInFlight -", msg.ID)
-			}
+			c.acceptResponse(msg)
+
+		case Batch:
+			c.acceptBatch(ctx, msg, preempter)
 
 		default:
 			c.internalErrorf("Read returned an unexpected message of type %T", msg)
@@ -567,16 +769,118 @@ func (c *Connection) readIncoming(ctx context.Context, reader Reader, preempter
 	})
 }
 
+// acceptResponse retires the outgoing call that msg responds to.
+func (c *Connection) acceptResponse(msg *Response) {
+	if Verbose {
+		log.Println("==> readIncoming Response:", msg.ID)
+	}
+	c.updateInFlight(func(s *inFlightState) {
+		if ac, ok := s.outgoingCalls[msg.ID]; ok {
+			delete(s.outgoingCalls, msg.ID)
+			ac.retire(msg)
+		} else {
+			// TODO: How should we report unexpected responses?
+			_ = 0
+		}
+	})
+	if Verbose {
+		log.Println("==> readIncoming: updateInFlight -", msg.ID)
+	}
+}
+
+// acceptBatch delivers the messages of a batch one by one. The responses to
+// its call requests are collected and sent back in one Batch.
+func (c *Connection) acceptBatch(ctx context.Context, batch Batch, preempter Preempter) {
+	// Count the calls first: a call may be done before the next one is accepted.
+	b := new(incomingBatch)
+	for _, msg := range batch {
+		switch msg := msg.(type) {
+		case *Request:
+			if msg.IsCall() {
+				b.pending++
+			}
+		case *InvalidMessage:
+			b.pending++
+		}
+	}
+	for _, msg := range batch {
+		switch msg := msg.(type) {
+		case *Request:
+			var rb *incomingBatch
+			if msg.IsCall() {
+				rb = b
+			}
+			c.acceptBatchRequest(ctx, msg, rb, preempter)
+		case *Response:
+			c.acceptResponse(msg)
+		case *InvalidMessage:
+			// The id of an invalid element can't be trusted, so it's responded
+			// with a null id.
+			if resps := b.add(&Response{Error: msg.Error()}); resps != nil {
+				c.write(notDone{ctx}, resps)
+			}
+		default:
+			c.internalErrorf("Read returned an unexpected message of type %T in a batch", msg)
+		}
+	}
+}
+
+// timeoutOf returns the timeout of an incoming call, 0 if no timeout.
+func (c *Connection) timeoutOf(msg *Request) time.Duration {
+	if !msg.IsCall() || c.timeouts == nil {
+		return 0
+	}
+	if d, ok := c.timeouts[msg.Method]; ok {
+		return d
+	}
+	return c.timeouts[""]
+}
+
+// acceptCancel cancels the incoming call that a $/cancelRequest notification
+// refers to. Cancellation is best effort, so a bad notification is ignored.
+func (c *Connection) acceptCancel(msg *Request) {
+	var params CancelParams
+	err := json.Unmarshal(msg.Params, &params)
+	if err == nil {
+		var id ID
+		if id, err = makeID(params.ID); err == nil {
+			c.Cancel(id)
+			return
+		}
+	}
+	if debugCall {
+		log.Println("acceptCancel:", err)
+	}
+}
+
 // acceptRequest either handles msg synchronously or enqueues it to be handled
 // asynchronously.
 func (c *Connection) acceptRequest(ctx context.Context, msg *Request, msgBytes int64, preempter Preempter) {
+	c.acceptBatchRequest(ctx, msg, nil, preempter)
+}
+
+// acceptBatchRequest is acceptRequest for a request which may be a call of the
+// batch b.
+func (c *Connection) acceptBatchRequest(ctx context.Context, msg *Request, b *incomingBatch, preempter Preempter) {
+	if msg.Method == MethodCancelRequest && !msg.IsCall() {
+		c.acceptCancel(msg)
+		return
+	}
+
 	// In theory notifications cannot be cancelled, but we build them a cancel
 	// context anyway.
-	ctx, cancel := context.WithCancel(ctx)
+	var cancel context.CancelFunc
+	if d := c.timeoutOf(msg); d > 0 {
+		ctx, cancel = context.WithTimeout(ctx, d)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
 	req := &incomingRequest{
 		Request: msg,
 		ctx:     ctx,
 		cancel:  cancel,
+		batch:   b,
+		start:   time.Now(),
 	}
 
 	// If the request is a call, add it to the incoming map so it can be
@@ -716,13 +1020,17 @@ func (c *Connection) processResult(from any, req *incomingRequest, result any, e
 		// Add detail describing the unhandled method.
 		err = fmt.Errorf("%w: %q", ErrMethodNotFound, req.Method)
 	}
+	if err != nil && req.ctx.Err() != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		err = fmt.Errorf("%w: %q: %v", ErrRequestCancelled, req.Method, err)
+	}
 
 	if result != nil && err != nil {
 		c.internalErrorf("%#v returned a non-nil result with a non-nil error for %s:\n%v\n%#v", from, req.Method, err, result)
 		result = nil // Discard the spurious result and respond with err.
 	}
 
-	if req.IsCall() {
+	if req.IsCall() || req.batch != nil {
+		// A call of a batch is always responded, even if its ID is misattributed.
 		response, respErr := NewResponse(req.ID, result, err)
 		if debugCall {
 			log.Println("processResult", response.ID, string(response.Result), response.Error)
@@ -734,13 +1042,24 @@ func (c *Connection) processResult(from any, req *incomingRequest, result any, e
 		c.updateInFlight(func(s *inFlightState) {
 			delete(s.incomingByID, req.ID)
 		})
-		if respErr == nil {
+		if respErr != nil {
+			err = c.internalErrorf("%#v returned a malformed result for %q: %w", from, req.Method, respErr)
+		}
+		if req.batch != nil {
+			if respErr != nil {
+				response = &Response{ID: req.ID, Error: err}
+			}
+			if resps := req.batch.add(response); resps != nil {
+				writeErr := c.write(notDone{req.ctx}, resps)
+				if err == nil {
+					err = writeErr
+				}
+			}
+		} else if respErr == nil {
 			writeErr := c.write(notDone{req.ctx}, response)
 			if err == nil {
 				err = writeErr
 			}
-		} else {
-			err = c.internalErrorf("%#v returned a malformed result for %q: %w", from, req.Method, respErr)
 		}
 	} else { // req is a notification
 		if result != nil {
@@ -22,9 +22,13 @@ package jsonrpc2
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -61,17 +65,63 @@ type Framer interface {
 	Writer(rw io.Writer) Writer
 }
 
+// DefaultMaxMessageSize is the default maximum size of a message read by
+// the framers of this package.
+const DefaultMaxMessageSize = 64 << 20
+
+// ErrMessageTooLarge is returned by a message reader when the size of a
+// message exceeds the limit of its framer.
+var ErrMessageTooLarge = errors.New("jsonrpc2: message too large")
+
+// LimitFramer returns a copy of framer f whose readers reject messages larger
+// than maxSize bytes. f must be a framer of this package, or a TraceFramer of
+// one, otherwise an error is returned. maxSize <= 0 means
+// DefaultMaxMessageSize.
+func LimitFramer(f Framer, maxSize int64) (Framer, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxMessageSize
+	}
+	if l, ok := f.(limiter); ok {
+		if ret := l.limit(maxSize); ret != nil {
+			return ret, nil
+		}
+	}
+	return nil, fmt.Errorf("jsonrpc2: can't limit the message size of framer %T", f)
+}
+
+// limiter is implemented by the framers which LimitFramer supports. limit
+// returns nil if it can't limit the framer.
+type limiter interface {
+	limit(maxSize int64) Framer
+}
+
+func (headerFramer) limit(maxSize int64) Framer { return headerFramer{maxSize} }
+func (rawFramer) limit(maxSize int64) Framer    { return rawFramer{maxSize} }
+func (lengthFramer) limit(maxSize int64) Framer { return lengthFramer{maxSize} }
+
+func errTooLarge(size, maxSize int64) error {
+	return fmt.Errorf("%w: %d bytes exceeds the limit of %d bytes", ErrMessageTooLarge, size, maxSize)
+}
+
+// -----------------------------------------------------------------------------
+
+// maxHeaderLine is the maximum length of a header line read by HeaderFramer.
+const maxHeaderLine = 4096
+
 // HeaderFramer returns a new Framer.
 // The messages are sent with HTTP content length and MIME type headers.
 // This is the format used by LSP and others.
-func HeaderFramer() Framer { return headerFramer{} }
+func HeaderFramer() Framer { return headerFramer{DefaultMaxMessageSize} }
 
-type headerFramer struct{}
-type headerReader struct{ in *bufio.Reader }
+type headerFramer struct{ maxSize int64 }
+type headerReader struct {
+	in      *bufio.Reader
+	maxSize int64
+}
 type headerWriter struct{ out io.Writer }
 
-func (headerFramer) Reader(rw io.Reader) Reader {
-	return &headerReader{in: bufio.NewReader(rw)}
+func (f headerFramer) Reader(rw io.Reader) Reader {
+	return &headerReader{in: bufio.NewReaderSize(rw, maxHeaderLine), maxSize: f.maxSize}
 }
 
 func (headerFramer) Writer(rw io.Writer) Writer {
@@ -87,8 +137,12 @@ func (r *headerReader) Read(ctx context.Context) (Message, int64, error) {
 	var total, length int64
 	// read the header, stop on the first empty line
 	for {
-		line, err := r.in.ReadString('\n')
-		total += int64(len(line))
+		data, err := r.in.ReadSlice('\n')
+		total += int64(len(data))
+		// rw may be a bufio.Reader with a larger buffer
+		if err == bufio.ErrBufferFull || len(data) > maxHeaderLine {
+			return nil, total, fmt.Errorf("header line longer than %d bytes", maxHeaderLine)
+		}
 		if err != nil {
 			if err == io.EOF {
 				if total == 0 {
@@ -98,7 +152,7 @@ func (r *headerReader) Read(ctx context.Context) (Message, int64, error) {
 			}
 			return nil, total, fmt.Errorf("failed reading header line: %w", err)
 		}
-		line = strings.TrimSpace(line)
+		line := strings.TrimSpace(string(data))
 		// check we have a header line
 		if line == "" {
 			break
@@ -116,6 +170,9 @@ func (r *headerReader) Read(ctx context.Context) (Message, int64, error) {
 			if length <= 0 {
 				return nil, total, fmt.Errorf("invalid Content-Length: %v", length)
 			}
+			if length > r.maxSize {
+				return nil, total, errTooLarge(length, r.maxSize)
+			}
 		default:
 			// ignoring unknown headers
 		}
@@ -151,3 +208,152 @@ func (w *headerWriter) Write(ctx context.Context, msg Message) (int64, error) {
 	}
 	return total, err
 }
+
+// -----------------------------------------------------------------------------
+
+// RawFramer returns a new Framer.
+// The messages are sent as newline-delimited JSON, one message per line.
+// This is the format used by MCP and many scripting clients.
+func RawFramer() Framer { return rawFramer{DefaultMaxMessageSize} }
+
+type rawFramer struct{ maxSize int64 }
+type rawReader struct {
+	in      *bufio.Reader
+	maxSize int64
+}
+type rawWriter struct{ out io.Writer }
+
+func (f rawFramer) Reader(rw io.Reader) Reader {
+	return &rawReader{in: bufio.NewReader(rw), maxSize: f.maxSize}
+}
+
+func (rawFramer) Writer(rw io.Writer) Writer {
+	return &rawWriter{out: rw}
+}
+
+func (r *rawReader) Read(ctx context.Context) (Message, int64, error) {
+	select {
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	default:
+	}
+	var total int64
+	for {
+		var data []byte
+		for {
+			line, err := r.in.ReadSlice('\n')
+			total += int64(len(line))
+			if int64(len(data)+len(line)) > r.maxSize {
+				return nil, total, errTooLarge(int64(len(data)+len(line)), r.maxSize)
+			}
+			data = append(data, line...)
+			if err == nil {
+				break
+			}
+			if err != bufio.ErrBufferFull {
+				if err == io.EOF {
+					if len(bytes.TrimSpace(data)) == 0 {
+						return nil, total, io.EOF
+					}
+					err = io.ErrUnexpectedEOF
+				}
+				return nil, total, err
+			}
+		}
+		if data = bytes.TrimSpace(data); len(data) > 0 { // skip empty lines
+			msg, err := DecodeMessage(data)
+			return msg, total, err
+		}
+	}
+}
+
+func (w *rawWriter) Write(ctx context.Context, msg Message) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+	data, err := EncodeMessage(msg)
+	if err != nil {
+		return 0, fmt.Errorf("marshaling message: %v", err)
+	}
+	// encoding/json escapes newlines in strings, so data is a single line.
+	n, err := w.out.Write(append(data, '\n'))
+	return int64(n), err
+}
+
+// -----------------------------------------------------------------------------
+
+// LengthFramer returns a new Framer.
+// The messages are sent with a 4-byte big-endian length prefix. It is cheaper
+// to parse than headers, which makes it suitable for local IPC.
+func LengthFramer() Framer { return lengthFramer{DefaultMaxMessageSize} }
+
+type lengthFramer struct{ maxSize int64 }
+type lengthReader struct {
+	in      *bufio.Reader
+	maxSize int64
+}
+type lengthWriter struct{ out io.Writer }
+
+func (f lengthFramer) Reader(rw io.Reader) Reader {
+	return &lengthReader{in: bufio.NewReader(rw), maxSize: f.maxSize}
+}
+
+func (lengthFramer) Writer(rw io.Writer) Writer {
+	return &lengthWriter{out: rw}
+}
+
+func (r *lengthReader) Read(ctx context.Context) (Message, int64, error) {
+	select {
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	default:
+	}
+	var prefix [4]byte
+	n, err := io.ReadFull(r.in, prefix[:])
+	total := int64(n)
+	if err != nil {
+		if err == io.EOF {
+			return nil, 0, io.EOF
+		}
+		return nil, total, fmt.Errorf("failed reading length prefix: %w", err)
+	}
+	length := int64(binary.BigEndian.Uint32(prefix[:]))
+	if length == 0 {
+		return nil, total, fmt.Errorf("invalid message length: 0")
+	}
+	if length > r.maxSize {
+		return nil, total, errTooLarge(length, r.maxSize)
+	}
+	data := make([]byte, length)
+	n, err = io.ReadFull(r.in, data)
+	total += int64(n)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, total, err
+	}
+	msg, err := DecodeMessage(data)
+	return msg, total, err
+}
+
+func (w *lengthWriter) Write(ctx context.Context, msg Message) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+	data, err := EncodeMessage(msg)
+	if err != nil {
+		return 0, fmt.Errorf("marshaling message: %v", err)
+	}
+	if int64(len(data)) > math.MaxUint32 {
+		return 0, errTooLarge(int64(len(data)), math.MaxUint32)
+	}
+	buf := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	n, err := w.out.Write(append(buf, data...))
+	return int64(n), err
+}
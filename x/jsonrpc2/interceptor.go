@@ -0,0 +1,329 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsonrpc2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+
+// CallFunc is the signature of Connection.Call.
+type CallFunc func(ctx context.Context, method string, params any) *AsyncCall
+
+// NotifyFunc is the signature of Connection.Notify.
+type NotifyFunc func(ctx context.Context, method string, params any) error
+
+// Interceptor intercepts the requests of a Connection, see
+// ConnectionOptions.Interceptors. Any of its fields may be nil.
+type Interceptor struct {
+	// Handle wraps the Handler of incoming requests. Requests handled by the
+	// Preempter don't go through it.
+	Handle func(next Handler) Handler
+
+	// Preempt wraps the Preempter of incoming requests, if the connection
+	// has one.
+	Preempt func(next Preempter) Preempter
+
+	// Call wraps Connection.Call.
+	Call func(next CallFunc) CallFunc
+
+	// Notify wraps Connection.Notify.
+	Notify func(next NotifyFunc) NotifyFunc
+}
+
+// awaitCall calls done with the error of ac when it is ready.
+func awaitCall(ac *AsyncCall, done func(err error)) {
+	if ac.IsReady() {
+		done(ac.response.Error)
+		return
+	}
+	go func() {
+		<-ac.ready
+		done(ac.response.Error)
+	}()
+}
+
+// -----------------------------------------------------------------------------
+
+// LogEntry is a record of a request logged by LogInterceptor.
+type LogEntry struct {
+	Dir      string        `json:"dir"`  // "in" or "out"
+	Kind     string        `json:"kind"` // "call" or "notify"
+	Method   string        `json:"method"`
+	ID       any           `json:"id,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+func (p *LogEntry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s", p.Dir, p.Kind, p.Method)
+	if p.ID != nil {
+		fmt.Fprintf(&b, " id=%v", p.ID)
+	}
+	fmt.Fprintf(&b, " duration=%v", p.Duration)
+	if p.Error != "" {
+		fmt.Fprintf(&b, " error=%q", p.Error)
+	}
+	return b.String()
+}
+
+// LogInterceptor returns an interceptor that calls log with an entry for each
+// request when it is done. For an asynchronous response, the duration is the
+// time until the Handler returns.
+func LogInterceptor(log func(e *LogEntry)) Interceptor {
+	entry := func(dir, method string, id ID, start time.Time, err error) *LogEntry {
+		e := &LogEntry{Dir: dir, Kind: "notify", Method: method, Duration: time.Since(start)}
+		if id.IsValid() {
+			e.Kind, e.ID = "call", id.value
+		}
+		if err != nil && err != ErrAsyncResponse {
+			e.Error = err.Error()
+		}
+		return e
+	}
+	return Interceptor{
+		Handle: func(next Handler) Handler {
+			return HandlerFunc(func(ctx context.Context, req *Request) (any, error) {
+				start := time.Now()
+				result, err := next.Handle(ctx, req)
+				log(entry("in", req.Method, req.ID, start, err))
+				return result, err
+			})
+		},
+		Call: func(next CallFunc) CallFunc {
+			return func(ctx context.Context, method string, params any) *AsyncCall {
+				start := time.Now()
+				ac := next(ctx, method, params)
+				awaitCall(ac, func(err error) {
+					log(entry("out", method, ac.id, start, err))
+				})
+				return ac
+			}
+		},
+		Notify: func(next NotifyFunc) NotifyFunc {
+			return func(ctx context.Context, method string, params any) error {
+				start := time.Now()
+				err := next(ctx, method, params)
+				log(entry("out", method, ID{}, start, err))
+				return err
+			}
+		},
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// RecoverInterceptor returns an interceptor that converts a panic of the
+// Handler or the Preempter into an ErrInternal error, so that a bad request
+// doesn't bring down the whole process. If onPanic isn't nil, it is called
+// with the value passed to panic and the stack. It should be the last
+// interceptor, so that the others see the error.
+func RecoverInterceptor(onPanic func(v any, stack []byte)) Interceptor {
+	recovered := func(req *Request, result *any, err *error) {
+		if v := recover(); v != nil {
+			if onPanic != nil {
+				onPanic(v, debug.Stack())
+			}
+			*result, *err = nil, fmt.Errorf("%w: panic in %q: %v", ErrInternal, req.Method, v)
+		}
+	}
+	return Interceptor{
+		Handle: func(next Handler) Handler {
+			return HandlerFunc(func(ctx context.Context, req *Request) (result any, err error) {
+				defer recovered(req, &result, &err)
+				return next.Handle(ctx, req)
+			})
+		},
+		Preempt: func(next Preempter) Preempter {
+			return PreempterFunc(func(ctx context.Context, req *Request) (result any, err error) {
+				defer recovered(req, &result, &err)
+				return next.Preempt(ctx, req)
+			})
+		},
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// LatencyBuckets are the upper bounds of the latency histograms of Metrics.
+var LatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// MethodStats is the statistics of a method in a Metrics snapshot.
+type MethodStats struct {
+	Count  int64         `json:"count"`
+	Errors int64         `json:"errors"`
+	Total  time.Duration `json:"total"`
+	Max    time.Duration `json:"max"`
+
+	// Buckets[i] counts requests done within LatencyBuckets[i], and the last
+	// one counts the rest.
+	Buckets []int64 `json:"buckets"`
+}
+
+func (p *MethodStats) add(d time.Duration, failed bool) {
+	if p.Buckets == nil {
+		p.Buckets = make([]int64, len(LatencyBuckets)+1)
+	}
+	p.Count++
+	if failed {
+		p.Errors++
+	}
+	p.Total += d
+	if d > p.Max {
+		p.Max = d
+	}
+	i := sort.Search(len(LatencyBuckets), func(i int) bool { return d <= LatencyBuckets[i] })
+	p.Buckets[i]++
+}
+
+// Metrics collects latency histograms of requests per method. It implements
+// expvar.Var, so it can be published by expvar.Publish:
+//
+//	m := new(jsonrpc2.Metrics)
+//	expvar.Publish("jsonrpc2", m)
+//	opts.Interceptors = append(opts.Interceptors, m.Interceptor())
+//
+// One Metrics can be shared by all connections of a server.
+//
+// Incoming requests of methods the Handler doesn't know, and methods beyond
+// the first MaxMetricsMethods ones, are counted as UnknownMethod, so that a
+// peer can't make it grow without limit.
+type Metrics struct {
+	mutex   sync.Mutex
+	methods map[string]*MethodStats // "in:method" or "out:method"
+}
+
+// UnknownMethod is the method of the requests which Metrics doesn't track
+// separately.
+const UnknownMethod = "<unknown>"
+
+// MaxMetricsMethods is the maximum number of methods tracked by Metrics in
+// each direction.
+const MaxMetricsMethods = 256
+
+func (p *Metrics) add(dir, method string, start time.Time, err error) {
+	d := time.Since(start)
+	if dir == "in" && (errors.Is(err, ErrNotHandled) || errors.Is(err, ErrMethodNotFound)) {
+		method = UnknownMethod
+	}
+	key := dir + ":" + method
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.methods == nil {
+		p.methods = make(map[string]*MethodStats)
+	}
+	stats := p.methods[key]
+	if stats == nil {
+		if p.count(dir) >= MaxMetricsMethods {
+			key = dir + ":" + UnknownMethod
+			stats = p.methods[key]
+		}
+		if stats == nil {
+			stats = new(MethodStats)
+			p.methods[key] = stats
+		}
+	}
+	stats.add(d, err != nil && err != ErrAsyncResponse)
+}
+
+// count returns the number of methods tracked in the direction dir, except
+// UnknownMethod.
+func (p *Metrics) count(dir string) (n int) {
+	for key := range p.methods {
+		if strings.HasPrefix(key, dir+":") && key[len(dir)+1:] != UnknownMethod {
+			n++
+		}
+	}
+	return
+}
+
+// Interceptor returns an interceptor that records requests to p.
+func (p *Metrics) Interceptor() Interceptor {
+	return Interceptor{
+		Handle: func(next Handler) Handler {
+			return HandlerFunc(func(ctx context.Context, req *Request) (any, error) {
+				start := time.Now()
+				result, err := next.Handle(ctx, req)
+				p.add("in", req.Method, start, err)
+				return result, err
+			})
+		},
+		Call: func(next CallFunc) CallFunc {
+			return func(ctx context.Context, method string, params any) *AsyncCall {
+				start := time.Now()
+				ac := next(ctx, method, params)
+				awaitCall(ac, func(err error) {
+					p.add("out", method, start, err)
+				})
+				return ac
+			}
+		},
+		Notify: func(next NotifyFunc) NotifyFunc {
+			return func(ctx context.Context, method string, params any) error {
+				start := time.Now()
+				err := next(ctx, method, params)
+				p.add("out", method, start, err)
+				return err
+			}
+		},
+	}
+}
+
+// Snapshot returns a copy of the statistics, keyed by "in:method" for incoming
+// requests and "out:method" for outgoing ones.
+func (p *Metrics) Snapshot() map[string]*MethodStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	ret := make(map[string]*MethodStats, len(p.methods))
+	for key, stats := range p.methods {
+		v := *stats
+		v.Buckets = append([]int64(nil), stats.Buckets...)
+		ret[key] = &v
+	}
+	return ret
+}
+
+// String returns the snapshot in JSON, which implements expvar.Var.
+func (p *Metrics) String() string {
+	b, err := json.Marshal(p.Snapshot())
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+// -----------------------------------------------------------------------------
@@ -47,6 +47,16 @@ func SetDebug(flags dbgFlags) {
 	debugCall = (flags & (DbgFlagCall | DbgFlagVerbose)) != 0
 }
 
+// MethodCancelRequest is the method of notifications to cancel a call, which
+// is sent when the context of an outgoing call is done before its response
+// arrives. Connections handle it before the Preempter.
+const MethodCancelRequest = "$/cancelRequest"
+
+// CancelParams is the params of $/cancelRequest notifications.
+type CancelParams struct {
+	ID any `json:"id"` // number or string
+}
+
 var (
 	// ErrIdleTimeout is returned when serving timed out waiting for new connections.
 	ErrIdleTimeout = errors.New("timed out waiting for new connections")
@@ -68,6 +68,23 @@ var callTests = []invoker{
 		collect{"a", true, false},
 		collect{"b", true, false},
 	}},
+	batch{"batch", []batchCall{
+		{"one_string", "fish", "got:fish", false},
+		{"join", []string{"a", "b", "c"}, "a/b/c", false},
+		{"peek", nil, 0, false}, // handled by the preempter
+		{"no_args", nil, true, false},
+		{"unknown", nil, nil, true},
+	}},
+	sequence{"batch async", []invoker{
+		async{"a", "fork", "a"},
+		batch{"b", []batchCall{
+			{"set", 2, nil, false},
+			{"add", 3, nil, false},
+			{"get", nil, 5, false},
+		}},
+		notify{"unblock", "a"},
+		collect{"a", true, false},
+	}},
 }
 
 type binder struct {
@@ -124,6 +141,18 @@ type sequence struct {
 
 type echo call
 
+type batchCall struct {
+	method string
+	params any
+	expect any
+	fails  bool
+}
+
+type batch struct {
+	name  string
+	calls []batchCall
+}
+
 type cancelParams struct{ ID int64 }
 
 func Test(t *testing.T, ctx context.Context, listener jsonrpc2.Listener, framer jsonrpc2.Framer, noLeak bool) {
@@ -208,6 +237,29 @@ func (test cancel) Invoke(t *testing.T, ctx context.Context, h *handler) {
 	}
 }
 
+func (test batch) Name() string { return test.name }
+func (test batch) Invoke(t *testing.T, ctx context.Context, h *handler) {
+	calls := make([]jsonrpc2.BatchCall, len(test.calls))
+	for i, c := range test.calls {
+		calls[i] = jsonrpc2.BatchCall{Method: c.method, Params: c.params}
+	}
+	acs := h.conn.CallBatch(ctx, calls)
+	if len(acs) != len(calls) {
+		t.Fatalf("%v:CallBatch returned %d calls, expect %d", test.name, len(acs), len(calls))
+	}
+	for i, c := range test.calls {
+		results := newResults(c.expect)
+		err := acs[i].Await(ctx, results)
+		switch {
+		case c.fails && err == nil:
+			t.Fatalf("%v:%v was supposed to fail", test.name, c.method)
+		case !c.fails && err != nil:
+			t.Fatalf("%v:%v failed: %v", test.name, c.method, err)
+		}
+		verifyResults(t, c.method, results, c.expect)
+	}
+}
+
 func (test sequence) Name() string { return test.name }
 func (test sequence) Invoke(t *testing.T, ctx context.Context, h *handler) {
 	for _, child := range test.tests {
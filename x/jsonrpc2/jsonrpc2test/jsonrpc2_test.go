@@ -5,8 +5,17 @@
 package jsonrpc2test_test
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/goplus/xgo/x/jsonrpc2"
 	"github.com/goplus/xgo/x/jsonrpc2/jsonrpc2test"
@@ -19,3 +28,523 @@ func TestNetPipe(t *testing.T) {
 	listener := jsonrpc2test.NetPipeListener()
 	cases.Test(t, ctx, listener, jsonrpc2.HeaderFramer(), true)
 }
+
+func TestRawFramer(t *testing.T) {
+	ctx := context.Background()
+	cases.Test(t, ctx, jsonrpc2test.NetPipeListener(), jsonrpc2.RawFramer(), true)
+}
+
+func TestLengthFramer(t *testing.T) {
+	ctx := context.Background()
+	cases.Test(t, ctx, jsonrpc2test.NetPipeListener(), jsonrpc2.LengthFramer(), true)
+}
+
+func TestLimitFramer(t *testing.T) {
+	ctx := context.Background()
+	var trace bytes.Buffer
+	framers := []jsonrpc2.Framer{
+		jsonrpc2.HeaderFramer(), jsonrpc2.RawFramer(), jsonrpc2.LengthFramer(),
+		jsonrpc2.TraceFramer(jsonrpc2.HeaderFramer(), &trace),
+	}
+	for _, f := range framers {
+		var buf bytes.Buffer
+		w := f.Writer(&buf)
+		small, _ := jsonrpc2.NewNotification("small", nil)
+		large, _ := jsonrpc2.NewNotification("large", strings.Repeat("x", 200))
+		if _, err := w.Write(ctx, small); err != nil {
+			t.Fatalf("%T: Write: %v", f, err)
+		}
+		if _, err := w.Write(ctx, large); err != nil {
+			t.Fatalf("%T: Write: %v", f, err)
+		}
+		limited, err := jsonrpc2.LimitFramer(f, 100)
+		if err != nil {
+			t.Fatalf("%T: LimitFramer: %v", f, err)
+		}
+		r := limited.Reader(&buf)
+		msg, _, err := r.Read(ctx)
+		if err != nil {
+			t.Fatalf("%T: Read: %v", f, err)
+		}
+		if req, ok := msg.(*jsonrpc2.Request); !ok || req.Method != "small" {
+			t.Fatalf("%T: Read: got %#v", f, msg)
+		}
+		if _, _, err = r.Read(ctx); !errors.Is(err, jsonrpc2.ErrMessageTooLarge) {
+			t.Fatalf("%T: Read large message: %v", f, err)
+		}
+	}
+
+	// the limited copy of a TraceFramer records to the same file
+	records, err := jsonrpc2.ReadRecords(&trace)
+	if err != nil {
+		t.Fatal("ReadRecords:", err)
+	}
+	if len(records) != 3 || records[2].Dir != "in" {
+		t.Fatalf("ReadRecords: %d records", len(records))
+	}
+
+	// other framers can't be limited
+	if _, err := jsonrpc2.LimitFramer(otherFramer{jsonrpc2.HeaderFramer()}, 100); err == nil {
+		t.Fatal("LimitFramer: no error for an unknown framer")
+	}
+}
+
+type otherFramer struct{ jsonrpc2.Framer }
+
+func TestHeaderLineTooLong(t *testing.T) {
+	ctx := context.Background()
+	for _, n := range []int{5000, 100 << 10} {
+		r := jsonrpc2.HeaderFramer().Reader(strings.NewReader("X-Long: " + strings.Repeat("x", n) + "\r\n"))
+		if _, _, err := r.Read(ctx); err == nil || !strings.Contains(err.Error(), "header line longer than") {
+			t.Fatalf("Read header line of %d bytes: %v", n, err)
+		}
+	}
+
+	// a buffered reader with a larger buffer
+	in := bufio.NewReaderSize(strings.NewReader("X-Long: "+strings.Repeat("x", 5000)+"\r\n"), 8192)
+	if _, _, err := jsonrpc2.HeaderFramer().Reader(in).Read(ctx); err == nil || !strings.Contains(err.Error(), "header line longer than") {
+		t.Fatalf("Read header line from a bufio.Reader: %v", err)
+	}
+}
+
+func TestNetListener(t *testing.T) {
+	ctx := context.Background()
+	listener, err := jsonrpc2.NetListener(ctx, "tcp", "127.0.0.1:0", jsonrpc2.NetListenOptions{})
+	if err != nil {
+		t.Fatal("jsonrpc2.NetListener:", err)
+	}
+	cases.Test(t, ctx, listener, jsonrpc2.HeaderFramer(), true)
+}
+
+func TestNetListenerUnix(t *testing.T) {
+	ctx := context.Background()
+	sock := filepath.Join(t.TempDir(), "test.sock")
+	listener, err := jsonrpc2.NetListener(ctx, "unix", sock, jsonrpc2.NetListenOptions{})
+	if err != nil {
+		t.Skip("jsonrpc2.NetListener:", err)
+	}
+	cases.Test(t, ctx, listener, jsonrpc2.HeaderFramer(), true)
+	if _, err := os.Stat(sock); !os.IsNotExist(err) {
+		t.Fatal("socket file not removed:", err)
+	}
+}
+
+func TestBatchMessage(t *testing.T) {
+	data := []byte(` [{"jsonrpc":"2.0","id":1,"method":"sum","params":[1,2]},{"jsonrpc":"2.0","method":"notify"}]`)
+	msg, err := jsonrpc2.DecodeMessage(data)
+	if err != nil {
+		t.Fatal("DecodeMessage:", err)
+	}
+	batch, ok := msg.(jsonrpc2.Batch)
+	if !ok || len(batch) != 2 {
+		t.Fatalf("DecodeMessage: got %#v, expect a batch of 2 requests", msg)
+	}
+	if req, ok := batch[0].(*jsonrpc2.Request); !ok || !req.IsCall() || req.Method != "sum" {
+		t.Fatalf("batch[0]: got %#v", batch[0])
+	}
+	if req, ok := batch[1].(*jsonrpc2.Request); !ok || req.IsCall() || req.Method != "notify" {
+		t.Fatalf("batch[1]: got %#v", batch[1])
+	}
+
+	resp, _ := jsonrpc2.NewResponse(jsonrpc2.Int64ID(1), 3, nil)
+	ret, err := jsonrpc2.EncodeMessage(jsonrpc2.Batch{resp})
+	if err != nil {
+		t.Fatal("EncodeMessage:", err)
+	}
+	if string(ret) != `[{"jsonrpc":"2.0","id":1,"result":3}]` {
+		t.Fatal("EncodeMessage:", string(ret))
+	}
+
+	if _, err = jsonrpc2.DecodeMessage([]byte(`[]`)); !errors.Is(err, jsonrpc2.ErrInvalidRequest) {
+		t.Fatal("DecodeMessage empty batch:", err)
+	}
+	msg, err = jsonrpc2.DecodeMessage([]byte(`[[], 1]`))
+	if err != nil {
+		t.Fatal("DecodeMessage invalid elements:", err)
+	}
+	if batch, ok := msg.(jsonrpc2.Batch); !ok || len(batch) != 2 {
+		t.Fatalf("DecodeMessage invalid elements: got %#v", msg)
+	} else if elem, ok := batch[0].(*jsonrpc2.InvalidMessage); !ok || string(elem.Raw) != "[]" {
+		t.Fatalf("batch[0]: got %#v", batch[0])
+	}
+}
+
+func TestBatchInvalidElements(t *testing.T) {
+	ctx := context.Background()
+	listener := jsonrpc2test.NetPipeListener()
+	handler := jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+		if req.Method == "hello" {
+			return "world", nil
+		}
+		return nil, jsonrpc2.ErrNotHandled
+	})
+	server := jsonrpc2.NewServer(ctx, listener, jsonrpc2.BinderFunc(
+		func(ctx context.Context, c *jsonrpc2.Connection) jsonrpc2.ConnectionOptions {
+			return jsonrpc2.ConnectionOptions{Handler: handler}
+		}))
+	defer func() {
+		listener.Close()
+		server.Wait()
+	}()
+
+	rwc, err := listener.Dialer().Dial(ctx)
+	if err != nil {
+		t.Fatal("Dial:", err)
+	}
+	defer rwc.Close()
+	msg, err := jsonrpc2.DecodeMessage([]byte(`[
+		{"jsonrpc":"2.0","id":1,"method":"hello"},
+		{"foo":"boo"},
+		{"jsonrpc":"2.0","method":"notify"},
+		1
+	]`))
+	if err != nil {
+		t.Fatal("DecodeMessage:", err)
+	}
+	framer := jsonrpc2.HeaderFramer()
+	if _, err = framer.Writer(rwc).Write(ctx, msg); err != nil {
+		t.Fatal("Write:", err)
+	}
+	msg, _, err = framer.Reader(rwc).Read(ctx)
+	if err != nil {
+		t.Fatal("Read:", err)
+	}
+	batch, ok := msg.(jsonrpc2.Batch)
+	if !ok || len(batch) != 3 {
+		t.Fatalf("Read: got %#v, expect 3 responses", msg)
+	}
+	var results, invalids int
+	for _, m := range batch {
+		resp, ok := m.(*jsonrpc2.Response)
+		if !ok {
+			t.Fatalf("Read: got %#v in the batch", m)
+		}
+		switch {
+		case resp.ID == jsonrpc2.Int64ID(1) && string(resp.Result) == `"world"`:
+			results++
+		case !resp.ID.IsValid() && errors.Is(resp.Error, jsonrpc2.ErrInvalidRequest):
+			invalids++
+		default:
+			t.Fatalf("Read: unexpected response %#v", resp)
+		}
+	}
+	if results != 1 || invalids != 2 {
+		t.Fatalf("Read: got %d results and %d errors", results, invalids)
+	}
+}
+
+func TestInterceptors(t *testing.T) {
+	ctx := context.Background()
+	listener := jsonrpc2test.NetPipeListener()
+
+	var mutex sync.Mutex
+	var logs []string
+	logger := jsonrpc2.LogInterceptor(func(e *jsonrpc2.LogEntry) {
+		mutex.Lock()
+		logs = append(logs, e.Dir+" "+e.Method)
+		mutex.Unlock()
+	})
+	var panicked any
+	recoverer := jsonrpc2.RecoverInterceptor(func(v any, stack []byte) { panicked = v })
+	metrics := new(jsonrpc2.Metrics)
+	release := make(chan struct{})
+	handler := jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+		switch req.Method {
+		case "hello":
+			return "world", nil
+		case "boom":
+			panic("boom")
+		case "wait":
+			<-release
+			return true, nil
+		}
+		return nil, jsonrpc2.ErrNotHandled
+	})
+	server := jsonrpc2.NewServer(ctx, listener, jsonrpc2.BinderFunc(
+		func(ctx context.Context, c *jsonrpc2.Connection) jsonrpc2.ConnectionOptions {
+			return jsonrpc2.ConnectionOptions{
+				Handler: handler,
+				Preempter: jsonrpc2.PreempterFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+					if req.Method == "preboom" {
+						panic("preboom")
+					}
+					return nil, jsonrpc2.ErrNotHandled
+				}),
+				Interceptors: []jsonrpc2.Interceptor{logger, metrics.Interceptor(), recoverer},
+			}
+		}))
+	defer func() {
+		listener.Close()
+		server.Wait()
+	}()
+
+	client, err := jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.BinderFunc(
+		func(ctx context.Context, c *jsonrpc2.Connection) jsonrpc2.ConnectionOptions {
+			return jsonrpc2.ConnectionOptions{
+				Interceptors: []jsonrpc2.Interceptor{metrics.Interceptor()},
+			}
+		}), nil)
+	if err != nil {
+		t.Fatal("Dial:", err)
+	}
+	defer client.Close()
+
+	var ret string
+	if err = client.Call(ctx, "hello", nil).Await(ctx, &ret); err != nil || ret != "world" {
+		t.Fatal("hello:", ret, err)
+	}
+	if err = client.Call(ctx, "boom", nil).Await(ctx, nil); !errors.Is(err, jsonrpc2.ErrInternal) {
+		t.Fatal("boom:", err)
+	}
+	if panicked != "boom" {
+		t.Fatal("onPanic:", panicked)
+	}
+	if err = client.Call(ctx, "preboom", nil).Await(ctx, nil); !errors.Is(err, jsonrpc2.ErrInternal) {
+		t.Fatal("preboom:", err)
+	}
+	if panicked != "preboom" {
+		t.Fatal("onPanic:", panicked)
+	}
+	for _, method := range []string{"nosuch1", "nosuch2"} {
+		if err = client.Call(ctx, method, nil).Await(ctx, nil); !errors.Is(err, jsonrpc2.ErrMethodNotFound) {
+			t.Fatal(method+":", err)
+		}
+	}
+
+	wait := client.Call(ctx, "wait", nil)
+	stats := client.InFlight()
+	if len(stats.Outgoing) != 1 || stats.Outgoing[0].Method != "wait" {
+		t.Fatalf("InFlight: %+v", stats)
+	}
+	close(release)
+	if err = wait.Await(ctx, nil); err != nil {
+		t.Fatal("wait:", err)
+	}
+	if stats = client.InFlight(); len(stats.Outgoing) != 0 {
+		t.Fatalf("InFlight: %+v", stats)
+	}
+
+	snapshot := metrics.Snapshot()
+	for _, key := range []string{"in:hello", "in:boom", "in:wait"} {
+		if stats := snapshot[key]; stats == nil || stats.Count != 1 {
+			t.Fatalf("Metrics %s: %+v", key, stats)
+		}
+	}
+	if stats := snapshot["in:boom"]; stats.Errors != 1 {
+		t.Fatalf("Metrics in:boom: %+v", stats)
+	}
+	if stats := snapshot["out:hello"]; stats == nil || stats.Count != 1 {
+		t.Fatalf("Metrics out:hello: %+v", stats)
+	}
+	if stats := snapshot["in:"+jsonrpc2.UnknownMethod]; stats == nil || stats.Count != 2 || snapshot["in:nosuch1"] != nil {
+		t.Fatalf("Metrics of unknown methods: %+v", stats)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if strings.Join(logs, ",") != "in hello,in boom,in nosuch1,in nosuch2,in wait" {
+		t.Fatal("logs:", logs)
+	}
+}
+
+func TestMetricsMaxMethods(t *testing.T) {
+	ctx := context.Background()
+	listener := jsonrpc2test.NetPipeListener()
+	metrics := new(jsonrpc2.Metrics)
+	handler := jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+		return true, nil
+	})
+	server := jsonrpc2.NewServer(ctx, listener, jsonrpc2.BinderFunc(
+		func(ctx context.Context, c *jsonrpc2.Connection) jsonrpc2.ConnectionOptions {
+			return jsonrpc2.ConnectionOptions{Handler: handler, Interceptors: []jsonrpc2.Interceptor{metrics.Interceptor()}}
+		}))
+	defer func() {
+		listener.Close()
+		server.Wait()
+	}()
+	client, err := jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.BinderFunc(
+		func(ctx context.Context, c *jsonrpc2.Connection) jsonrpc2.ConnectionOptions {
+			return jsonrpc2.ConnectionOptions{}
+		}), nil)
+	if err != nil {
+		t.Fatal("Dial:", err)
+	}
+	defer client.Close()
+
+	const extra = 10
+	for i := 0; i < jsonrpc2.MaxMetricsMethods+extra; i++ {
+		if err = client.Call(ctx, fmt.Sprint("m", i), nil).Await(ctx, nil); err != nil {
+			t.Fatal("Call:", err)
+		}
+	}
+	snapshot := metrics.Snapshot()
+	if len(snapshot) != jsonrpc2.MaxMetricsMethods+1 {
+		t.Fatalf("Metrics: %d methods", len(snapshot))
+	}
+	if stats := snapshot["in:"+jsonrpc2.UnknownMethod]; stats == nil || stats.Count != extra {
+		t.Fatalf("Metrics of unknown methods: %+v", stats)
+	}
+}
+
+func TestCancelRequest(t *testing.T) {
+	ctx := context.Background()
+	listener := jsonrpc2test.NetPipeListener()
+
+	started := make(chan struct{})
+	cancelled := make(chan error, 1) // why the handler of wait was cancelled
+	handler := jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+		switch req.Method {
+		case "wait", "slow":
+			close(started)
+			<-ctx.Done()
+			cancelled <- ctx.Err()
+			return nil, ctx.Err()
+		case "hello":
+			return "world", nil
+		}
+		return nil, jsonrpc2.ErrNotHandled
+	})
+	server := jsonrpc2.NewServer(ctx, listener, jsonrpc2.BinderFunc(
+		func(ctx context.Context, c *jsonrpc2.Connection) jsonrpc2.ConnectionOptions {
+			return jsonrpc2.ConnectionOptions{
+				Handler:        handler,
+				MethodTimeouts: map[string]time.Duration{"slow": 50 * time.Millisecond, "": time.Minute},
+			}
+		}))
+	defer func() {
+		listener.Close()
+		server.Wait()
+	}()
+
+	client, err := jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.BinderFunc(
+		func(ctx context.Context, c *jsonrpc2.Connection) jsonrpc2.ConnectionOptions {
+			return jsonrpc2.ConnectionOptions{}
+		}), nil)
+	if err != nil {
+		t.Fatal("Dial:", err)
+	}
+	defer client.Close()
+
+	// the server handler is cancelled by $/cancelRequest, not by its timeout
+	callCtx, cancel := context.WithCancel(ctx)
+	wait := client.Call(callCtx, "wait", nil)
+	<-started
+	cancel()
+	if err = wait.Await(ctx, nil); !errors.Is(err, jsonrpc2.ErrRequestCancelled) {
+		t.Fatal("wait cancelled:", err)
+	}
+	if err = <-cancelled; err != context.Canceled {
+		t.Fatal("wait cancelled by", err)
+	}
+
+	// the server handler is cancelled by its timeout
+	started = make(chan struct{})
+	if err = client.Call(ctx, "slow", nil).Await(ctx, nil); !errors.Is(err, jsonrpc2.ErrRequestCancelled) {
+		t.Fatal("slow timeout:", err)
+	}
+	if err = <-cancelled; err != context.DeadlineExceeded {
+		t.Fatal("slow cancelled by", err)
+	}
+
+	var ret string
+	if err = client.Call(ctx, "hello", nil).Await(ctx, &ret); err != nil || ret != "world" {
+		t.Fatal("hello:", ret, err)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	ctx := context.Background()
+	newServer := func(framer jsonrpc2.Framer, step int) (jsonrpc2.Listener, *jsonrpc2.Server) {
+		n := 0
+		handler := jsonrpc2.HandlerFunc(func(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+			switch req.Method {
+			case "add":
+				n += step
+				return nil, nil
+			case "get":
+				return n, nil
+			}
+			return nil, jsonrpc2.ErrNotHandled
+		})
+		listener := jsonrpc2test.NetPipeListener()
+		server := jsonrpc2.NewServer(ctx, listener, jsonrpc2.BinderFunc(
+			func(ctx context.Context, c *jsonrpc2.Connection) jsonrpc2.ConnectionOptions {
+				return jsonrpc2.ConnectionOptions{Framer: framer, Handler: handler}
+			}))
+		return listener, server
+	}
+
+	// record two sessions at the same time, with the same call IDs
+	var buf bytes.Buffer
+	listener, server := newServer(jsonrpc2.TraceFramer(jsonrpc2.HeaderFramer(), &buf), 1)
+	dial := func() *jsonrpc2.Connection {
+		client, err := jsonrpc2.Dial(ctx, listener.Dialer(), jsonrpc2.BinderFunc(
+			func(ctx context.Context, c *jsonrpc2.Connection) jsonrpc2.ConnectionOptions {
+				return jsonrpc2.ConnectionOptions{}
+			}), nil)
+		if err != nil {
+			t.Fatal("Dial:", err)
+		}
+		return client
+	}
+	client1, client2 := dial(), dial()
+	client1.Notify(ctx, "add", nil)
+	client1.Notify(ctx, "add", nil)
+	var n int
+	if err := client1.Call(ctx, "get", nil).Await(ctx, &n); err != nil || n != 2 {
+		t.Fatal("get:", n, err)
+	}
+	client2.Notify(ctx, "add", nil)
+	if err := client2.Call(ctx, "get", nil).Await(ctx, &n); err != nil || n != 3 {
+		t.Fatal("get:", n, err)
+	}
+	client1.Close()
+	client2.Close()
+	listener.Close()
+	server.Wait()
+
+	records, err := jsonrpc2.ReadRecords(&buf)
+	if err != nil {
+		t.Fatal("ReadRecords:", err)
+	}
+	if len(records) != 7 || records[0].Dir != "in" || records[6].Dir != "out" {
+		t.Fatalf("ReadRecords: got %d records", len(records))
+	}
+	if records[0].Conn == records[6].Conn {
+		t.Fatalf("ReadRecords: both sessions on connection %d", records[0].Conn)
+	}
+
+	// replay it
+	replay := func(step int) []*jsonrpc2.ReplayDiff {
+		listener, server := newServer(nil, step)
+		defer func() {
+			listener.Close()
+			server.Wait()
+		}()
+		diffs, err := jsonrpc2.Replay(ctx, listener.Dialer(), records, &jsonrpc2.ReplayOptions{Timeout: time.Second})
+		if err != nil {
+			t.Fatal("Replay:", err)
+		}
+		return diffs
+	}
+	if diffs := replay(1); len(diffs) != 0 {
+		t.Fatal("Replay:", diffs)
+	}
+	diffs := replay(2)
+	if len(diffs) != 2 || diffs[0].Method != "get" || string(diffs[0].Got) != `{"jsonrpc":"2.0","id":1,"result":4}` ||
+		diffs[1].Method != "get" || string(diffs[1].Got) != `{"jsonrpc":"2.0","id":1,"result":6}` ||
+		diffs[0].Conn != records[0].Conn || diffs[1].Conn != records[6].Conn {
+		t.Fatal("Replay:", diffs)
+	}
+}
+
+func TestReadRecordsLongLine(t *testing.T) {
+	msg := `{"jsonrpc":"2.0","method":"long","params":"` + strings.Repeat(`\"`, 100<<10) + `"}`
+	data := `{"conn":1,"dir":"in","msg":` + msg + "}\n\n" + `{"conn":1,"dir":"out","msg":{"jsonrpc":"2.0","method":"end"}}`
+	records, err := jsonrpc2.ReadRecords(strings.NewReader(data))
+	if err != nil {
+		t.Fatal("ReadRecords:", err)
+	}
+	if len(records) != 2 || string(records[0].Message) != msg || records[1].Dir != "out" {
+		t.Fatalf("ReadRecords: got %d records", len(records))
+	}
+}
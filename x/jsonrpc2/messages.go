@@ -21,6 +21,7 @@
 package jsonrpc2
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -33,8 +34,8 @@ type ID struct {
 
 // Message is the interface to all jsonrpc2 message types.
 // They share no common functionality, but are a closed set of concrete types
-// that are allowed to implement this interface. The message types are *Request
-// and *Response.
+// that are allowed to implement this interface. The message types are *Request,
+// *Response, Batch and *InvalidMessage.
 type Message interface {
 	// marshal builds the wire form from the API form.
 	// It is private, which makes the set of Message implementations closed.
@@ -64,6 +65,20 @@ type Response struct {
 	ID ID
 }
 
+// Batch is a Message holding several requests or responses, which are sent
+// in one JSON array. See https://www.jsonrpc.org/specification#batch.
+type Batch []Message
+
+// InvalidMessage is an element of a Batch which is neither a valid request nor
+// a valid response. As JSON-RPC 2.0 requires, it is responded with an Invalid
+// Request error, while the other elements of the batch are still processed.
+type InvalidMessage struct {
+	// Raw is the JSON value of the element.
+	Raw json.RawMessage
+	// Err is why the element is invalid.
+	Err error
+}
+
 // StringID creates a new string request identifier.
 func StringID(s string) ID { return ID{value: s} }
 
@@ -108,10 +123,26 @@ func NewResponse(id ID, result any, rerr error) (*Response, error) {
 
 func (msg *Response) marshal(to *wireCombined) {
 	to.ID = msg.ID.value
+	if to.ID == nil { // the id of an invalid request, which must be null
+		to.ID = json.RawMessage("null")
+	}
 	to.Error = toWireError(msg.Error)
 	to.Result = msg.Result
 }
 
+// marshal is never called: EncodeMessage encodes a batch as an array of its
+// elements.
+func (msg Batch) marshal(to *wireCombined) {}
+
+// marshal is never called: EncodeMessage encodes an InvalidMessage as its Raw
+// value.
+func (msg *InvalidMessage) marshal(to *wireCombined) {}
+
+// Error returns the Invalid Request error to respond msg with.
+func (msg *InvalidMessage) Error() error {
+	return fmt.Errorf("%w: %v", ErrInvalidRequest, msg.Err)
+}
+
 func toWireError(err error) *wireError {
 	if err == nil {
 		// no error, the response is complete
@@ -132,16 +163,57 @@ func toWireError(err error) *wireError {
 }
 
 func EncodeMessage(msg Message) ([]byte, error) {
-	wire := wireCombined{VersionTag: wireVersion}
-	msg.marshal(&wire)
-	data, err := json.Marshal(&wire)
+	var data []byte
+	var err error
+	if batch, ok := msg.(Batch); ok {
+		elems := make([]any, len(batch))
+		for i, msg := range batch {
+			if msg, ok := msg.(*InvalidMessage); ok {
+				elems[i] = msg.Raw
+				continue
+			}
+			wire := &wireCombined{VersionTag: wireVersion}
+			msg.marshal(wire)
+			elems[i] = wire
+		}
+		data, err = json.Marshal(elems)
+	} else {
+		wire := wireCombined{VersionTag: wireVersion}
+		msg.marshal(&wire)
+		data, err = json.Marshal(&wire)
+	}
 	if err != nil {
 		return data, fmt.Errorf("marshaling jsonrpc message: %w", err)
 	}
 	return data, nil
 }
 
+// DecodeMessage decodes a message or a batch of messages. The invalid elements
+// of a batch are decoded as *InvalidMessage instead of failing the batch.
 func DecodeMessage(data []byte) (Message, error) {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	if len(data) == 0 || data[0] != '[' {
+		return decodeMessage(data)
+	}
+	var elems []json.RawMessage
+	if err := json.Unmarshal(data, &elems); err != nil {
+		return nil, fmt.Errorf("unmarshaling jsonrpc batch: %w", err)
+	}
+	if len(elems) == 0 {
+		return nil, fmt.Errorf("%w: empty batch", ErrInvalidRequest)
+	}
+	batch := make(Batch, len(elems))
+	for i, elem := range elems {
+		msg, err := decodeMessage(elem)
+		if err != nil {
+			msg = &InvalidMessage{Raw: elem, Err: err}
+		}
+		batch[i] = msg
+	}
+	return batch, nil
+}
+
+func decodeMessage(data []byte) (Message, error) {
 	msg := wireCombined{}
 	if err := json.Unmarshal(data, &msg); err != nil {
 		return nil, fmt.Errorf("unmarshaling jsonrpc message: %w", err)
@@ -149,18 +221,9 @@ func DecodeMessage(data []byte) (Message, error) {
 	if msg.VersionTag != wireVersion {
 		return nil, fmt.Errorf("invalid message version tag %s expected %s", msg.VersionTag, wireVersion)
 	}
-	id := ID{}
-	switch v := msg.ID.(type) {
-	case nil:
-	case float64:
-		// coerce the id type to int64 if it is float64, the spec does not allow fractional parts
-		id = Int64ID(int64(v))
-	case int64:
-		id = Int64ID(v)
-	case string:
-		id = StringID(v)
-	default:
-		return nil, fmt.Errorf("invalid message id type <%T>%v", v, v)
+	id, err := makeID(msg.ID)
+	if err != nil {
+		return nil, err
 	}
 	if msg.Method != "" {
 		// has a method, must be a call
@@ -170,8 +233,9 @@ func DecodeMessage(data []byte) (Message, error) {
 			Params: msg.Params,
 		}, nil
 	}
-	// no method, should be a response
-	if !id.IsValid() {
+	// no method, should be a response, whose id is null only if it reports
+	// an invalid request
+	if !id.IsValid() && msg.Error == nil {
 		return nil, ErrInvalidRequest
 	}
 	resp := &Response{
@@ -185,6 +249,23 @@ func DecodeMessage(data []byte) (Message, error) {
 	return resp, nil
 }
 
+// makeID makes an ID from its decoded JSON value.
+func makeID(v any) (ID, error) {
+	switch v := v.(type) {
+	case nil:
+	case float64:
+		// coerce the id type to int64 if it is float64, the spec does not allow fractional parts
+		return Int64ID(int64(v)), nil
+	case int64:
+		return Int64ID(v), nil
+	case string:
+		return StringID(v), nil
+	default:
+		return ID{}, fmt.Errorf("invalid message id type <%T>%v", v, v)
+	}
+	return ID{}, nil
+}
+
 func marshalToRaw(obj any) (json.RawMessage, error) {
 	if obj == nil {
 		return nil, nil
@@ -0,0 +1,369 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsonrpc2
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+
+// Record is a message recorded by TraceFramer, one per line of a JSONL file.
+type Record struct {
+	Time    time.Time       `json:"time"`
+	Conn    int64           `json:"conn"` // ID of the connection, from 1
+	Dir     string          `json:"dir"`  // "in" (read) or "out" (written)
+	Message json.RawMessage `json:"msg"`
+}
+
+// TraceFramer returns a framer which wraps f and records every message read
+// or written by its connections to w, see Record. The reader and the writer
+// of a connection must be created from the same stream to get the same
+// connection ID, as Connection does.
+func TraceFramer(f Framer, w io.Writer) Framer {
+	return &traceFramer{Framer: f, log: &traceLog{out: w, conns: make(map[any]int64)}}
+}
+
+type traceFramer struct {
+	Framer
+	log *traceLog
+}
+
+// traceLog is the file shared by a TraceFramer and its limited copies.
+type traceLog struct {
+	mutex  sync.Mutex
+	out    io.Writer
+	conns  map[any]int64 // stream => ID of the connections with a reader or a writer only
+	lastID int64
+}
+
+// connID returns the ID of the connection of stream rw.
+func (p *traceLog) connID(rw any) int64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if rw != nil && reflect.TypeOf(rw).Comparable() {
+		if id, ok := p.conns[rw]; ok { // the other half of the connection
+			delete(p.conns, rw)
+			return id
+		}
+		p.lastID++
+		p.conns[rw] = p.lastID
+		return p.lastID
+	}
+	p.lastID++
+	return p.lastID
+}
+
+type traceReader struct {
+	Reader
+	f  *traceFramer
+	id int64
+}
+
+type traceWriter struct {
+	Writer
+	f  *traceFramer
+	id int64
+}
+
+func (f *traceFramer) Reader(rw io.Reader) Reader {
+	return &traceReader{f.Framer.Reader(rw), f, f.log.connID(rw)}
+}
+
+func (f *traceFramer) Writer(rw io.Writer) Writer {
+	return &traceWriter{f.Framer.Writer(rw), f, f.log.connID(rw)}
+}
+
+func (f *traceFramer) limit(maxSize int64) Framer {
+	inner, err := LimitFramer(f.Framer, maxSize)
+	if err != nil {
+		return nil
+	}
+	return &traceFramer{Framer: inner, log: f.log}
+}
+
+func (f *traceFramer) record(id int64, dir string, msg Message) {
+	data, err := EncodeMessage(msg)
+	if err != nil {
+		return
+	}
+	line, err := json.Marshal(&Record{Time: time.Now(), Conn: id, Dir: dir, Message: data})
+	if err != nil {
+		return
+	}
+	f.log.mutex.Lock()
+	defer f.log.mutex.Unlock()
+	f.log.out.Write(append(line, '\n'))
+}
+
+func (r *traceReader) Read(ctx context.Context) (Message, int64, error) {
+	msg, n, err := r.Reader.Read(ctx)
+	if err == nil {
+		r.f.record(r.id, "in", msg)
+	}
+	return msg, n, err
+}
+
+func (w *traceWriter) Write(ctx context.Context, msg Message) (int64, error) {
+	w.f.record(w.id, "out", msg)
+	return w.Writer.Write(ctx, msg)
+}
+
+// ReadRecords reads the records saved by TraceFramer. The lines aren't
+// limited in size, as a record of a message is larger than the message.
+func ReadRecords(r io.Reader) ([]*Record, error) {
+	var ret []*Record
+	in := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := in.ReadBytes('\n')
+		if len(bytes.TrimSpace(data)) > 0 {
+			rec := new(Record)
+			if err := json.Unmarshal(data, rec); err != nil {
+				return nil, fmt.Errorf("record %d: %w", line, err)
+			}
+			ret = append(ret, rec)
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return ret, err
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// ReplayDiff is a response that differs from the recorded one.
+type ReplayDiff struct {
+	Conn   int64           `json:"conn"`
+	ID     any             `json:"id"`
+	Method string          `json:"method"`
+	Want   json.RawMessage `json:"want"`
+	Got    json.RawMessage `json:"got"` // nil if no response
+}
+
+func (p *ReplayDiff) String() string {
+	return fmt.Sprintf("%s (conn %d, id %v):\n\twant: %s\n\tgot:  %s", p.Method, p.Conn, p.ID, p.Want, p.Got)
+}
+
+// ReplayOptions holds the options of Replay.
+type ReplayOptions struct {
+	// Framer is the framer to talk to the server. If nil, HeaderFramer will
+	// be used.
+	Framer Framer
+
+	// Timeout is how long to wait for a response. Default is 10 seconds.
+	Timeout time.Duration
+}
+
+// Replay plays the client sessions recorded at the server side to the server
+// dialed by dialer, and returns the responses which differ from the recorded
+// ones. Each recorded connection is replayed on a new connection, one after
+// another in the order they started. The messages of the client are sent in
+// the recorded order, and each one is sent after the responses recorded
+// before it arrive, so the replay is deterministic. Notifications of the
+// server are ignored.
+func Replay(ctx context.Context, dialer Dialer, records []*Record, opts *ReplayOptions) (diffs []*ReplayDiff, err error) {
+	var o ReplayOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Framer == nil {
+		o.Framer = HeaderFramer()
+	}
+	if o.Timeout == 0 {
+		o.Timeout = 10 * time.Second
+	}
+	for _, session := range replaySessions(records) {
+		var ret []*ReplayDiff
+		ret, err = replaySession(ctx, dialer, session, &o)
+		diffs = append(diffs, ret...)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// replaySessions groups records by connection, in the order of the first
+// record of each connection.
+func replaySessions(records []*Record) (sessions [][]*Record) {
+	index := make(map[int64]int) // conn => index of its session
+	for _, rec := range records {
+		i, ok := index[rec.Conn]
+		if !ok {
+			i = len(sessions)
+			index[rec.Conn] = i
+			sessions = append(sessions, nil)
+		}
+		sessions[i] = append(sessions[i], rec)
+	}
+	return
+}
+
+func replaySession(ctx context.Context, dialer Dialer, records []*Record, o *ReplayOptions) (diffs []*ReplayDiff, err error) {
+	rwc, err := dialer.Dial(ctx)
+	if err != nil {
+		return
+	}
+	defer rwc.Close()
+
+	got := newReplayResponses()
+	go got.read(ctx, o.Framer.Reader(rwc))
+	w := o.Framer.Writer(rwc)
+	methods := make(map[ID]string) // id => method of the calls sent
+	for _, rec := range records {
+		msg, err := DecodeMessage(rec.Message)
+		if err != nil {
+			return diffs, err
+		}
+		msgs := []Message{msg}
+		if batch, ok := msg.(Batch); ok {
+			msgs = batch
+		}
+		switch rec.Dir {
+		case "in":
+			for _, m := range msgs {
+				if req, ok := m.(*Request); ok && req.IsCall() {
+					methods[req.ID] = req.Method
+				}
+			}
+			if _, err = w.Write(ctx, msg); err != nil {
+				return diffs, err
+			}
+		case "out":
+			for _, m := range msgs {
+				want, ok := m.(*Response)
+				if !ok {
+					continue
+				}
+				resp := got.wait(want.ID, o.Timeout)
+				if diff := diffResponse(want, resp); diff != nil {
+					diff.Conn, diff.Method = rec.Conn, methods[want.ID]
+					diffs = append(diffs, diff)
+				}
+			}
+		}
+	}
+	return
+}
+
+// diffResponse compares the response resp with the recorded one, ignoring
+// the JSON formatting.
+func diffResponse(want, resp *Response) *ReplayDiff {
+	wantData, _ := EncodeMessage(want)
+	diff := &ReplayDiff{ID: want.ID.value, Want: wantData}
+	if resp == nil {
+		return diff
+	}
+	diff.Got, _ = EncodeMessage(resp)
+	var v1, v2 any
+	if json.Unmarshal(diff.Want, &v1) == nil && json.Unmarshal(diff.Got, &v2) == nil && reflect.DeepEqual(v1, v2) {
+		return nil
+	}
+	return diff
+}
+
+// replayResponses collects the responses read in a replay.
+type replayResponses struct {
+	mutex sync.Mutex
+	resps map[ID]*Response
+	ready chan none // signaled when a response arrives
+	done  chan none // closed when the reader exits
+}
+
+type none = struct{}
+
+func newReplayResponses() *replayResponses {
+	return &replayResponses{
+		resps: make(map[ID]*Response),
+		ready: make(chan none, 1),
+		done:  make(chan none),
+	}
+}
+
+func (p *replayResponses) read(ctx context.Context, r Reader) {
+	defer close(p.done)
+	for {
+		msg, _, err := r.Read(ctx)
+		if err != nil {
+			return
+		}
+		var resps []*Response
+		switch msg := msg.(type) {
+		case *Response:
+			resps = append(resps, msg)
+		case Batch:
+			for _, m := range msg {
+				if resp, ok := m.(*Response); ok {
+					resps = append(resps, resp)
+				}
+			}
+		}
+		if len(resps) == 0 {
+			continue
+		}
+		p.mutex.Lock()
+		for _, resp := range resps {
+			p.resps[resp.ID] = resp
+		}
+		p.mutex.Unlock()
+		select {
+		case p.ready <- none{}:
+		default:
+		}
+	}
+}
+
+// wait waits for the response to the call id, nil if it times out or the
+// connection breaks.
+func (p *replayResponses) wait(id ID, timeout time.Duration) *Response {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		p.mutex.Lock()
+		resp, ok := p.resps[id]
+		if ok {
+			delete(p.resps, id)
+		}
+		p.mutex.Unlock()
+		if ok {
+			return resp
+		}
+		select {
+		case <-p.ready:
+		case <-p.done:
+			p.mutex.Lock()
+			resp = p.resps[id]
+			p.mutex.Unlock()
+			return resp
+		case <-timer.C:
+			return nil
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
@@ -53,6 +53,9 @@ var (
 	ErrServerClosing = NewError(-32002, "JSON RPC server is closing")
 	// ErrClientClosing is a dummy error returned for calls initiated while the client is closing.
 	ErrClientClosing = NewError(-32003, "JSON RPC client is closing")
+	// ErrRequestCancelled is returned for calls cancelled by the caller or by
+	// their timeouts. The code is the one defined by LSP.
+	ErrRequestCancelled = NewError(-32800, "JSON RPC request cancelled")
 )
 
 const wireVersion = "2.0"
@@ -0,0 +1,338 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"fmt"
+	goast "go/ast"
+	"go/types"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/goplus/mod/xgomod"
+	"github.com/goplus/xgo/ast"
+	"github.com/goplus/xgo/cl/outline"
+	"github.com/goplus/xgo/parser"
+	"github.com/goplus/xgo/scanner"
+	"github.com/goplus/xgo/token"
+	"github.com/goplus/xgo/tool"
+	"github.com/goplus/xgo/x/typesutil"
+	"github.com/qiniu/x/errors"
+)
+
+// -----------------------------------------------------------------------------
+
+// snapshot is the result of type checking a package directory.
+type snapshot struct {
+	dir     string
+	diskVer uint64 // version of files on disk it is checked against
+	fset    *token.FileSet
+	mod     *xgomod.Module
+	pkg     *types.Package
+	files   map[string]*ast.File   // path => XGo file
+	goFiles map[string]*goast.File // path => Go file
+	info    *typesutil.Info
+	goInfo  *types.Info
+	errs    []error
+
+	src     *ast.Package
+	outline *outline.All
+}
+
+// workspace type checks packages by reading open documents from the overlay.
+type workspace struct {
+	mutex    sync.Mutex
+	fs       *overlay
+	confs    map[string]*tool.Config // module root => config
+	outlines map[string]*outline.All // dir => last valid outline
+
+	diskVer  func() uint64 // see handler.diskVersion
+	confsVer uint64        // diskVer when confs were created
+}
+
+func newWorkspace(fs *overlay, diskVer func() uint64) *workspace {
+	return &workspace{
+		fs:       fs,
+		confs:    make(map[string]*tool.Config),
+		outlines: make(map[string]*outline.All),
+		diskVer:  diskVer,
+	}
+}
+
+// confOf returns the config of the module containing directory dir. Configs
+// cache imported packages, so they are dropped once files on disk change,
+// eg. xgo_autogen.go of a dependency is regenerated.
+func (p *workspace) confOf(dir string) (conf *tool.Config, err error) {
+	if ver := p.diskVer(); ver != p.confsVer {
+		p.confs = make(map[string]*tool.Config)
+		p.confsVer = ver
+	}
+	mod, err := tool.LoadMod(dir)
+	if err != nil {
+		return
+	}
+	root := mod.Root()
+	if conf = p.confs[root]; conf == nil {
+		if conf, err = tool.NewDefaultConf(dir, 0); err != nil {
+			return
+		}
+		p.confs[root] = conf
+	}
+	return
+}
+
+// check type checks the package in directory dir.
+func (p *workspace) check(dir string) (snap *snapshot) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	snap = &snapshot{dir: dir}
+	conf, err := p.confOf(dir)
+	snap.diskVer = p.confsVer
+	if err != nil {
+		snap.errs = append(snap.errs, err)
+		return
+	}
+	mod, fset := conf.Mod, conf.Fset
+	snap.mod, snap.fset = mod, fset
+	pkgs, err := parser.ParseFSDir(fset, p.fs, dir, parser.Config{
+		ClassKind: mod.ClassKind,
+		Mode:      parser.ParseComments | parser.AllErrors,
+	})
+	if err != nil {
+		snap.errs = append(snap.errs, err)
+	}
+	pkg := mainPkgOf(pkgs)
+	if pkg == nil {
+		return
+	}
+	snap.src, snap.files, snap.goFiles = pkg, pkg.Files, pkg.GoFiles
+	snap.pkg = types.NewPackage(pkgPathOf(mod, dir, pkg.Name), pkg.Name)
+	snap.info = &typesutil.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+		Overloads:  make(map[*ast.Ident]types.Object),
+	}
+	snap.goInfo = &types.Info{
+		Types:      make(map[goast.Expr]types.TypeAndValue),
+		Defs:       make(map[*goast.Ident]types.Object),
+		Uses:       make(map[*goast.Ident]types.Object),
+		Implicits:  make(map[goast.Node]types.Object),
+		Selections: make(map[*goast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[goast.Node]*types.Scope),
+	}
+	typesConf := &types.Config{
+		Importer: conf.Importer,
+		Error: func(err error) {
+			snap.errs = append(snap.errs, err)
+		},
+	}
+	opts := &typesutil.Config{
+		Types:      snap.pkg,
+		Fset:       fset,
+		WorkingDir: dir,
+		Mod:        mod,
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			snap.errs = append(snap.errs, fmt.Errorf("typesutil.Checker: %v", e))
+		}
+	}()
+	checker := typesutil.NewChecker(typesConf, opts, snap.goInfo, snap.info)
+	checker.Files(sortedFiles(snap.goFiles), sortedFiles(snap.files))
+	return
+}
+
+// mainPkgOf returns the package to check among packages of a directory: the
+// first non-test package by name, or the first package if all are tests.
+func mainPkgOf(pkgs map[string]*ast.Package) *ast.Package {
+	names := make([]string, 0, len(pkgs))
+	for name := range pkgs {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !strings.HasSuffix(name, "_test") {
+			return pkgs[name]
+		}
+	}
+	return pkgs[names[0]]
+}
+
+// outlineOf returns the outline of a snapshot, including unexported objects.
+// Function bodies are not compiled, so it's usually available even if the
+// package has errors. Otherwise the last valid outline of the directory is
+// returned.
+func (p *workspace) outlineOf(snap *snapshot) *outline.All {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if snap.outline != nil || snap.src == nil {
+		return snap.outline
+	}
+	if conf, err := p.confOf(snap.dir); err == nil {
+		func() {
+			defer func() {
+				recover()
+			}()
+			out, err := outline.NewPackage(snap.pkg.Path(), snap.src, &outline.Config{
+				Fset:        snap.fset,
+				LookupClass: snap.mod.LookupClass,
+				Importer:    conf.Importer,
+			})
+			if err == nil {
+				p.outlines[snap.dir] = out.Outline(true)
+			}
+		}()
+	}
+	snap.outline = p.outlines[snap.dir]
+	return snap.outline
+}
+
+func pkgPathOf(mod *xgomod.Module, dir, name string) string {
+	if mod.HasModfile() {
+		if rel, err := filepath.Rel(mod.Root(), dir); err == nil {
+			return path.Join(mod.Path(), filepath.ToSlash(rel))
+		}
+	}
+	return name
+}
+
+func sortedFiles[T any](files map[string]T) []T {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ret := make([]T, len(names))
+	for i, name := range names {
+		ret[i] = files[name]
+	}
+	return ret
+}
+
+// -----------------------------------------------------------------------------
+
+// diagnostics converts errors of a snapshot to diagnostics grouped by file path.
+// Errors without position information are reported at the beginning of
+// fallback.
+func (p *workspace) diagnostics(snap *snapshot, fallback string) map[string][]Diagnostic {
+	ret := make(map[string][]Diagnostic)
+	texts := make(map[string]*document) // path => content, read once
+	add := func(start, end token.Position, msg string) {
+		if start.Filename == "" {
+			start, end = token.Position{Filename: fallback}, token.Position{}
+		}
+		if end.Filename != start.Filename || end.Line == 0 {
+			end = start
+		}
+		text := texts[start.Filename]
+		if text == nil {
+			data, _ := p.fs.content(start.Filename)
+			text = &document{text: data}
+			texts[start.Filename] = text
+		}
+		ret[start.Filename] = append(ret[start.Filename], Diagnostic{
+			Range:    rangeIn(text.text, text.lineStarts(), start, end),
+			Severity: SeverityError,
+			Source:   "xgo",
+			Message:  msg,
+		})
+	}
+	var onErr func(err error)
+	onErr = func(err error) {
+		switch e := err.(type) {
+		case types.Error:
+			start, end := typesutil.ErrorRange(e)
+			add(e.Fset.Position(start), e.Fset.Position(end), e.Msg)
+		case scanner.ErrorList:
+			for _, v := range e {
+				add(v.Pos, v.Pos, v.Msg)
+			}
+		case *scanner.Error:
+			add(e.Pos, e.Pos, e.Msg)
+		case errors.List:
+			for _, v := range e {
+				onErr(v)
+			}
+		default:
+			var pos token.Position
+			if snap.fset != nil {
+				pos = snap.fset.Position(tool.ErrorPos(err))
+			}
+			add(pos, pos, err.Error())
+		}
+	}
+	for _, err := range snap.errs {
+		onErr(err)
+	}
+	return ret
+}
+
+// rangeOf converts a token.Position range to a LSP range.
+func (p *workspace) rangeOf(start, end token.Position) Range {
+	text, _ := p.fs.content(start.Filename)
+	return rangeIn(text, lineStartsOf(text), start, end)
+}
+
+// rangeIn converts a token.Position range in text to a LSP range.
+func rangeIn(text []byte, lines []int, start, end token.Position) Range {
+	from, to := byteOffset(lines, start), byteOffset(lines, end)
+	if to <= from { // extend an empty range to the word at start
+		to = wordEnd(text, from)
+	}
+	return Range{
+		Start: positionOf(text, lines, from),
+		End:   positionOf(text, lines, to),
+	}
+}
+
+func wordEnd(text []byte, off int) int {
+	for off < len(text) {
+		r, size := utf8.DecodeRune(text[off:])
+		if !(r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			break
+		}
+		off += size
+	}
+	return off
+}
+
+func byteOffset(lines []int, pos token.Position) int {
+	if pos.Line <= 0 || pos.Line > len(lines) {
+		return 0
+	}
+	off := lines[pos.Line-1]
+	if pos.Column > 0 {
+		off += pos.Column - 1
+	}
+	return off
+}
+
+// -----------------------------------------------------------------------------
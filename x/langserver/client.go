@@ -27,8 +27,26 @@ import (
 const (
 	methodGenGo   = "gengo"
 	methodChanged = "changed"
+
+	methodGenGoResult = "gengoResult"
 )
 
+// GenGoResult is the params of gengoResult notifications, which are sent by
+// the LangServer each time it finishes generating xgo_autogen.go of a
+// directory in background.
+type GenGoResult struct {
+	Dir   string `json:"dir"`
+	Error string `json:"error,omitempty"` // empty if succeeded
+
+	// Canceled = true means the generation was skipped for a newer change,
+	// and nothing was written.
+	Canceled bool `json:"canceled,omitempty"`
+
+	// Superseded = true means a newer change came while generating. The
+	// files are written, and Error is the result of the generation.
+	Superseded bool `json:"superseded,omitempty"`
+}
+
 // -----------------------------------------------------------------------------
 
 // Dialer is used by clients to dial a server.
@@ -0,0 +1,448 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"bytes"
+	"go/build"
+	"go/types"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/goplus/xgo/ast"
+	"github.com/goplus/xgo/format"
+	"github.com/goplus/xgo/printer"
+	"github.com/goplus/xgo/token"
+	xformat "github.com/goplus/xgo/x/format"
+)
+
+// -----------------------------------------------------------------------------
+
+// actionBuilder collects code actions of a document.
+type actionBuilder struct {
+	snap  *snapshot
+	f     *ast.File
+	uri   DocumentURI
+	text  []byte
+	lines []int
+	base  int
+	only  []CodeActionKind
+
+	actions []CodeAction
+}
+
+func (p *actionBuilder) wants(kind CodeActionKind) bool {
+	if len(p.only) == 0 {
+		return true
+	}
+	for _, k := range p.only {
+		if k == kind || strings.HasPrefix(string(kind), string(k)+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *actionBuilder) add(title string, kind CodeActionKind, diag *Diagnostic, edits ...TextEdit) {
+	action := CodeAction{
+		Title: title,
+		Kind:  kind,
+		Edit:  &WorkspaceEdit{Changes: map[DocumentURI][]TextEdit{p.uri: edits}},
+	}
+	if diag != nil {
+		action.Diagnostics = []Diagnostic{*diag}
+	}
+	p.actions = append(p.actions, action)
+}
+
+func (p *actionBuilder) offsetOf(pos token.Pos) int {
+	return int(pos) - p.base
+}
+
+func (p *actionBuilder) positionOf(pos token.Pos) Position {
+	return positionOf(p.text, p.lines, p.offsetOf(pos))
+}
+
+// lineOf returns the offsets of the line containing offset off, excluding the
+// line terminator.
+func (p *actionBuilder) lineOf(off int) (start, end int) {
+	start = bytes.LastIndexByte(p.text[:off], '\n') + 1
+	end = bytes.IndexByte(p.text[off:], '\n')
+	if end < 0 {
+		return start, len(p.text)
+	}
+	return start, off + end
+}
+
+// replace returns an edit that replaces node n by text.
+func (p *actionBuilder) replace(n ast.Node, text string) TextEdit {
+	return TextEdit{
+		Range:   Range{Start: p.positionOf(n.Pos()), End: p.positionOf(n.End())},
+		NewText: text,
+	}
+}
+
+// deleteLines returns an edit that deletes whole lines of node n.
+func (p *actionBuilder) deleteLines(n ast.Node) TextEdit {
+	start, _ := p.lineOf(p.offsetOf(n.Pos()))
+	_, end := p.lineOf(p.offsetOf(n.End()))
+	if end < len(p.text) {
+		end++
+	}
+	return TextEdit{
+		Range: Range{Start: positionOf(p.text, p.lines, start), End: positionOf(p.text, p.lines, end)},
+	}
+}
+
+// print prints node n, which replaces the source at pos. Lines after the
+// first one are indented as the line of pos.
+func (p *actionBuilder) print(n ast.Node, pos token.Pos) (string, bool) {
+	var buf bytes.Buffer
+	node := any(n)
+	if n.Pos().IsValid() {
+		node = &printer.CommentedNode{Node: n, Comments: p.f.Comments}
+	}
+	if err := format.Node(&buf, p.snap.fset, node); err != nil {
+		return "", false
+	}
+	start, _ := p.lineOf(p.offsetOf(pos))
+	i := start
+	for i < len(p.text) && (p.text[i] == ' ' || p.text[i] == '\t') {
+		i++
+	}
+	ret := bytes.ReplaceAll(buf.Bytes(), []byte{'\n'}, append([]byte{'\n'}, p.text[start:i]...))
+	return string(ret), true
+}
+
+// -----------------------------------------------------------------------------
+
+// quickFixes adds fixes of diagnostic diag reported by the compiler.
+func (p *actionBuilder) quickFixes(diag *Diagnostic) {
+	if diag.Source != "xgo" || !p.wants(QuickFix) {
+		return
+	}
+	if name := strings.TrimPrefix(diag.Message, "undefined: "); name != diag.Message && p.isPkgRef(diag.Range.Start, name) {
+		paths := stdPkgsNamed(name)
+		for _, path := range paths {
+			if edit, ok := p.addImport(path); ok {
+				p.add("Add import "+strconv.Quote(path), QuickFix, diag, edit)
+				p.actions[len(p.actions)-1].IsPreferred = len(paths) == 1
+			}
+		}
+	}
+
+	// add a `// compile error:` notation, which makes tools ignore the error
+	off := offsetOf(p.text, p.lines, diag.Range.Start)
+	_, end := p.lineOf(off)
+	if bytes.Contains(p.text[off:end], []byte("//")) {
+		return
+	}
+	msg := diag.Message
+	if pos := strings.IndexByte(msg, '\n'); pos >= 0 {
+		msg = msg[:pos]
+	}
+	at := positionOf(p.text, p.lines, end)
+	p.add("Add `// compile error:` notation", QuickFix, diag, TextEdit{
+		Range:   Range{Start: at, End: at},
+		NewText: " // compile error: " + msg,
+	})
+}
+
+// isPkgRef reports whether identifier name at position pos is referred as
+// a package, eg. strings in strings.ToUpper.
+func (p *actionBuilder) isPkgRef(pos Position, name string) (ret bool) {
+	at := token.Pos(p.base + offsetOf(p.text, p.lines, pos))
+	ast.Inspect(p.f, func(n ast.Node) bool {
+		if ret || n == nil || n.Pos() > at || n.End() <= at {
+			return false
+		}
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Pos() == at && x.Name == name {
+				ret = true
+			}
+		}
+		return true
+	})
+	return
+}
+
+// addImport returns an edit that imports package path.
+func (p *actionBuilder) addImport(path string) (edit TextEdit, ok bool) {
+	var last *ast.GenDecl
+	for _, decl := range p.f.Decls {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			last = d
+		}
+	}
+	spec := strconv.Quote(path)
+	switch {
+	case last == nil:
+		at := Position{}
+		if p.f.Package.IsValid() && !p.f.NoPkgDecl {
+			at = Position{Line: p.positionOf(p.f.Name.End()).Line + 1}
+			spec = "\n" + "import " + spec + "\n"
+		} else {
+			spec = "import " + spec + "\n\n"
+		}
+		edit = TextEdit{Range: Range{Start: at, End: at}, NewText: spec}
+	case last.Lparen.IsValid():
+		off := p.offsetOf(last.Rparen)
+		start, _ := p.lineOf(off)
+		if len(bytes.TrimSpace(p.text[start:off])) == 0 {
+			at := positionOf(p.text, p.lines, start)
+			edit = TextEdit{Range: Range{Start: at, End: at}, NewText: "\t" + spec + "\n"}
+		} else {
+			at := p.positionOf(last.Rparen)
+			edit = TextEdit{Range: Range{Start: at, End: at}, NewText: "; " + spec}
+		}
+	default: // import "fmt" => import (...)
+		if len(last.Specs) != 1 {
+			return
+		}
+		old := p.text[p.offsetOf(last.Specs[0].Pos()):p.offsetOf(last.Specs[0].End())]
+		edit = p.replace(last, "import (\n\t"+string(old)+"\n\t"+spec+"\n)")
+	}
+	return edit, true
+}
+
+var stdPkgs struct {
+	once  sync.Once
+	names map[string][]string // name => package paths
+}
+
+// stdPkgsNamed returns paths of the standard packages with the name.
+func stdPkgsNamed(name string) []string {
+	stdPkgs.once.Do(func() {
+		stdPkgs.names = make(map[string][]string)
+		root := filepath.Join(build.Default.GOROOT, "src")
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() || path == root {
+				return nil
+			}
+			switch elem := d.Name(); elem {
+			case "internal", "vendor", "testdata", "cmd":
+				return filepath.SkipDir
+			default:
+				if strings.HasPrefix(elem, ".") || strings.HasPrefix(elem, "_") {
+					return filepath.SkipDir
+				}
+				if rel, e := filepath.Rel(root, path); e == nil {
+					stdPkgs.names[elem] = append(stdPkgs.names[elem], filepath.ToSlash(rel))
+				}
+			}
+			return nil
+		})
+		for _, paths := range stdPkgs.names {
+			sort.Slice(paths, func(i, j int) bool {
+				return len(paths[i]) < len(paths[j]) || (len(paths[i]) == len(paths[j]) && paths[i] < paths[j])
+			})
+		}
+	})
+	return stdPkgs.names[name]
+}
+
+// -----------------------------------------------------------------------------
+
+// rewrites adds rewrites of Go style code in range [start, end) to XGo style.
+func (p *actionBuilder) rewrites(start, end token.Pos) {
+	if !p.wants(RefactorRewrite) {
+		return
+	}
+	overlaps := func(n ast.Node) bool {
+		return n.Pos() <= end && start <= n.End()
+	}
+	stmts := func(list []ast.Stmt) {
+		for i := 0; i+1 < len(list); i++ {
+			if assign, ok := list[i].(*ast.AssignStmt); ok {
+				if ifStmt, ok := list[i+1].(*ast.IfStmt); ok && (overlaps(assign) || overlaps(ifStmt)) {
+					p.errWrap(assign, ifStmt)
+				}
+			}
+		}
+	}
+	var exprStmt *ast.ExprStmt
+	ast.Inspect(p.f, func(n ast.Node) bool {
+		if n == nil || !overlaps(n) {
+			return false
+		}
+		switch v := n.(type) {
+		case *ast.ExprStmt:
+			exprStmt = v
+		case *ast.BlockStmt:
+			stmts(v.List)
+		case *ast.CaseClause:
+			stmts(v.Body)
+		case *ast.CommClause:
+			stmts(v.Body)
+		case *ast.CallExpr:
+			p.fmtToBuiltin(v, exprStmt != nil && exprStmt.X == v)
+			for _, arg := range v.Args {
+				if lit, ok := arg.(*ast.FuncLit); ok && overlaps(lit) {
+					p.funcLitToLambda(lit)
+				}
+			}
+		}
+		return true
+	})
+}
+
+// errWrap converts `x, err := f(); if err != nil { return err }` to x := f()?.
+func (p *actionBuilder) errWrap(assign *ast.AssignStmt, ifStmt *ast.IfStmt) {
+	if assign.Tok != token.DEFINE {
+		return
+	}
+	stmt := xformat.ErrReturnToErrWrap(assign, ifStmt)
+	if stmt == nil {
+		return
+	}
+	errObj := p.snap.info.Defs[assign.Lhs[len(assign.Lhs)-1].(*ast.Ident)]
+	if errObj == nil { // err isn't a new variable
+		return
+	}
+	for id, obj := range p.snap.info.Uses {
+		if obj == errObj && (id.Pos() < ifStmt.Pos() || id.Pos() >= ifStmt.End()) {
+			return
+		}
+	}
+	text, ok := p.print(stmt, assign.Pos())
+	if !ok {
+		return
+	}
+	edit := TextEdit{
+		Range:   Range{Start: p.positionOf(assign.Pos()), End: p.positionOf(ifStmt.End())},
+		NewText: text,
+	}
+	p.add("Convert to error wrap expression `?`", RefactorRewrite, nil, edit)
+}
+
+// fmtToBuiltin converts fmt.Println(x) to echo x, and so on. The fmt import
+// is removed if it isn't used elsewhere.
+func (p *actionBuilder) fmtToBuiltin(call *ast.CallExpr, isStmt bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return
+	}
+	x, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return
+	}
+	pkgName, ok := p.snap.info.Uses[x].(*types.PkgName)
+	if !ok || pkgName.Imported().Path() != "fmt" {
+		return
+	}
+	v := *call
+	if !xformat.FmtToBuiltin(&v) {
+		return
+	}
+	if isStmt {
+		xformat.CommandStyle(&v)
+	}
+	text, ok := p.print(&v, call.Pos())
+	if !ok {
+		return
+	}
+	edits := []TextEdit{p.replace(call, text)}
+	uses := 0
+	for _, obj := range p.snap.info.Uses {
+		if obj == pkgName {
+			uses++
+		}
+	}
+	if uses == 1 {
+		if n := p.importOf(pkgName); n != nil {
+			edits = append([]TextEdit{p.deleteLines(n)}, edits...)
+		}
+	}
+	p.add("Convert "+x.Name+"."+sel.Sel.Name+" to "+v.Fun.(*ast.Ident).Name, RefactorRewrite, nil, edits...)
+}
+
+// importOf returns the import spec of pkgName, or the import declaration if
+// it is the only spec.
+func (p *actionBuilder) importOf(pkgName *types.PkgName) ast.Node {
+	for _, decl := range p.f.Decls {
+		d, ok := decl.(*ast.GenDecl)
+		if !ok || d.Tok != token.IMPORT {
+			continue
+		}
+		for _, item := range d.Specs {
+			spec := item.(*ast.ImportSpec)
+			if path, _ := strconv.Unquote(spec.Path.Value); path != pkgName.Imported().Path() {
+				continue
+			}
+			if spec.Name != nil && spec.Name.Name != pkgName.Name() {
+				continue
+			}
+			if len(d.Specs) == 1 {
+				return d
+			}
+			return spec
+		}
+	}
+	return nil
+}
+
+// funcLitToLambda converts function literal lit, which is an argument, to a
+// lambda expression.
+func (p *actionBuilder) funcLitToLambda(lit *ast.FuncLit) {
+	lambda := xformat.FuncLitToLambda(lit)
+	if lambda == nil {
+		return
+	}
+	text, ok := p.print(lambda, lit.Pos())
+	if !ok {
+		return
+	}
+	p.add("Convert function literal to lambda", RefactorRewrite, nil, p.replace(lit, text))
+}
+
+// -----------------------------------------------------------------------------
+
+// codeAction implements textDocument/codeAction.
+func (p *session) codeAction(params *CodeActionParams) ([]CodeAction, error) {
+	uri := params.TextDocument.URI
+	file := uri.Path()
+	snap := p.snapshotOf(filepath.Dir(file))
+	f, ok := snap.files[file]
+	if !ok || snap.info == nil {
+		return nil, nil
+	}
+	text, err := p.fs.content(file)
+	if err != nil {
+		return nil, nil
+	}
+	tf := snap.fset.File(f.Pos())
+	if tf == nil || tf.Size() != len(text) { // the snapshot is out of date
+		return nil, nil
+	}
+	a := &actionBuilder{
+		snap: snap, f: f, uri: uri, text: text, lines: lineStartsOf(text),
+		base: tf.Base(), only: params.Context.Only,
+	}
+	for i := range params.Context.Diagnostics {
+		a.quickFixes(&params.Context.Diagnostics[i])
+	}
+	start := tf.Pos(offsetOf(text, a.lines, params.Range.Start))
+	end := tf.Pos(offsetOf(text, a.lines, params.Range.End))
+	a.rewrites(start, end)
+	return a.actions, nil
+}
+
+// -----------------------------------------------------------------------------
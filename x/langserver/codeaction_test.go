@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+// codeActions opens document name with text, or changes it to text if it's
+// open, and returns the code actions of the whole document with its
+// diagnostics, as title => text after applying.
+func (c *testClient) codeActions(name, text string, only ...CodeActionKind) map[string]string {
+	c.t.Helper()
+	c.mutex.Lock()
+	_, opened := c.vers[name]
+	c.mutex.Unlock()
+	if opened {
+		c.change(name, text)
+	} else {
+		c.open(name, text)
+	}
+	diags := c.diagnostics(name)
+	doc := &document{text: []byte(text)}
+	params := &CodeActionParams{
+		TextDocument: c.doc(name),
+		Range:        Range{End: doc.positionOf(len(text))},
+		Context:      CodeActionContext{Diagnostics: diags, Only: only},
+	}
+	var actions []CodeAction
+	if err := c.call(methodCodeAction, params, &actions); err != nil {
+		c.t.Fatal("codeAction:", err)
+	}
+	if actions == nil {
+		c.t.Fatal("codeAction: null")
+	}
+	ret := make(map[string]string)
+	for _, action := range actions {
+		if action.Edit == nil || len(action.Edit.Changes) != 1 {
+			c.t.Fatalf("codeAction %q: edit %v", action.Title, action.Edit)
+		}
+		ret[action.Title] = applyEdits(text, action.Edit.Changes[c.uri(name)])
+	}
+	return ret
+}
+
+func TestCodeActionQuickFix(t *testing.T) {
+	c := newTestClient(t, nil)
+	tests := []struct {
+		text string
+		want map[string]string
+	}{
+		{"echo strings.ToUpper(\"hi\")\n", map[string]string{
+			"Add import \"strings\"":           "import \"strings\"\n\necho strings.ToUpper(\"hi\")\n",
+			"Add `// compile error:` notation": "echo strings.ToUpper(\"hi\") // compile error: undefined: strings\n",
+		}},
+		{"import \"fmt\"\n\nfmt.Println(strings.ToUpper(\"hi\"))\n", map[string]string{
+			"Add import \"strings\"":           "import (\n\t\"fmt\"\n\t\"strings\"\n)\n\nfmt.Println(strings.ToUpper(\"hi\"))\n",
+			"Add `// compile error:` notation": "import \"fmt\"\n\nfmt.Println(strings.ToUpper(\"hi\")) // compile error: undefined: strings\n",
+			"Convert fmt.Println to echo":      "\necho strings.ToUpper(\"hi\")\n",
+		}},
+		{"import (\n\t\"fmt\"\n)\n\nfmt.Println(strings.ToUpper(\"hi\")) // ok\n", map[string]string{
+			"Add import \"strings\"":      "import (\n\t\"fmt\"\n\t\"strings\"\n)\n\nfmt.Println(strings.ToUpper(\"hi\")) // ok\n",
+			"Convert fmt.Println to echo": "\necho strings.ToUpper(\"hi\") // ok\n",
+		}},
+		{"x := undefinedVar\n", map[string]string{
+			"Add `// compile error:` notation": "x := undefinedVar // compile error: undefined: undefinedVar\n",
+		}},
+	}
+	for _, tt := range tests {
+		got := c.codeActions("main.xgo", tt.text)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("codeAction %q:\ngot  %q\nwant %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCodeActionRewrite(t *testing.T) {
+	c := newTestClient(t, nil)
+	tests := []struct {
+		text string
+		want map[string]string
+	}{
+		{"import \"fmt\"\n\nfmt.Println(\"hi\")\nx := fmt.Sprint(1)\necho x\n", map[string]string{
+			"Convert fmt.Println to echo":  "import \"fmt\"\n\necho \"hi\"\nx := fmt.Sprint(1)\necho x\n",
+			"Convert fmt.Sprint to sprint": "import \"fmt\"\n\nfmt.Println(\"hi\")\nx := sprint(1)\necho x\n",
+		}},
+		{"import \"strconv\"\n\nfunc f(s string) (int, error) {\n\tn, err := strconv.Atoi(s)\n\tif err != nil {\n\t\treturn 0, err\n\t}\n\treturn n, nil\n}\n", map[string]string{
+			"Convert to error wrap expression `?`": "import \"strconv\"\n\nfunc f(s string) (int, error) {\n\tn := strconv.Atoi(s)?\n\treturn n, nil\n}\n",
+		}},
+		{"import \"sort\"\n\na := []int{2, 1}\nsort.Slice(a, func(i, j int) bool {\n\treturn a[i] < a[j]\n})\n", map[string]string{
+			"Convert function literal to lambda": "import \"sort\"\n\na := []int{2, 1}\nsort.Slice(a, (i, j) => a[i] < a[j])\n",
+		}},
+	}
+	for _, tt := range tests {
+		got := c.codeActions("main.xgo", tt.text)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("codeAction %q:\ngot  %q\nwant %q", tt.text, got, tt.want)
+		}
+	}
+
+	// only quick fixes are wanted
+	text := "import \"fmt\"\n\nfmt.Println(undefinedVar)\n"
+	got := c.codeActions("main.xgo", text, QuickFix)
+	want := map[string]string{
+		"Add `// compile error:` notation": "import \"fmt\"\n\nfmt.Println(undefinedVar) // compile error: undefined: undefinedVar\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("codeAction only %v:\ngot  %q\nwant %q", QuickFix, got, want)
+	}
+}
+
+func TestCodeActionNotFound(t *testing.T) {
+	c := newTestClient(t, map[string]string{"a.go": "package main\n\nvar x = undefinedVar\n"})
+	for _, name := range []string{"none.xgo", "a.go"} {
+		var actions []CodeAction
+		params := &CodeActionParams{TextDocument: c.doc(name), Range: Range{End: Position{Line: 2}}}
+		if err := c.call(methodCodeAction, params, &actions); err != nil || actions == nil || len(actions) != 0 {
+			t.Errorf("codeAction %s: %v, %v", name, actions, err)
+		}
+	}
+}
@@ -0,0 +1,409 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"go/types"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/goplus/xgo/ast"
+	"github.com/goplus/xgo/cl/outline"
+	"github.com/goplus/xgo/token"
+	"github.com/goplus/xgo/tool"
+)
+
+// -----------------------------------------------------------------------------
+
+// xgoBuiltins are the builtins inserted by cl/builtin.go. They are not in
+// any scope recorded by the type checker.
+var xgoBuiltins = []struct {
+	name, detail string
+	kind         CompletionItemKind
+}{
+	{"echo", "fmt.Println", FunctionCompletion},
+	{"print", "fmt.Print", FunctionCompletion},
+	{"println", "fmt.Println", FunctionCompletion},
+	{"printf", "fmt.Printf", FunctionCompletion},
+	{"errorf", "fmt.Errorf", FunctionCompletion},
+	{"fprint", "fmt.Fprint", FunctionCompletion},
+	{"fprintln", "fmt.Fprintln", FunctionCompletion},
+	{"fprintf", "fmt.Fprintf", FunctionCompletion},
+	{"sprint", "fmt.Sprint", FunctionCompletion},
+	{"sprintln", "fmt.Sprintln", FunctionCompletion},
+	{"sprintf", "fmt.Sprintf", FunctionCompletion},
+	{"open", "os.Open", FunctionCompletion},
+	{"create", "os.Create", FunctionCompletion},
+	{"lines", "osx.Lines", FunctionCompletion},
+	{"blines", "osx.BLines", FunctionCompletion},
+	{"errorln", "osx.Errorln", FunctionCompletion},
+	{"fatal", "osx.Fatal", FunctionCompletion},
+	{"type", "reflect.TypeOf", FunctionCompletion},
+	{"newRange", "xgo.NewRange", FunctionCompletion},
+	{"bigint", "ng.Bigint", ClassCompletion},
+	{"bigrat", "ng.Bigrat", ClassCompletion},
+	{"bigfloat", "ng.Bigfloat", ClassCompletion},
+	{"int128", "ng.Int128", ClassCompletion},
+	{"uint128", "ng.Uint128", ClassCompletion},
+}
+
+// domainTags are the known tags of domain text literals, eg. json`...`.
+// Tags other than tpl refer to packages in tpl/encoding.
+var domainTags = []string{"tpl", "csv", "json", "regexp", "regexposix", "xml"}
+
+// completer collects completion items matching a prefix.
+type completer struct {
+	snap   *snapshot
+	qf     types.Qualifier
+	prefix string
+	items  []CompletionItem
+	seen   map[string]bool
+}
+
+func (p *completer) add(item CompletionItem) {
+	if p.seen[item.Label] || !hasPrefixFold(item.Label, p.prefix) {
+		return
+	}
+	p.seen[item.Label] = true
+	p.items = append(p.items, item)
+}
+
+// addObject adds a completion item for obj. If alias is true, exported
+// functions and methods are completed with their lowercase XGo names,
+// eg. os.open for os.Open.
+func (p *completer) addObject(obj types.Object, alias bool) {
+	name := obj.Name()
+	if name == "_" || isHidden(name) {
+		return
+	}
+	if _, _, ok := outline.CheckOverload(obj); ok { // overload members are completed by their declaration
+		return
+	}
+	item := CompletionItem{Label: name, Kind: kindOf(obj), Detail: objectString(obj, p.qf)}
+	if _, ok := obj.(*types.Func); ok && alias && obj.Exported() {
+		item.Label = lowerFirst(name)
+		item.Documentation = &MarkupContent{Kind: Markdown, Value: "XGo alias of `" + name + "`"}
+	}
+	p.add(item)
+}
+
+// addMembers adds fields and methods of type typ.
+func (p *completer) addMembers(typ types.Type, alias bool) {
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	mset := types.NewMethodSet(typ)
+	if !types.IsInterface(typ) {
+		mset = types.NewMethodSet(types.NewPointer(typ))
+	}
+	for i, n := 0, mset.Len(); i < n; i++ {
+		if obj := mset.At(i).Obj(); p.accessible(obj) {
+			p.addObject(obj, alias)
+		}
+	}
+	visited := make(map[types.Type]bool)
+	var addFields func(typ types.Type)
+	addFields = func(typ types.Type) {
+		if ptr, ok := typ.(*types.Pointer); ok {
+			typ = ptr.Elem()
+		}
+		if visited[typ] {
+			return
+		}
+		visited[typ] = true
+		st, ok := typ.Underlying().(*types.Struct)
+		if !ok {
+			return
+		}
+		var embedded []types.Type
+		for i, n := 0, st.NumFields(); i < n; i++ {
+			fld := st.Field(i)
+			if p.accessible(fld) {
+				p.addObject(fld, false)
+			}
+			if fld.Embedded() {
+				embedded = append(embedded, fld.Type())
+			}
+		}
+		for _, t := range embedded { // shallower fields shadow deeper ones
+			addFields(t)
+		}
+	}
+	addFields(typ)
+}
+
+func (p *completer) accessible(obj types.Object) bool {
+	return obj.Exported() || obj.Pkg() == p.snap.pkg
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// isHidden reports whether name is generated by gogen, eg. Gop_Add, Gopo_T_Add.
+func isHidden(name string) bool {
+	for _, prefix := range []string{"Gop", "XGo"} {
+		if strings.HasPrefix(name, prefix) {
+			rest := name[len(prefix):]
+			if strings.HasPrefix(rest, "_") || len(rest) > 1 && rest[1] == '_' && 'a' <= rest[0] && rest[0] <= 'z' {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func lowerFirst(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[size:]
+}
+
+func kindOf(obj types.Object) CompletionItemKind {
+	switch o := obj.(type) {
+	case *types.Func:
+		if o.Type().(*types.Signature).Recv() != nil {
+			return MethodCompletion
+		}
+		return FunctionCompletion
+	case *types.Var:
+		if o.IsField() {
+			return FieldCompletion
+		}
+		return VariableCompletion
+	case *types.Const:
+		return ConstantCompletion
+	case *types.TypeName:
+		if _, ok := o.Type().(*types.TypeParam); ok {
+			return TypeParamCompletion
+		}
+		switch o.Type().Underlying().(type) {
+		case *types.Interface:
+			return InterfaceCompletion
+		case *types.Struct:
+			return StructCompletion
+		}
+		return ClassCompletion
+	case *types.PkgName:
+		return ModuleCompletion
+	}
+	return TextCompletion
+}
+
+// -----------------------------------------------------------------------------
+
+// completion implements textDocument/completion.
+func (p *session) completion(params *CompletionParams) (*CompletionList, error) {
+	file := params.TextDocument.URI.Path()
+	snap := p.snapshotOf(filepath.Dir(file))
+	f, ok := snap.files[file]
+	if !ok || snap.info == nil {
+		return nil, nil
+	}
+	text, err := p.fs.content(file)
+	if err != nil {
+		return nil, nil
+	}
+	off := offsetOf(text, lineStartsOf(text), params.Position)
+	start := identStart(text, off)
+	at := p.tokenPos(snap, file, params.Position)
+	if !at.IsValid() {
+		return nil, nil
+	}
+	c := &completer{
+		snap:   snap,
+		qf:     types.RelativeTo(snap.pkg),
+		prefix: string(text[start:off]),
+		seen:   make(map[string]bool),
+	}
+	startPos := at - token.Pos(off-start)
+	switch {
+	case start > 0 && text[start-1] == '.':
+		c.selector(f, startPos-1, text[:start-1])
+	case start == off && off > 0 && text[off-1] == ')':
+		c.errWrap(f, at)
+	default:
+		c.scope(f, file, at)
+	}
+	return &CompletionList{Items: c.items}, nil
+}
+
+// identStart returns the start offset of the identifier that ends at off.
+func identStart(text []byte, off int) int {
+	for off > 0 {
+		r, size := utf8.DecodeLastRune(text[:off])
+		if !(r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			break
+		}
+		off -= size
+	}
+	return off
+}
+
+// selector completes members of the operand x in `x.`, the dot is at dot.
+func (p *completer) selector(f *ast.File, dot token.Pos, before []byte) {
+	info := p.snap.info
+	var obj types.Object
+	var typ types.Type
+	ast.Inspect(f, func(n ast.Node) bool {
+		if obj != nil || typ != nil || n == nil || n.Pos() > dot || n.End() < dot {
+			return false
+		}
+		if x, ok := n.(ast.Expr); ok && x.End() == dot {
+			if id, ok := x.(*ast.Ident); ok {
+				obj = info.ObjectOf(id)
+			}
+			if tv, ok := info.Types[x]; ok && obj == nil {
+				typ = tv.Type
+			}
+		}
+		return true
+	})
+	if obj == nil && typ == nil { // the operand may be lost by syntax errors
+		name := string(before[identStart(before, len(before)):])
+		if name == "" {
+			return
+		}
+		for _, s := range scopesAt(p.snap, f, dot) {
+			if obj = s.Lookup(name); obj != nil {
+				break
+			}
+		}
+		if obj == nil {
+			return
+		}
+	}
+	switch o := obj.(type) {
+	case *types.PkgName:
+		scope := o.Imported().Scope()
+		for _, name := range scope.Names() {
+			if obj := scope.Lookup(name); obj.Exported() {
+				p.addObject(obj, true)
+			}
+		}
+		return
+	case *types.TypeName: // method expressions, eg. T.method
+		typ = o.Type()
+	case nil:
+	default:
+		typ = o.Type()
+	}
+	if typ != nil {
+		p.addMembers(typ, true)
+	}
+}
+
+// errWrap completes ErrWrapExpr suffixes after a call that returns an error.
+func (p *completer) errWrap(f *ast.File, at token.Pos) {
+	var call *ast.CallExpr
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil || n.Pos() > at || n.End() < at {
+			return false
+		}
+		if x, ok := n.(*ast.CallExpr); ok && x.End() == at {
+			call = x
+		}
+		return true
+	})
+	if call == nil {
+		return
+	}
+	tv, ok := p.snap.info.Types[call]
+	if !ok || !returnsError(tv.Type) {
+		return
+	}
+	p.add(CompletionItem{Label: "!", Kind: OperatorCompletion, Detail: "panic if an error occurs"})
+	p.add(CompletionItem{Label: "?", Kind: OperatorCompletion, Detail: "return the error to the caller"})
+	p.add(CompletionItem{Label: "?:", Kind: OperatorCompletion, Detail: "use a default value if an error occurs", InsertText: "?: "})
+}
+
+func returnsError(typ types.Type) bool {
+	if t, ok := typ.(*types.Tuple); ok {
+		if t.Len() == 0 {
+			return false
+		}
+		typ = t.At(t.Len() - 1).Type()
+	}
+	return types.Identical(typ, types.Universe.Lookup("error").Type())
+}
+
+// scope completes names visible at position at: local variables and lambda
+// parameters, package members, classfile members, builtins and domain text
+// literal tags.
+func (p *completer) scope(f *ast.File, file string, at token.Pos) {
+	for _, s := range scopesAt(p.snap, f, at) {
+		local := s != p.snap.pkg.Scope() && s != types.Universe
+		for _, name := range s.Names() {
+			obj := s.Lookup(name)
+			if local && obj.Pos().IsValid() && obj.Pos() > at { // not declared yet
+				continue
+			}
+			p.addObject(obj, false)
+		}
+	}
+	if f.IsClass {
+		classType, _ := tool.GetFileClassType(p.snap.mod, f, file)
+		if obj, ok := p.snap.pkg.Scope().Lookup(classType).(*types.TypeName); ok {
+			p.addMembers(obj.Type(), true)
+		}
+	}
+	for _, v := range xgoBuiltins {
+		p.add(CompletionItem{Label: v.name, Kind: v.kind, Detail: v.detail})
+	}
+	for _, tag := range domainTags {
+		p.add(CompletionItem{Label: tag, Kind: KeywordCompletion, Detail: tag + "`...`", InsertText: tag + "`"})
+	}
+}
+
+// scopesAt returns scopes of file f containing position at, from the
+// innermost to the universe scope.
+//
+// Scopes of functions are recorded on their types, which don't cover the
+// function bodies. And their parents are the package scope, so the file
+// scope is inserted before it.
+func scopesAt(snap *snapshot, f *ast.File, at token.Pos) (ret []*types.Scope) {
+	inner := snap.pkg.Scope()
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil || at < n.Pos() || at > n.End() {
+			return false
+		}
+		key := n
+		switch v := n.(type) {
+		case *ast.FuncDecl:
+			key = v.Type
+		case *ast.FuncLit:
+			key = v.Type
+		}
+		if s, ok := snap.info.Scopes[key]; ok {
+			inner = s
+		}
+		return true
+	})
+	fileScope, hasFileScope := snap.info.Scopes[f]
+	for s := inner; s != nil; s = s.Parent() {
+		if s == fileScope {
+			hasFileScope = false
+		} else if s == snap.pkg.Scope() && hasFileScope {
+			ret = append(ret, fileScope)
+		}
+		ret = append(ret, s)
+	}
+	return
+}
+
+// -----------------------------------------------------------------------------
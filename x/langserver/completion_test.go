@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"strings"
+	"testing"
+)
+
+const completionTypes = `const cool = 2
+
+type T struct {
+	Name string
+	age  int
+}
+
+func (t *T) Hello() string {
+	return "hello " + t.Name
+}
+`
+
+func TestCompletion(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string // | is the cursor
+		want []string
+		not  []string
+	}{
+		{"package", "import \"strings\"\n\necho strings.toU|\n",
+			[]string{"toUpper"}, []string{"ToUpper", "toLower"}},
+		{"package all", "import \"strings\"\n\nstrings.|\n",
+			[]string{"toUpper", "Builder", "Reader"}, []string{"builder"}},
+		{"members", "t := &T{}\necho t.|\n",
+			[]string{"Name", "age", "hello"}, []string{"Hello"}},
+		{"member prefix", "t := &T{}\necho t.n|\n",
+			[]string{"Name"}, []string{"age", "hello"}},
+		{"scope", "count := 1\necho co|\n",
+			[]string{"count", "cool"}, []string{"echo"}},
+		{"local order", "func f() {\n\tx1 := 1\n\techo x|\n\tx2 := 2\n\techo x1, x2\n}\n",
+			[]string{"x1"}, []string{"x2"}},
+		{"builtins", "ec|\n", []string{"echo"}, []string{"errorf"}},
+		{"domain tags", "x := js|\n", []string{"json"}, nil},
+		{"err wrap", "import \"os\"\n\nf := os.open(\"a.txt\")|\necho f\n",
+			[]string{"!", "?", "?:"}, nil},
+		{"no err wrap", "echo len(\"a\")|\n", nil, []string{"!", "?"}},
+	}
+	c := newTestClient(t, map[string]string{"types.xgo": completionTypes})
+	c.open("main.xgo", "")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.change("main.xgo", strings.Replace(tt.src, "|", "", 1))
+			var ret *CompletionList
+			params := &CompletionParams{TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: c.doc("main.xgo"),
+				Position:     positionIn(t, tt.src, "|", 0),
+			}}
+			if err := c.call(methodCompletion, params, &ret); err != nil {
+				t.Fatal("completion:", err)
+			}
+			if ret == nil {
+				t.Fatal("completion: null")
+			}
+			labels := make(map[string]bool)
+			for _, item := range ret.Items {
+				labels[item.Label] = true
+			}
+			for _, label := range tt.want {
+				if !labels[label] {
+					t.Errorf("completion: %q not found in %v", label, labels)
+				}
+			}
+			for _, label := range tt.not {
+				if labels[label] {
+					t.Errorf("completion: unexpected %q", label)
+				}
+			}
+		})
+	}
+}
+
+func TestCompletionNull(t *testing.T) {
+	c := newTestClient(t, map[string]string{"types.xgo": completionTypes, "a.go": "package main\n"})
+	for _, name := range []string{"none.xgo", "a.go"} { // neither opened nor on disk, not a XGo file
+		var ret *CompletionList
+		params := &CompletionParams{TextDocumentPositionParams: TextDocumentPositionParams{TextDocument: c.doc(name)}}
+		if err := c.call(methodCompletion, params, &ret); err != nil || ret != nil {
+			t.Fatalf("completion of %s: %v, %v", name, ret, err)
+		}
+	}
+}
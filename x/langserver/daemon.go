@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/goplus/xgo/x/jsonrpc2"
+)
+
+// -----------------------------------------------------------------------------
+
+// ParseAddr parses a listen address in form of network:address, eg.
+// tcp:127.0.0.1:7077 or unix:/tmp/xgo.sock.
+func ParseAddr(addr string) (network, address string, err error) {
+	pos := strings.IndexByte(addr, ':')
+	if pos > 0 {
+		switch network = addr[:pos]; network {
+		case "tcp", "tcp4", "tcp6", "unix":
+			return network, addr[pos+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("invalid listen address %q: want tcp:host:port or unix:path", addr)
+}
+
+// Listen announces on the local address addr (see ParseAddr). A unix socket
+// file left by a daemon which is no longer running is removed.
+func Listen(ctx context.Context, addr string) (Listener, error) {
+	network, address, err := ParseAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	l, err := jsonrpc2.NetListener(ctx, network, address, jsonrpc2.NetListenOptions{})
+	if err != nil && network == "unix" {
+		if _, e := os.Stat(address); e == nil {
+			if c, e := net.Dial(network, address); e == nil { // the daemon is running
+				c.Close()
+				return nil, err
+			}
+			os.Remove(address)
+			l, err = jsonrpc2.NetListener(ctx, network, address, jsonrpc2.NetListenOptions{})
+		}
+	}
+	return l, err
+}
+
+// -----------------------------------------------------------------------------
+
+// DaemonConfig represents the configuration of DaemonDialer.
+type DaemonConfig struct {
+	// IdleTimeout is how long the daemon started keeps running after its
+	// last connection is closed. Default is 10 minutes.
+	IdleTimeout time.Duration
+
+	// StartTimeout is how long to wait for the daemon started to accept
+	// connections. Default is 10 seconds.
+	StartTimeout time.Duration
+}
+
+type daemonDialer struct {
+	addr string
+
+	conf   DaemonConfig
+	xgoCmd string
+}
+
+// DaemonDialer returns a dialer to the LangServer daemon listening on addr
+// (see ParseAddr). If the daemon isn't running, it starts one by running
+// `xgoCmd serve -listen addr -idle timeout`, so that editors, `gop watch`
+// and scripts can share one long-lived daemon:
+//
+//	c, err := langserver.Open(ctx, langserver.DaemonDialer(addr, nil, "gop"), nil)
+func DaemonDialer(addr string, conf *DaemonConfig, xgoCmd string) Dialer {
+	p := &daemonDialer{addr: addr, xgoCmd: xgoCmd}
+	if conf != nil {
+		p.conf = *conf
+	}
+	if p.conf.IdleTimeout == 0 {
+		p.conf.IdleTimeout = 10 * time.Minute
+	}
+	if p.conf.StartTimeout == 0 {
+		p.conf.StartTimeout = 10 * time.Second
+	}
+	return p
+}
+
+func (p *daemonDialer) Dial(ctx context.Context) (io.ReadWriteCloser, error) {
+	network, address, err := ParseAddr(p.addr)
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	c, err := d.DialContext(ctx, network, address)
+	if err == nil {
+		return c, nil
+	}
+	if err := p.start(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.conf.StartTimeout)
+	defer cancel()
+	for delay := 10 * time.Millisecond; ; delay *= 2 {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("langserver: dial daemon %s: %w", p.addr, err)
+		case <-time.After(delay):
+		}
+		if c, err = d.DialContext(ctx, network, address); err == nil {
+			return c, nil
+		}
+		if delay > time.Second/2 {
+			delay = time.Second / 4
+		}
+	}
+}
+
+// start runs the daemon in background. Its log saves to ~/.xgo/daemon.log.
+// The daemon is detached from this process, eg. it runs in a new session, so
+// that a Ctrl-C in the terminal of an editor doesn't kill the shared daemon.
+func (p *daemonDialer) start() error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	xgoDir := filepath.Join(home, ".xgo")
+	if err = os.MkdirAll(xgoDir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(xgoDir, "daemon.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cmd := exec.Command(p.xgoCmd, "serve", "-listen", p.addr, "-idle", p.conf.IdleTimeout.String())
+	cmd.Stdout = f
+	cmd.Stderr = f
+	cmd.SysProcAttr = detachAttr()
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// -----------------------------------------------------------------------------
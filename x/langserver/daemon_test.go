@@ -0,0 +1,174 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"context"
+	"flag"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/goplus/xgo/x/jsonrpc2"
+)
+
+// envTestDaemon makes the test binary run as the daemon started by
+// DaemonDialer, that is, `xgo serve -listen addr -idle timeout`.
+const envTestDaemon = "XGO_LANGSERVER_TEST_DAEMON"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(envTestDaemon) != "" {
+		runTestDaemon(os.Args[1:])
+		return
+	}
+	if os.Getenv("XGOROOT") == "" {
+		dir, _ := os.Getwd()
+		os.Setenv("XGOROOT", filepath.Clean(filepath.Join(dir, "../..")))
+	}
+	os.Exit(m.Run())
+}
+
+func runTestDaemon(args []string) {
+	if len(args) == 0 || args[0] != "serve" {
+		os.Exit(2)
+	}
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("listen", "", "")
+	idle := flags.Duration("idle", 0, "")
+	flags.Parse(args[1:])
+
+	ctx := context.Background()
+	listener, err := Listen(ctx, *addr)
+	if err != nil {
+		os.Exit(1)
+	}
+	listener = jsonrpc2.NewIdleListener(*idle, listener)
+	defer listener.Close()
+	NewServer(ctx, listener, &Config{}).Wait()
+}
+
+func skipUnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not tested on windows")
+	}
+}
+
+func TestParseAddr(t *testing.T) {
+	tests := []struct {
+		addr, network, address string
+		ok                     bool
+	}{
+		{"tcp:127.0.0.1:7077", "tcp", "127.0.0.1:7077", true},
+		{"unix:/tmp/xgo.sock", "unix", "/tmp/xgo.sock", true},
+		{"tcp6:[::1]:7077", "tcp6", "[::1]:7077", true},
+		{"udp:127.0.0.1:7077", "", "", false},
+		{"/tmp/xgo.sock", "", "", false},
+		{":7077", "", "", false},
+	}
+	for _, tt := range tests {
+		network, address, err := ParseAddr(tt.addr)
+		if (err == nil) != tt.ok || network != tt.network || address != tt.address {
+			t.Errorf("ParseAddr(%q) = %q, %q, %v", tt.addr, network, address, err)
+		}
+	}
+}
+
+func TestListenStaleSocket(t *testing.T) {
+	skipUnixSocket(t)
+	ctx := context.Background()
+	sock := filepath.Join(t.TempDir(), "xgo.sock")
+
+	// a socket file left by a daemon which is no longer running
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: sock, Net: "unix"})
+	if err != nil {
+		t.Skip("net.ListenUnix:", err)
+	}
+	l.SetUnlinkOnClose(false)
+	l.Close()
+	if _, err = os.Stat(sock); err != nil {
+		t.Fatal("socket file:", err)
+	}
+
+	listener, err := Listen(ctx, "unix:"+sock)
+	if err != nil {
+		t.Fatal("Listen with a stale socket file:", err)
+	}
+	defer listener.Close()
+
+	// the daemon is running: don't remove its socket
+	if _, err = Listen(ctx, "unix:"+sock); err == nil {
+		t.Fatal("Listen: the socket of a running daemon is taken over")
+	}
+	c, err := listener.Dialer().Dial(ctx)
+	if err != nil {
+		t.Fatal("Dial the running daemon:", err)
+	}
+	c.Close()
+}
+
+func TestDaemonDialer(t *testing.T) {
+	skipUnixSocket(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(envTestDaemon, "1")
+
+	sock := filepath.Join(home, "d.sock")
+	addr := "unix:" + sock
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &DaemonConfig{IdleTimeout: 200 * time.Millisecond, StartTimeout: 10 * time.Second}
+	dialer := DaemonDialer(addr, conf, self)
+
+	ctx := context.Background()
+	c, err := dialer.Dial(ctx) // starts the daemon
+	if err != nil {
+		t.Fatal("Dial:", err)
+	}
+	if _, err = os.Stat(filepath.Join(home, ".xgo", "daemon.log")); err != nil {
+		t.Fatal("daemon.log:", err)
+	}
+
+	// the daemon is shared by the next dial
+	var d net.Dialer
+	c2, err := d.DialContext(ctx, "unix", sock)
+	if err != nil {
+		t.Fatal("Dial the running daemon:", err)
+	}
+	c2.Close()
+	c.Close()
+
+	// the daemon shuts down when idle, and the next dial starts a new one
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err = os.Stat(sock); os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the daemon doesn't shut down when idle")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if c, err = dialer.Dial(ctx); err != nil {
+		t.Fatal("Dial after idle shutdown:", err)
+	}
+	c.Close()
+}
@@ -0,0 +1,240 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/goplus/xgo/parser/fsx"
+)
+
+// -----------------------------------------------------------------------------
+
+// document is an open buffer of the client.
+type document struct {
+	uri     DocumentURI
+	path    string
+	version int32
+	text    []byte
+	lines   []int // offsets of line starts, computed lazily
+}
+
+func newDocument(uri DocumentURI, version int32, text []byte) *document {
+	return &document{uri: uri, path: uri.Path(), version: version, text: text}
+}
+
+func (p *document) lineStarts() []int {
+	if p.lines == nil {
+		p.lines = lineStartsOf(p.text)
+	}
+	return p.lines
+}
+
+// applyChange applies a content change event to the document.
+func (p *document) applyChange(chg *TextDocumentContentChangeEvent) {
+	if chg.Range == nil {
+		p.text = []byte(chg.Text)
+	} else {
+		start := p.offsetOf(chg.Range.Start)
+		end := p.offsetOf(chg.Range.End)
+		if end < start {
+			end = start
+		}
+		text := make([]byte, 0, len(p.text)-(end-start)+len(chg.Text))
+		text = append(text, p.text[:start]...)
+		text = append(text, chg.Text...)
+		text = append(text, p.text[end:]...)
+		p.text = text
+	}
+	p.lines = nil
+}
+
+// offsetOf converts a LSP position to a byte offset.
+func (p *document) offsetOf(pos Position) int {
+	return offsetOf(p.text, p.lineStarts(), pos)
+}
+
+// positionOf converts a byte offset to a LSP position.
+func (p *document) positionOf(offset int) Position {
+	return positionOf(p.text, p.lineStarts(), offset)
+}
+
+func lineStartsOf(text []byte) []int {
+	lines := make([]int, 1, 64)
+	for i, c := range text {
+		if c == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+func offsetOf(text []byte, lines []int, pos Position) int {
+	line := int(pos.Line)
+	if line >= len(lines) {
+		return len(text)
+	}
+	off := lines[line]
+	for n := int(pos.Character); n > 0 && off < len(text) && text[off] != '\n'; {
+		r, size := utf8.DecodeRune(text[off:])
+		off += size
+		if r >= 0x10000 {
+			n -= 2
+		} else {
+			n--
+		}
+	}
+	return off
+}
+
+func positionOf(text []byte, lines []int, offset int) Position {
+	if offset > len(text) {
+		offset = len(text)
+	}
+	line := sort.Search(len(lines), func(i int) bool { return lines[i] > offset }) - 1
+	if line < 0 {
+		line = 0
+	}
+	var chr uint32
+	for off := lines[line]; off < offset; {
+		r, size := utf8.DecodeRune(text[off:])
+		off += size
+		if r >= 0x10000 {
+			chr += 2
+		} else {
+			chr++
+		}
+	}
+	return Position{Line: uint32(line), Character: chr}
+}
+
+// -----------------------------------------------------------------------------
+
+// overlay is a parser.FileSystem that reads open documents from memory and
+// falls back to the local file system for all other files.
+type overlay struct {
+	mutex sync.Mutex
+	docs  map[string]*document // path => document
+}
+
+func newOverlay() *overlay {
+	return &overlay{docs: make(map[string]*document)}
+}
+
+func (p *overlay) open(doc *document) {
+	p.mutex.Lock()
+	p.docs[doc.path] = doc
+	p.mutex.Unlock()
+}
+
+func (p *overlay) close(path string) {
+	p.mutex.Lock()
+	delete(p.docs, path)
+	p.mutex.Unlock()
+}
+
+func (p *overlay) get(path string) *document {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.docs[path]
+}
+
+// docsIn returns all open documents in the directory dir.
+func (p *overlay) docsIn(dir string) (docs []*document) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for path, doc := range p.docs {
+		if filepath.Dir(path) == dir {
+			docs = append(docs, doc)
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].path < docs[j].path })
+	return
+}
+
+// content returns the content of a file, either from an open document or
+// from the local file system.
+func (p *overlay) content(path string) ([]byte, error) {
+	if doc := p.get(path); doc != nil {
+		return doc.text, nil
+	}
+	return os.ReadFile(path)
+}
+
+func (p *overlay) ReadDir(dirname string) ([]fs.DirEntry, error) {
+	entries, err := os.ReadDir(dirname)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	found := make(map[string]bool, len(entries))
+	for i, e := range entries {
+		found[e.Name()] = true
+		if doc := p.get(filepath.Join(dirname, e.Name())); doc != nil {
+			entries[i] = docEntry{doc}
+		}
+	}
+	for _, doc := range p.docsIn(dirname) {
+		if name := filepath.Base(doc.path); !found[name] {
+			entries = append(entries, docEntry{doc})
+		}
+	}
+	if len(entries) == 0 && err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (p *overlay) ReadFile(filename string) ([]byte, error) {
+	return p.content(filename)
+}
+
+func (p *overlay) Join(elem ...string) string {
+	return fsx.Local.Join(elem...)
+}
+
+func (p *overlay) Base(filename string) string {
+	return fsx.Local.Base(filename)
+}
+
+func (p *overlay) Abs(path string) (string, error) {
+	return fsx.Local.Abs(path)
+}
+
+// docEntry implements fs.DirEntry and fs.FileInfo for an open document.
+type docEntry struct {
+	doc *document
+}
+
+func (p docEntry) Name() string               { return filepath.Base(p.doc.path) }
+func (p docEntry) IsDir() bool                { return false }
+func (p docEntry) Type() fs.FileMode          { return 0 }
+func (p docEntry) Info() (fs.FileInfo, error) { return p, nil }
+func (p docEntry) Size() int64                { return int64(len(p.doc.text)) }
+func (p docEntry) Mode() fs.FileMode          { return 0644 }
+func (p docEntry) ModTime() time.Time         { return time.Time{} }
+func (p docEntry) Sys() any                   { return nil }
+
+var _ fsx.FileSystem = (*overlay)(nil)
+
+// -----------------------------------------------------------------------------
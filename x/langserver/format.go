@@ -0,0 +1,294 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+
+	"github.com/goplus/xgo/ast"
+	"github.com/goplus/xgo/format"
+	"github.com/goplus/xgo/parser"
+	"github.com/goplus/xgo/token"
+	xformat "github.com/goplus/xgo/x/format"
+)
+
+// -----------------------------------------------------------------------------
+
+// formatDoc returns the document of a formatting request and whether it is a
+// class file. Only XGo source files can be formatted.
+func (p *session) formatDoc(uri DocumentURI) (file string, text []byte, class, ok bool) {
+	file = uri.Path()
+	switch ext := filepath.Ext(file); ext {
+	case ".xgo", ".gop":
+	case ".gox":
+		class = true
+	default:
+		snap := p.snapshotOf(filepath.Dir(file))
+		if snap.mod == nil || !snap.mod.IsClass(ext) {
+			return
+		}
+		class = true
+	}
+	text, err := p.fs.content(file)
+	return file, text, class, err == nil
+}
+
+// formatting implements textDocument/formatting. In smart mode, Go style code
+// of non-class files is converted to XGo style, eg. fmt.Println(x) to echo x.
+func (p *session) formatting(params *DocumentFormattingParams) ([]TextEdit, error) {
+	file, text, class, ok := p.formatDoc(params.TextDocument.URI)
+	if !ok {
+		return nil, nil
+	}
+	var ret []byte
+	var err error
+	if p.smartFormat && !class {
+		fset := token.NewFileSet()
+		var f *ast.File
+		if f, err = parser.ParseFile(fset, file, text, parser.ParseComments); err == nil {
+			xformat.Gopstyle(f)
+			var buf bytes.Buffer
+			if err = format.Node(&buf, fset, f); err == nil {
+				ret = buf.Bytes()
+			}
+		}
+	} else {
+		ret, err = format.Source(text, class, file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRequestFailed, err)
+	}
+	return textEdits(text, ret, 0), nil
+}
+
+// rangeFormatting implements textDocument/rangeFormatting. The range is
+// extended to whole lines, which are formatted as a source fragment.
+func (p *session) rangeFormatting(params *DocumentRangeFormattingParams) ([]TextEdit, error) {
+	file, text, class, ok := p.formatDoc(params.TextDocument.URI)
+	if !ok {
+		return nil, nil
+	}
+	lines := lineStartsOf(text)
+	rg := params.Range
+	start, end := rg.Start.Line, rg.End.Line
+	if rg.End.Character > 0 || end == start {
+		end++
+	}
+	ret, err := formatLines(text, lines, int(start), int(end), class, file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRequestFailed, err)
+	}
+	return ret, nil
+}
+
+// onTypeFormatting implements textDocument/onTypeFormatting. After a newline
+// the statement or declaration ending on the previous line is formatted, and
+// after a '}' the one closed by it is. Nothing happens if the document can't
+// be parsed, which is common when typing.
+func (p *session) onTypeFormatting(params *DocumentOnTypeFormattingParams) ([]TextEdit, error) {
+	file, text, class, ok := p.formatDoc(params.TextDocument.URI)
+	if !ok {
+		return nil, nil
+	}
+	mode := parser.ParseComments
+	if class {
+		mode |= parser.ParseGoPlusClass
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, text, mode)
+	if err != nil {
+		return nil, nil
+	}
+	lines := lineStartsOf(text)
+	off := offsetOf(text, lines, params.Position)
+	switch params.Ch {
+	case "\n":
+		line := int(params.Position.Line)
+		if line == 0 || line >= len(lines) {
+			return nil, nil
+		}
+		off = lines[line-1] + len(bytes.TrimRight(text[lines[line-1]:lines[line]], " \t\r\n"))
+	case "}":
+		if off == 0 || text[off-1] != '}' {
+			return nil, nil
+		}
+	default:
+		return nil, nil
+	}
+
+	base := fset.File(f.Pos()).Base()
+	var node ast.Node
+	ast.Inspect(f, func(n ast.Node) bool {
+		if node != nil || n == nil {
+			return false
+		}
+		switch v := n.(type) {
+		case *ast.FuncDecl:
+			if v.Shadow { // statements of the main entry
+				return true
+			}
+		case ast.Stmt, ast.Decl:
+		default:
+			return true
+		}
+		if int(n.End())-base == off {
+			node = n // the outermost one
+			return false
+		}
+		return int(n.Pos())-base < off && off < int(n.End())-base
+	})
+	if node == nil {
+		return nil, nil
+	}
+	first := fset.Position(node.Pos()).Line - 1
+	last := fset.Position(node.End()).Line
+	ret, err := formatLines(text, lines, first, last, class, file)
+	if err != nil {
+		return nil, nil
+	}
+	return ret, nil
+}
+
+// formatLines formats lines [start, end) of text as a source fragment.
+func formatLines(text []byte, lines []int, start, end int, class bool, file string) ([]TextEdit, error) {
+	from, to := len(text), len(text)
+	if start < len(lines) {
+		from = lines[start]
+	}
+	if end < len(lines) {
+		to = lines[end]
+	}
+	if from >= to {
+		return nil, nil
+	}
+	src := text[from:to]
+	ret, err := format.SourceFragment(src, class, file)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasSuffix(src, []byte{'\n'}) { // the last line of a document
+		ret = bytes.TrimSuffix(ret, []byte{'\n'})
+	}
+	return textEdits(text[:to], append(text[:from:from], ret...), from), nil
+}
+
+// -----------------------------------------------------------------------------
+
+// maxDiffCells limits the size of the table to compute a line diff.
+const maxDiffCells = 1 << 22
+
+// textEdits returns edits that turn text into ret, where text and ret share
+// the first from bytes. The edits replace changed lines only, so that cursor
+// positions and undo history of clients survive.
+func textEdits(text, ret []byte, from int) []TextEdit {
+	a, b := splitLines(text[from:]), splitLines(ret[from:])
+	// trim common prefix and suffix lines
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		from += len(a[0])
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	if len(a) == 0 && len(b) == 0 {
+		return []TextEdit{}
+	}
+
+	lines := lineStartsOf(text)
+	var edits []TextEdit
+	edit := func(off, end int, lines2 []string) {
+		edits = append(edits, TextEdit{
+			Range: Range{
+				Start: positionOf(text, lines, off),
+				End:   positionOf(text, lines, end),
+			},
+			NewText: joinLines(lines2),
+		})
+	}
+	if len(a)*len(b) > maxDiffCells {
+		edit(from, from+len(joinLines(a)), b)
+		return edits
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	n, m := len(a), len(b)
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j, off := 0, 0, from
+	for i < n || j < m {
+		if i < n && j < m && a[i] == b[j] {
+			off += len(a[i])
+			i, j = i+1, j+1
+			continue
+		}
+		// a hunk of changed lines
+		j0, end := j, off
+		for i < n || j < m {
+			if i < n && j < m && a[i] == b[j] {
+				break
+			}
+			if j == m || (i < n && lcs[i+1][j] >= lcs[i][j+1]) {
+				end += len(a[i])
+				i++
+			} else {
+				j++
+			}
+		}
+		edit(off, end, b[j0:j])
+		off = end
+	}
+	return edits
+}
+
+// splitLines splits text into lines, each with its line terminator.
+func splitLines(text []byte) []string {
+	var ret []string
+	for len(text) > 0 {
+		n := bytes.IndexByte(text, '\n') + 1
+		if n == 0 {
+			n = len(text)
+		}
+		ret = append(ret, string(text[:n]))
+		text = text[n:]
+	}
+	return ret
+}
+
+func joinLines(lines []string) string {
+	var b bytes.Buffer
+	for _, line := range lines {
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,203 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// applyEdits applies non-overlapping edits to text.
+func applyEdits(text string, edits []TextEdit) string {
+	doc := &document{text: []byte(text)}
+	sorted := append([]TextEdit(nil), edits...)
+	sort.SliceStable(sorted, func(i, j int) bool { // from the end
+		a, b := sorted[i].Range.Start, sorted[j].Range.Start
+		return a.Line > b.Line || a.Line == b.Line && a.Character > b.Character
+	})
+	for _, e := range sorted {
+		doc.applyChange(&TextDocumentContentChangeEvent{Range: &Range{Start: e.Range.Start, End: e.Range.End}, Text: e.NewText})
+	}
+	return string(doc.text)
+}
+
+func TestTextEdits(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		edits    int
+	}{
+		{"same", "a\nb\n", "a\nb\n", 0},
+		{"one line", "a\nb\nc\n", "a\nB\nc\n", 1},
+		{"two hunks", "a\nb\nc\nd\ne\n", "A\nb\nc\nd\nE\n", 2},
+		{"insert", "a\nc\n", "a\nb\nc\n", 1},
+		{"delete", "a\nb\nc\n", "a\nc\n", 1},
+		{"no newline at end", "a\nb", "a\nB", 1},
+		{"all", "a\n", "b\nc\n", 1},
+		{"empty", "", "a\n", 1},
+	}
+	for _, tt := range tests {
+		edits := textEdits([]byte(tt.old), []byte(tt.new), 0)
+		if edits == nil || len(edits) != tt.edits {
+			t.Errorf("%s: %d edits %v, want %d", tt.name, len(edits), edits, tt.edits)
+		}
+		if got := applyEdits(tt.old, edits); got != tt.new {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.new)
+		}
+	}
+}
+
+// format calls a formatting method and returns text with the edits applied.
+func (c *testClient) format(method string, params any, text string) (string, error) {
+	c.t.Helper()
+	var edits []TextEdit
+	if err := c.call(method, params, &edits); err != nil {
+		return "", err
+	}
+	if edits == nil {
+		c.t.Fatal(method, ": null")
+	}
+	return applyEdits(text, edits), nil
+}
+
+func TestFormatting(t *testing.T) {
+	files := map[string]string{
+		"main.xgo": "x:=1\nif x>0 {\necho  x\n}\n",
+		"bad.xgo":  "x := (\n",
+		"a.go":     "package main\nfunc  f() {}\n",
+		"Rect.gox": "var (\n\tw,h int\n)\necho  w*h\n",
+	}
+	c := newTestClient(t, files)
+	tests := []struct {
+		name, want string
+	}{
+		{"main.xgo", "x := 1\nif x > 0 {\n\techo x\n}\n"},
+		{"Rect.gox", "var (\n\tw, h int\n)\n\necho w*h\n"},
+		{"a.go", "package main\nfunc  f() {}\n"}, // only XGo files are formatted
+	}
+	for _, tt := range tests {
+		got, err := c.format(methodFormatting, &DocumentFormattingParams{TextDocument: c.doc(tt.name)}, files[tt.name])
+		if err != nil {
+			t.Fatalf("formatting %s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("formatting %s:\ngot  %q\nwant %q", tt.name, got, tt.want)
+		}
+	}
+
+	err := c.call(methodFormatting, &DocumentFormattingParams{TextDocument: c.doc("bad.xgo")}, nil)
+	if !errors.Is(err, ErrRequestFailed) {
+		t.Fatal("formatting invalid code:", err)
+	}
+	var edits []TextEdit
+	if err = c.call(methodFormatting, &DocumentFormattingParams{TextDocument: c.doc("none.xgo")}, &edits); err != nil || edits == nil || len(edits) != 0 {
+		t.Fatal("formatting a file not found:", edits, err)
+	}
+
+	// the open document is formatted instead of the file
+	c.open("main.xgo", "echo  1\n")
+	got, err := c.format(methodFormatting, &DocumentFormattingParams{TextDocument: c.doc("main.xgo")}, "echo  1\n")
+	if err != nil || got != "echo 1\n" {
+		t.Fatalf("formatting the open document: %q, %v", got, err)
+	}
+}
+
+func TestSmartFormatting(t *testing.T) {
+	files := map[string]string{
+		"go.mod":   testGoMod,
+		"main.xgo": "import \"fmt\"\n\nfmt.Println(\"hi\")\n",
+	}
+	c := newTestClient(t, files)
+	got, err := c.format(methodFormatting, &DocumentFormattingParams{TextDocument: c.doc("main.xgo")}, files["main.xgo"])
+	if err != nil || got != files["main.xgo"] {
+		t.Fatalf("formatting: %q, %v", got, err)
+	}
+
+	params := &InitializeParams{RootURI: URIOf(c.root), InitializationOptions: &InitializationOptions{SmartFormat: true}}
+	c = dialTestClient(t, newTestServer(t), params)
+	got, err = c.format(methodFormatting, &DocumentFormattingParams{TextDocument: c.doc("main.xgo")}, files["main.xgo"])
+	if err != nil || got != "echo \"hi\"\n" {
+		t.Fatalf("smart formatting: %q, %v", got, err)
+	}
+}
+
+func TestRangeFormatting(t *testing.T) {
+	src := "x:=1\nfunc f( ) {\n\ty:=2\n\techo  y\n}\nz:=3\n"
+	c := newTestClient(t, map[string]string{"main.xgo": src})
+	tests := []struct {
+		start, end Position
+		want       string
+	}{
+		{Position{2, 0}, Position{4, 0}, "x:=1\nfunc f( ) {\n\ty := 2\n\techo y\n}\nz:=3\n"},
+		{Position{3, 3}, Position{3, 3}, "x:=1\nfunc f( ) {\n\ty:=2\n\techo y\n}\nz:=3\n"},
+		{Position{1, 0}, Position{4, 1}, "x:=1\nfunc f() {\n\ty := 2\n\techo y\n}\nz:=3\n"},
+	}
+	for _, tt := range tests {
+		params := &DocumentRangeFormattingParams{TextDocument: c.doc("main.xgo"), Range: Range{Start: tt.start, End: tt.end}}
+		got, err := c.format(methodRangeFormatting, params, src)
+		if err != nil {
+			t.Fatalf("rangeFormatting %v-%v: %v", tt.start, tt.end, err)
+		}
+		if got != tt.want {
+			t.Errorf("rangeFormatting %v-%v:\ngot  %q\nwant %q", tt.start, tt.end, got, tt.want)
+		}
+	}
+
+	// an unbalanced fragment
+	params := &DocumentRangeFormattingParams{TextDocument: c.doc("main.xgo"), Range: Range{Start: Position{3, 0}, End: Position{5, 0}}}
+	if err := c.call(methodRangeFormatting, params, nil); !errors.Is(err, ErrRequestFailed) {
+		t.Fatal("rangeFormatting an unbalanced fragment:", err)
+	}
+}
+
+func TestOnTypeFormatting(t *testing.T) {
+	c := newTestClient(t, nil)
+	tests := []struct {
+		text string
+		pos  Position
+		ch   string
+		want string
+	}{
+		{"x:=1\ny:=2\n", Position{1, 0}, "\n", "x := 1\ny:=2\n"},
+		{"func f( ) {\n\tx:=1\n}\necho  1\n", Position{2, 1}, "}", "func f() {\n\tx := 1\n}\necho  1\n"},
+		{"if true {\n\tx:=1\n\techo  x\n}\n", Position{2, 0}, "\n", "if true {\n\tx := 1\n\techo  x\n}\n"},
+		{"x:=1\n", Position{0, 2}, "}", "x:=1\n"},  // not after a '}'
+		{"x:=(\n", Position{1, 0}, "\n", "x:=(\n"}, // can't be parsed
+		{"x:=1\n", Position{0, 4}, ";", "x:=1\n"},
+	}
+	c.open("main.xgo", "")
+	for _, tt := range tests {
+		c.change("main.xgo", tt.text)
+		params := &DocumentOnTypeFormattingParams{TextDocument: c.doc("main.xgo"), Position: tt.pos, Ch: tt.ch}
+		var edits []TextEdit
+		if err := c.call(methodOnTypeFormatting, params, &edits); err != nil || edits == nil {
+			t.Fatalf("onTypeFormatting %q: %v, %v", tt.text, edits, err)
+		}
+		if got := applyEdits(tt.text, edits); got != tt.want {
+			t.Errorf("onTypeFormatting %q %q:\ngot  %q\nwant %q", tt.text, tt.ch, got, tt.want)
+		}
+	}
+
+	// neither opened nor on disk
+	params := &DocumentOnTypeFormattingParams{TextDocument: c.doc(filepath.Join("sub", "none.xgo")), Ch: "\n"}
+	var edits []TextEdit
+	if err := c.call(methodOnTypeFormatting, params, &edits); err != nil || edits == nil || len(edits) != 0 {
+		t.Fatal("onTypeFormatting a file not found:", edits, err)
+	}
+}
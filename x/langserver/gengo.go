@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+
+// genGoDelay is how long files must stay unchanged before xgo_autogen.go of
+// their directories is generated.
+const genGoDelay = 200 * time.Millisecond
+
+// createProgressTimeout is how long to wait for a client to create a progress
+// token, before a generation starts without reporting its progress.
+const createProgressTimeout = time.Second
+
+// genClient is a client which changed files of a directory, and receives the
+// progress and the result of its generation.
+type genClient interface {
+	// createProgress creates a work done progress token, see
+	// window/workDoneProgress/create. It returns false if the client doesn't
+	// support it.
+	createProgress(ctx context.Context, token ProgressToken) bool
+	notify(method string, params any)
+}
+
+type genClients = map[genClient]none
+
+// genJob is a pending or running generation of a directory.
+type genJob struct {
+	dir     string
+	clients genClients
+	ctx     context.Context
+	cancel  context.CancelFunc
+	prev    *genJob   // the canceled job of the same directory
+	done    chan none // closed when the job finished
+}
+
+// genQueue generates xgo_autogen.go of changed directories in background.
+//
+// Changes are debounced by the caller (see handler.changed). A change of a
+// directory cancels its current job: a canceled job which hasn't taken gen
+// is skipped and reported as canceled, and as tool.GenGoEx can't be
+// interrupted, a running one finishes and is reported as superseded, with
+// its error. The new job starts after it. The clients of a canceled job are
+// taken over by the new one.
+type genQueue struct {
+	mutex sync.Mutex
+	jobs  map[string]*genJob // dir => the latest job
+	gen   sync.Mutex         // generates one directory at a time
+
+	genGo func(dir string) error
+}
+
+func newGenQueue(genGo func(dir string) error) *genQueue {
+	return &genQueue{
+		jobs:  make(map[string]*genJob),
+		genGo: genGo,
+	}
+}
+
+// changed schedules a generation of directory dir for clients.
+func (p *genQueue) changed(dir string, clients genClients) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	next := &genJob{dir: dir, clients: make(genClients), ctx: ctx, cancel: cancel, done: make(chan none)}
+	for c := range clients {
+		next.clients[c] = none{}
+	}
+	if job := p.jobs[dir]; job != nil {
+		job.cancel()
+		for c := range job.clients {
+			next.clients[c] = none{}
+		}
+		next.prev = job
+	}
+	p.jobs[dir] = next
+	go p.run(next)
+}
+
+func (p *genQueue) run(job *genJob) {
+	defer close(job.done)
+	if job.prev != nil {
+		<-job.prev.done
+		job.prev = nil
+	}
+
+	ret := &GenGoResult{Dir: job.dir}
+	ran := job.ctx.Err() == nil && p.generate(job, ret) // not canceled before it starts
+	ret.Canceled = !ran && job.ctx.Err() != nil
+	ret.Superseded = ran && job.ctx.Err() != nil
+	for c := range job.clients {
+		c.notify(methodGenGoResult, ret)
+	}
+
+	p.mutex.Lock()
+	if p.jobs[job.dir] == job {
+		delete(p.jobs, job.dir)
+	}
+	p.mutex.Unlock()
+	job.cancel()
+}
+
+// generate generates the directory of job, and reports whether it ran, that
+// is, it wasn't canceled before taking gen.
+func (p *genQueue) generate(job *genJob, ret *GenGoResult) bool {
+	token := "gengo:" + job.dir
+	var progress []genClient
+	for c := range job.clients {
+		ctx, cancel := context.WithTimeout(job.ctx, createProgressTimeout)
+		if c.createProgress(ctx, token) {
+			progress = append(progress, c)
+		}
+		cancel()
+	}
+
+	p.gen.Lock()
+	if job.ctx.Err() != nil { // canceled while waiting for other directories
+		p.gen.Unlock()
+		return false
+	}
+	for _, c := range progress {
+		c.notify(methodProgress, &ProgressParams{
+			Token: token,
+			Value: &WorkDoneProgressBegin{Kind: "begin", Title: "gengo", Message: job.dir},
+		})
+	}
+	err := p.genGo(job.dir)
+	p.gen.Unlock()
+
+	end := &WorkDoneProgressEnd{Kind: "end"}
+	if err != nil {
+		ret.Error, end.Message = err.Error(), err.Error()
+	} else if job.ctx.Err() != nil {
+		end.Message = "superseded"
+	}
+	for _, c := range progress {
+		c.notify(methodProgress, &ProgressParams{Token: token, Value: end})
+	}
+	return true
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,273 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+
+// fakeGenClient records the progress and results of generations.
+type fakeGenClient struct {
+	progress bool
+	results  chan *GenGoResult
+
+	mutex  sync.Mutex
+	events []string
+}
+
+func newFakeGenClient(progress bool) *fakeGenClient {
+	return &fakeGenClient{progress: progress, results: make(chan *GenGoResult, 10)}
+}
+
+func (p *fakeGenClient) add(event string) {
+	p.mutex.Lock()
+	p.events = append(p.events, event)
+	p.mutex.Unlock()
+}
+
+func (p *fakeGenClient) createProgress(ctx context.Context, token ProgressToken) bool {
+	if p.progress {
+		p.add(fmt.Sprint("create ", token))
+	}
+	return p.progress
+}
+
+func (p *fakeGenClient) notify(method string, params any) {
+	switch v := params.(type) {
+	case *ProgressParams:
+		switch val := v.Value.(type) {
+		case *WorkDoneProgressBegin:
+			p.add(fmt.Sprint("begin ", v.Token))
+		case *WorkDoneProgressEnd:
+			p.add(fmt.Sprint("end ", v.Token, " ", val.Message))
+		}
+	case *GenGoResult:
+		p.add(resultString(v))
+		p.results <- v
+	}
+}
+
+func (p *fakeGenClient) wait(t *testing.T, n int) []string {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-p.results:
+		case <-time.After(10 * time.Second):
+			t.Fatal("timeout waiting for results")
+		}
+	}
+	select {
+	case ret := <-p.results:
+		t.Fatal("unexpected result:", resultString(ret))
+	case <-time.After(50 * time.Millisecond):
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.events
+}
+
+func resultString(ret *GenGoResult) string {
+	s := "result " + ret.Dir
+	if ret.Canceled {
+		s += " canceled"
+	}
+	if ret.Superseded {
+		s += " superseded"
+	}
+	if ret.Error != "" {
+		s += " error: " + ret.Error
+	}
+	return s
+}
+
+// fakeGenGo returns a genGo which records the directories generated. It
+// blocks generating directories in block until the channel is closed.
+func fakeGenGo(block map[string]chan none) (genGo func(dir string) error, started chan string) {
+	started = make(chan string, 10)
+	genGo = func(dir string) error {
+		started <- dir
+		if ch, ok := block[dir]; ok {
+			<-ch
+		}
+		if dir == "bad" {
+			return errors.New("bad dir")
+		}
+		return nil
+	}
+	return
+}
+
+func drain(started chan string) (dirs []string) {
+	for {
+		select {
+		case dir := <-started:
+			dirs = append(dirs, dir)
+		default:
+			return
+		}
+	}
+}
+
+func TestGenQueueSkipStale(t *testing.T) {
+	block := make(chan none)
+	genGo, started := fakeGenGo(map[string]chan none{"a": block})
+	q := newGenQueue(genGo)
+	c := newFakeGenClient(false)
+	clients := genClients{c: {}}
+
+	q.changed("a", clients)
+	<-started // a is generating
+	q.changed("b", clients)
+	q.changed("b", clients) // b is waiting for a: the stale job is skipped
+	q.changed("b", clients)
+	close(block)
+
+	events := c.wait(t, 4)
+	if dirs := drain(started); !reflect.DeepEqual(dirs, []string{"b"}) {
+		t.Fatal("generated after a:", dirs)
+	}
+	sort.Strings(events) // jobs of different directories are not ordered
+	want := []string{"result a", "result b", "result b canceled", "result b canceled"}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events: got %v, want %v", events, want)
+	}
+}
+
+func TestGenQueueCancelRunning(t *testing.T) {
+	block := make(chan none)
+	genGo, started := fakeGenGo(map[string]chan none{"a": block})
+	q := newGenQueue(genGo)
+	c1, c2 := newFakeGenClient(false), newFakeGenClient(false)
+
+	q.changed("a", genClients{c1: {}})
+	<-started
+	q.changed("a", genClients{c2: {}}) // the running job can't be interrupted
+	block <- none{}
+	close(block)
+
+	// c2 only waits for the new job, c1 is reported of both, and the running
+	// one, which has written its files, isn't canceled
+	if events := c2.wait(t, 1); !reflect.DeepEqual(events, []string{"result a"}) {
+		t.Fatal("events of c2:", events)
+	}
+	if events := c1.wait(t, 2); !reflect.DeepEqual(events, []string{"result a superseded", "result a"}) {
+		t.Fatal("events of c1:", events)
+	}
+	if dirs := drain(started); !reflect.DeepEqual(dirs, []string{"a"}) {
+		t.Fatal("generated after canceled:", dirs)
+	}
+}
+
+func TestGenQueueSupersededError(t *testing.T) {
+	block := make(chan none)
+	genGo, started := fakeGenGo(map[string]chan none{"bad": block})
+	q := newGenQueue(genGo)
+	c := newFakeGenClient(true)
+
+	q.changed("bad", genClients{c: {}})
+	<-started
+	q.changed("bad", genClients{c: {}})
+	block <- none{}
+	close(block)
+
+	want := []string{
+		"create gengo:bad", "begin gengo:bad", "end gengo:bad bad dir", "result bad superseded error: bad dir",
+		"create gengo:bad", "begin gengo:bad", "end gengo:bad bad dir", "result bad error: bad dir",
+	}
+	if events := c.wait(t, 2); !reflect.DeepEqual(events, want) {
+		t.Fatalf("events:\ngot  %v\nwant %v", events, want)
+	}
+}
+
+func TestGenQueueClients(t *testing.T) {
+	genGo, _ := fakeGenGo(nil)
+	q := newGenQueue(genGo)
+	c1, c2 := newFakeGenClient(true), newFakeGenClient(false)
+
+	q.changed("bad", genClients{c1: {}})
+	want := []string{"create gengo:bad", "begin gengo:bad", "end gengo:bad bad dir", "result bad error: bad dir"}
+	if events := c1.wait(t, 1); !reflect.DeepEqual(events, want) {
+		t.Fatalf("events of c1: got %v, want %v", events, want)
+	}
+
+	q.changed("a", genClients{c2: {}})
+	if events := c2.wait(t, 1); !reflect.DeepEqual(events, []string{"result a"}) {
+		t.Fatal("events of c2:", events)
+	}
+	if events := c1.wait(t, 0); len(events) != 4 {
+		t.Fatal("c1 is notified of generations it didn't request:", events)
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+func TestGenGoChanged(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "go.mod"), testGoMod)
+	writeTestFile(t, filepath.Join(root, "main.xgo"), "echo \"hi\"\n")
+
+	listener := newTestServer(t)
+	params := &InitializeParams{RootURI: URIOf(root)}
+	params.Capabilities.Window = &WindowClientCapabilities{WorkDoneProgress: true}
+	c1 := dialTestClient(t, listener, params)
+	c2 := dialTestClient(t, listener, &InitializeParams{RootURI: URIOf(root)})
+
+	c1.notify(methodChanged, []string{c1.path("main.xgo")})
+	ret := waitNote(c1, methodGenGoResult, func(ret *GenGoResult) bool { return true })
+	if ret.Dir != root || ret.Error != "" || ret.Canceled {
+		t.Fatalf("gengoResult: %+v", ret)
+	}
+	if _, err := os.Stat(filepath.Join(root, "xgo_autogen.go")); err != nil {
+		t.Fatal("xgo_autogen.go:", err)
+	}
+
+	// the token of $/progress is created by window/workDoneProgress/create
+	create := waitNote(c1, methodWorkDoneProgressCreate, func(*WorkDoneProgressCreateParams) bool { return true })
+	var kinds []string
+	waitNote(c1, methodProgress, func(params *ProgressParams) bool {
+		if params.Token != create.Token {
+			t.Fatalf("$/progress: token %v, want %v", params.Token, create.Token)
+		}
+		kind := params.Value.(map[string]any)["kind"].(string)
+		kinds = append(kinds, kind)
+		return kind == "end"
+	})
+	if !reflect.DeepEqual(kinds, []string{"begin", "end"}) {
+		t.Fatal("$/progress:", kinds)
+	}
+
+	// c2 changed nothing
+	c2.sync()
+	c2.mutex.Lock()
+	defer c2.mutex.Unlock()
+	for _, note := range c2.notes {
+		t.Error("unexpected message to c2:", note.Method)
+	}
+}
+
+// -----------------------------------------------------------------------------
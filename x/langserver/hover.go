@@ -0,0 +1,211 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"bytes"
+	goast "go/ast"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goplus/xgo/ast"
+)
+
+// -----------------------------------------------------------------------------
+
+// hover implements textDocument/hover.
+func (p *session) hover(params *TextDocumentPositionParams) (*Hover, error) {
+	id, ok := p.identAt(params.TextDocument.URI, params.Position)
+	if !ok {
+		return nil, nil
+	}
+	snap := id.snap
+	qf := types.RelativeTo(snap.pkg)
+
+	var b strings.Builder
+	b.WriteString("```xgo\n")
+	b.WriteString(objectString(id.obj, qf))
+	b.WriteString("\n```\n")
+	if doc := docOf(snap, id.obj); doc != "" {
+		b.WriteString("\n")
+		b.WriteString(doc)
+	}
+	if len(id.overloads) > 0 {
+		b.WriteString("\nOverloads:\n")
+		for _, o := range id.overloads {
+			s := "`" + objectString(o, qf) + "`"
+			if o == id.obj {
+				s = "**" + s + "** (selected)"
+			}
+			b.WriteString("- " + s + "\n")
+		}
+	}
+	rg := p.ws.rangeOf(snap.fset.Position(id.start), snap.fset.Position(id.end))
+	return &Hover{
+		Contents: MarkupContent{Kind: Markdown, Value: b.String()},
+		Range:    &rg,
+	}, nil
+}
+
+// objectString returns the declaration of obj in XGo style. Overloaded
+// functions are shown with their user-facing names.
+func objectString(obj types.Object, qf types.Qualifier) string {
+	if members := overloadMembers(obj); members != nil {
+		var b strings.Builder
+		b.WriteString("func " + obj.Name() + " = (")
+		for _, o := range members {
+			b.WriteString("\n\t" + types.TypeString(o.Type(), qf))
+		}
+		b.WriteString("\n)")
+		return b.String()
+	}
+	if fn, ok := obj.(*types.Func); ok {
+		sig := fn.Type().(*types.Signature)
+		var b bytes.Buffer
+		b.WriteString("func ")
+		if recv := sig.Recv(); recv != nil {
+			b.WriteString("(")
+			if name := recv.Name(); name != "" {
+				b.WriteString(name + " ")
+			}
+			b.WriteString(types.TypeString(recv.Type(), qf))
+			b.WriteString(") ")
+		} else if pkg := fn.Pkg(); pkg != nil {
+			if s := qf(pkg); s != "" {
+				b.WriteString(s + ".")
+			}
+		}
+		b.WriteString(displayName(fn))
+		types.WriteSignature(&b, sig, qf)
+		return b.String()
+	}
+	return types.ObjectString(obj, qf)
+}
+
+// docOf returns the doc comment of an object declared in the snapshot.
+func docOf(snap *snapshot, obj types.Object) (doc string) {
+	pos := obj.Pos()
+	if !pos.IsValid() || obj.Pkg() != snap.pkg {
+		return
+	}
+	for _, f := range snap.files {
+		if pos < f.Pos() || pos > f.End() {
+			continue
+		}
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Name.Pos() == pos {
+					return d.Doc.Text()
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					if spec.Pos() <= pos && pos < spec.End() {
+						if cg := specDoc(spec); cg != nil {
+							return cg.Text()
+						}
+						return d.Doc.Text()
+					}
+				}
+			}
+		}
+	}
+	for _, f := range snap.goFiles {
+		if pos < f.Pos() || pos > f.End() {
+			continue
+		}
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *goast.FuncDecl:
+				if d.Name.Pos() == pos {
+					return d.Doc.Text()
+				}
+			case *goast.GenDecl:
+				for _, spec := range d.Specs {
+					if spec.Pos() <= pos && pos < spec.End() {
+						if cg := goSpecDoc(spec); cg != nil {
+							return cg.Text()
+						}
+						return d.Doc.Text()
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+func specDoc(spec ast.Spec) *ast.CommentGroup {
+	switch s := spec.(type) {
+	case *ast.ValueSpec:
+		return s.Doc
+	case *ast.TypeSpec:
+		return s.Doc
+	}
+	return nil
+}
+
+func goSpecDoc(spec goast.Spec) *goast.CommentGroup {
+	switch s := spec.(type) {
+	case *goast.ValueSpec:
+		return s.Doc
+	case *goast.TypeSpec:
+		return s.Doc
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+
+// definition implements textDocument/definition.
+func (p *session) definition(params *TextDocumentPositionParams) ([]Location, error) {
+	id, ok := p.identAt(params.TextDocument.URI, params.Position)
+	if !ok {
+		return nil, nil
+	}
+	if pkgName, ok := id.obj.(*types.PkgName); ok {
+		return p.pkgLocations(id.snap, pkgName.Imported()), nil
+	}
+	if loc, ok := p.locationOf(id.snap, id.obj); ok {
+		return []Location{loc}, nil
+	}
+	return nil, nil
+}
+
+// pkgLocations returns the location of an imported package. It prefers
+// doc.go, otherwise the first source file of the package.
+func (p *session) pkgLocations(snap *snapshot, pkg *types.Package) []Location {
+	var files []string
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		if pos, ok := objectPosition(snap, scope.Lookup(name)); ok {
+			if filepath.Base(pos.Filename) == "doc.go" {
+				return []Location{{URI: URIOf(pos.Filename)}}
+			}
+			files = append(files, pos.Filename)
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	sort.Strings(files)
+	return []Location{{URI: URIOf(files[0])}}
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,135 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"strings"
+	"testing"
+)
+
+var hoverFiles = map[string]string{
+	"main.xgo": `import "strings"
+
+echo add(1, 2), mul(3, 4)
+echo strings.ToUpper("hi")
+`,
+	"add.xgo": `// add returns the sum of a and b.
+func add(a, b int) int {
+	return a + b
+}
+`,
+	"mul.go": `package main
+
+// mul returns the product of a and b.
+func mul(a, b int) int {
+	return a * b
+}
+`,
+}
+
+func TestHover(t *testing.T) {
+	c := newTestClient(t, hoverFiles)
+	tests := []struct {
+		name, sub string
+		want      []string
+	}{
+		{"main.xgo", "add", []string{"func add(a int, b int) int", "add returns the sum of a and b."}},
+		{"main.xgo", "mul", []string{"func mul(a int, b int) int", "mul returns the product of a and b."}},
+		{"main.xgo", "ToUpper", []string{"func strings.ToUpper(s string) string"}},
+		{"add.xgo", "a + b", []string{"var a int"}},
+		{"mul.go", "a * b", []string{"var a int"}},
+	}
+	for _, tt := range tests {
+		var ret Hover
+		params := c.position(tt.name, tt.sub, 0)
+		if err := c.call(methodHover, params, &ret); err != nil {
+			t.Fatal("hover:", err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(ret.Contents.Value, want) {
+				t.Errorf("hover %s %q: got %q, want %q", tt.name, tt.sub, ret.Contents.Value, want)
+			}
+		}
+		if ret.Range == nil || ret.Range.Start != params.Position {
+			t.Errorf("hover %s %q: range %v", tt.name, tt.sub, ret.Range)
+		}
+	}
+}
+
+func TestHoverOpenDocument(t *testing.T) {
+	c := newTestClient(t, hoverFiles)
+	text := "import \"strings\"\n\nx := 1.5\necho x, strings.ToUpper(\"hi\")\n"
+	c.open("main.xgo", text)
+	var ret Hover
+	params := &TextDocumentPositionParams{TextDocument: c.doc("main.xgo"), Position: positionIn(t, text, "x", 1)}
+	if err := c.call(methodHover, params, &ret); err != nil {
+		t.Fatal("hover:", err)
+	}
+	if !strings.Contains(ret.Contents.Value, "var x float64") {
+		t.Fatal("hover of the open document:", ret.Contents.Value)
+	}
+}
+
+func TestHoverNull(t *testing.T) {
+	c := newTestClient(t, hoverFiles)
+	for _, params := range []*TextDocumentPositionParams{
+		c.position("main.xgo", ", 2)", 0),                            // not an identifier
+		{TextDocument: c.doc("none.xgo"), Position: Position{}},      // neither opened nor on disk
+		{TextDocument: c.doc("main.xgo"), Position: Position{99, 0}}, // out of the document
+	} {
+		var ret *Hover
+		if err := c.call(methodHover, params, &ret); err != nil || ret != nil {
+			t.Fatalf("hover %v: %v, %v", params, ret, err)
+		}
+		var locs []Location
+		if err := c.call(methodDefinition, params, &locs); err != nil || locs == nil || len(locs) != 0 {
+			t.Fatalf("definition %v: %v, %v", params, locs, err)
+		}
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	c := newTestClient(t, hoverFiles)
+	tests := []struct {
+		name, sub    string
+		file, defSub string
+	}{
+		{"main.xgo", "add", "add.xgo", "add("},
+		{"main.xgo", "mul", "mul.go", "mul("},
+		{"add.xgo", "a + b", "add.xgo", "a, b"},
+		{"mul.go", "b\n}", "mul.go", "b int"},
+	}
+	for _, tt := range tests {
+		var locs []Location
+		if err := c.call(methodDefinition, c.position(tt.name, tt.sub, 0), &locs); err != nil {
+			t.Fatal("definition:", err)
+		}
+		want := c.at(tt.file, tt.defSub, 0)
+		if len(locs) != 1 || locs[0].URI != c.uri(tt.file) || locs[0].Range.Start != want {
+			t.Errorf("definition %s %q: got %v, want %s %v", tt.name, tt.sub, locs, tt.file, want)
+		}
+	}
+
+	// an imported package
+	var locs []Location
+	if err := c.call(methodDefinition, c.position("main.xgo", "strings", 1), &locs); err != nil {
+		t.Fatal("definition:", err)
+	}
+	if len(locs) != 1 || !strings.Contains(string(locs[0].URI), "/strings/") {
+		t.Fatal("definition of a package:", locs)
+	}
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"bytes"
+	goast "go/ast"
+	"go/types"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/goplus/gogen"
+	"github.com/goplus/xgo/ast"
+	"github.com/goplus/xgo/cl/outline"
+	"github.com/goplus/xgo/token"
+)
+
+// -----------------------------------------------------------------------------
+
+// identInfo describes the identifier at a position of a document.
+type identInfo struct {
+	snap       *snapshot
+	file       string
+	start, end token.Pos
+	obj        types.Object
+
+	// overloads holds all members of an overloaded function, obj is the one
+	// that was picked (or the overload declaration itself).
+	overloads []types.Object
+}
+
+// tokenPos converts a LSP position of file to a token.Pos in snap.fset.
+func (p *session) tokenPos(snap *snapshot, file string, pos Position) token.Pos {
+	var base token.Pos
+	if f, ok := snap.files[file]; ok {
+		base = f.Pos()
+	} else if f, ok := snap.goFiles[file]; ok {
+		base = f.Pos()
+	} else {
+		return token.NoPos
+	}
+	tf := snap.fset.File(base)
+	text, err := p.fs.content(file)
+	if tf == nil || err != nil {
+		return token.NoPos
+	}
+	off := offsetOf(text, lineStartsOf(text), pos)
+	if off > tf.Size() {
+		return token.NoPos
+	}
+	return tf.Pos(off)
+}
+
+// identAt returns the identifier at position pos of document uri.
+func (p *session) identAt(uri DocumentURI, pos Position) (ret *identInfo, ok bool) {
+	file := uri.Path()
+	snap := p.snapshotOf(filepath.Dir(file))
+	if snap.info == nil {
+		return
+	}
+	at := p.tokenPos(snap, file, pos)
+	if !at.IsValid() {
+		return
+	}
+	ret = &identInfo{snap: snap, file: file}
+	if f, found := snap.files[file]; found {
+		id := xgoIdentAt(f, at)
+		if id == nil {
+			if spec := xgoImportAt(f, at); spec != nil {
+				ret.start, ret.end = spec.Path.Pos(), spec.Path.End()
+				ret.obj = snap.info.Implicits[spec]
+			}
+			return ret, ret.obj != nil
+		}
+		ret.start, ret.end = id.Pos(), id.End()
+		ret.obj = snap.info.ObjectOf(id)
+		if decl, objs := snap.info.OverloadOf(id); decl != nil {
+			ret.overloads = objs
+		} else if ret.obj != nil {
+			ret.overloads = overloadMembers(ret.obj)
+		}
+	} else if f, found := snap.goFiles[file]; found {
+		id := goIdentAt(f, at)
+		if id == nil {
+			return
+		}
+		ret.start, ret.end = id.Pos(), id.End()
+		ret.obj = snap.goInfo.ObjectOf(id)
+	}
+	return ret, ret.obj != nil
+}
+
+func xgoIdentAt(f *ast.File, at token.Pos) (ret *ast.Ident) {
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil || at < n.Pos() || at > n.End() {
+			return false
+		}
+		if id, ok := n.(*ast.Ident); ok {
+			ret = id
+		}
+		return true
+	})
+	return
+}
+
+func xgoImportAt(f *ast.File, at token.Pos) *ast.ImportSpec {
+	for _, spec := range f.Imports {
+		if spec.Path != nil && spec.Path.Pos() <= at && at <= spec.Path.End() {
+			return spec
+		}
+	}
+	return nil
+}
+
+func goIdentAt(f *goast.File, at token.Pos) (ret *goast.Ident) {
+	goast.Inspect(f, func(n goast.Node) bool {
+		if n == nil || at < n.Pos() || at > n.End() {
+			return false
+		}
+		if id, ok := n.(*goast.Ident); ok {
+			ret = id
+		}
+		return true
+	})
+	return
+}
+
+// overloadMembers returns members of an overload declaration object.
+func overloadMembers(obj types.Object) []types.Object {
+	if sig, ok := obj.Type().(*types.Signature); ok {
+		if _, objs := gogen.CheckSigFuncExObjects(sig); len(objs) > 1 {
+			return objs
+		}
+	}
+	return nil
+}
+
+// displayName returns the user-facing name of an object, eg. `add` instead
+// of `add__0` for overloaded functions.
+func displayName(obj types.Object) string {
+	if name, _, ok := outline.CheckOverload(obj); ok {
+		return name
+	}
+	return obj.Name()
+}
+
+// -----------------------------------------------------------------------------
+
+// objectPosition returns where obj is declared. Positions of packages
+// imported from export data may refer to $GOROOT.
+func objectPosition(snap *snapshot, obj types.Object) (pos token.Position, ok bool) {
+	if !obj.Pos().IsValid() {
+		return
+	}
+	pos = snap.fset.Position(obj.Pos())
+	if pos.Filename == "" {
+		return
+	}
+	if strings.HasPrefix(pos.Filename, "$GOROOT") {
+		pos.Filename = filepath.Join(runtime.GOROOT(), pos.Filename[len("$GOROOT"):])
+	}
+	return pos, filepath.IsAbs(pos.Filename)
+}
+
+// locationOf returns the location of a declared object.
+func (p *session) locationOf(snap *snapshot, obj types.Object) (loc Location, ok bool) {
+	pos, ok := objectPosition(snap, obj)
+	if !ok {
+		return
+	}
+	text, err := p.fs.content(pos.Filename)
+	if err != nil {
+		return loc, false
+	}
+	if pos.Column <= 1 { // export data may lose columns, search the name in the line
+		lines := lineStartsOf(text)
+		if off := byteOffset(lines, pos); off < len(text) {
+			line := text[off:]
+			if n := bytes.IndexByte(line, '\n'); n >= 0 {
+				line = line[:n]
+			}
+			if i := bytes.Index(line, []byte(obj.Name())); i >= 0 {
+				pos.Column = i + 1
+			}
+		}
+	}
+	return Location{URI: URIOf(pos.Filename), Range: p.ws.rangeOf(pos, pos)}, true
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,592 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/goplus/xgo/x/jsonrpc2"
+)
+
+// This file contains the subset of the Language Server Protocol types
+// used by the XGo LangServer.
+// see https://microsoft.github.io/language-server-protocol/specification
+
+// -----------------------------------------------------------------------------
+
+const (
+	methodInitialize  = "initialize"
+	methodInitialized = "initialized"
+	methodShutdown    = "shutdown"
+	methodExit        = "exit"
+
+	methodDidOpen   = "textDocument/didOpen"
+	methodDidChange = "textDocument/didChange"
+	methodDidClose  = "textDocument/didClose"
+
+	methodPublishDiagnostics = "textDocument/publishDiagnostics"
+
+	methodHover      = "textDocument/hover"
+	methodDefinition = "textDocument/definition"
+	methodCompletion = "textDocument/completion"
+
+	methodDocumentSymbol  = "textDocument/documentSymbol"
+	methodWorkspaceSymbol = "workspace/symbol"
+
+	methodReferences = "textDocument/references"
+	methodRename     = "textDocument/rename"
+
+	methodSemanticTokensFull  = "textDocument/semanticTokens/full"
+	methodSemanticTokensRange = "textDocument/semanticTokens/range"
+
+	methodFormatting       = "textDocument/formatting"
+	methodRangeFormatting  = "textDocument/rangeFormatting"
+	methodOnTypeFormatting = "textDocument/onTypeFormatting"
+
+	methodCodeAction = "textDocument/codeAction"
+
+	methodProgress               = "$/progress"
+	methodWorkDoneProgressCreate = "window/workDoneProgress/create"
+)
+
+var (
+	// ErrRequestFailed is returned if a request is valid but failed, eg. a
+	// rename that would make the code incorrect.
+	ErrRequestFailed = jsonrpc2.NewError(-32803, "request failed")
+)
+
+// DocumentURI represents the URI of a document, eg. file:///home/user/a.xgo.
+type DocumentURI string
+
+// Path returns the local file path of the document.
+func (uri DocumentURI) Path() string {
+	u, err := url.Parse(string(uri))
+	if err != nil || u.Scheme != "file" {
+		return string(uri)
+	}
+	path := u.Path
+	if runtime.GOOS == "windows" {
+		path = strings.TrimPrefix(path, "/")
+	}
+	return filepath.FromSlash(path)
+}
+
+// URIOf returns the DocumentURI of a local file.
+func URIOf(path string) DocumentURI {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	u := url.URL{Scheme: "file", Path: path}
+	return DocumentURI(u.String())
+}
+
+// Position is a zero-based line and UTF-16 character offset in a document.
+type Position struct {
+	Line      uint32 `json:"line"`
+	Character uint32 `json:"character"`
+}
+
+// Range is a range in a document, the End position is exclusive.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location represents a location inside a resource.
+type Location struct {
+	URI   DocumentURI `json:"uri"`
+	Range Range       `json:"range"`
+}
+
+// -----------------------------------------------------------------------------
+
+// InitializeParams is the params of the initialize request.
+type InitializeParams struct {
+	ProcessID             int                    `json:"processId,omitempty"`
+	RootURI               DocumentURI            `json:"rootUri,omitempty"`
+	Capabilities          ClientCapabilities     `json:"capabilities"`
+	InitializationOptions *InitializationOptions `json:"initializationOptions,omitempty"`
+}
+
+// ClientCapabilities describes the features supported by the client.
+type ClientCapabilities struct {
+	Window *WindowClientCapabilities `json:"window,omitempty"`
+}
+
+// WindowClientCapabilities describes the window features supported by the
+// client.
+type WindowClientCapabilities struct {
+	// WorkDoneProgress is true if the client supports progress created by
+	// the server with window/workDoneProgress/create.
+	WorkDoneProgress bool `json:"workDoneProgress,omitempty"`
+}
+
+// InitializationOptions are the XGo specific options of the initialize
+// request.
+type InitializationOptions struct {
+	// SmartFormat converts Go style code to XGo style when formatting a
+	// document, eg. fmt.Println(x) to echo x.
+	SmartFormat bool `json:"smartFormat,omitempty"`
+}
+
+// InitializeResult is the result of the initialize request.
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+	ServerInfo   *ServerInfo        `json:"serverInfo,omitempty"`
+}
+
+// ServerInfo describes the LangServer.
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// TextDocumentSyncKind defines how the client syncs document changes.
+type TextDocumentSyncKind int
+
+const (
+	SyncNone        TextDocumentSyncKind = 0
+	SyncFull        TextDocumentSyncKind = 1
+	SyncIncremental TextDocumentSyncKind = 2
+)
+
+// TextDocumentSyncOptions describes how documents are synced to the server.
+type TextDocumentSyncOptions struct {
+	OpenClose bool                 `json:"openClose"`
+	Change    TextDocumentSyncKind `json:"change"`
+}
+
+// ServerCapabilities describes the features supported by the LangServer.
+type ServerCapabilities struct {
+	TextDocumentSync   *TextDocumentSyncOptions `json:"textDocumentSync,omitempty"`
+	HoverProvider      bool                     `json:"hoverProvider,omitempty"`
+	DefinitionProvider bool                     `json:"definitionProvider,omitempty"`
+	CompletionProvider *CompletionOptions       `json:"completionProvider,omitempty"`
+
+	DocumentSymbolProvider  bool `json:"documentSymbolProvider,omitempty"`
+	WorkspaceSymbolProvider bool `json:"workspaceSymbolProvider,omitempty"`
+	ReferencesProvider      bool `json:"referencesProvider,omitempty"`
+	RenameProvider          bool `json:"renameProvider,omitempty"`
+
+	SemanticTokensProvider *SemanticTokensOptions `json:"semanticTokensProvider,omitempty"`
+
+	DocumentFormattingProvider       bool                             `json:"documentFormattingProvider,omitempty"`
+	DocumentRangeFormattingProvider  bool                             `json:"documentRangeFormattingProvider,omitempty"`
+	DocumentOnTypeFormattingProvider *DocumentOnTypeFormattingOptions `json:"documentOnTypeFormattingProvider,omitempty"`
+	CodeActionProvider               *CodeActionOptions               `json:"codeActionProvider,omitempty"`
+}
+
+// CompletionOptions describes the completion support of the LangServer.
+type CompletionOptions struct {
+	TriggerCharacters []string `json:"triggerCharacters,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+
+// TextDocumentIdentifier identifies a document.
+type TextDocumentIdentifier struct {
+	URI DocumentURI `json:"uri"`
+}
+
+// VersionedTextDocumentIdentifier identifies a specific version of a document.
+type VersionedTextDocumentIdentifier struct {
+	URI     DocumentURI `json:"uri"`
+	Version int32       `json:"version"`
+}
+
+// TextDocumentItem is an item to transfer a document from the client to the server.
+type TextDocumentItem struct {
+	URI        DocumentURI `json:"uri"`
+	LanguageID string      `json:"languageId"`
+	Version    int32       `json:"version"`
+	Text       string      `json:"text"`
+}
+
+// DidOpenTextDocumentParams is the params of textDocument/didOpen.
+type DidOpenTextDocumentParams struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+}
+
+// TextDocumentContentChangeEvent describes a change to a document.
+// If Range is nil, Text is the full content of the document.
+type TextDocumentContentChangeEvent struct {
+	Range *Range `json:"range,omitempty"`
+	Text  string `json:"text"`
+}
+
+// DidChangeTextDocumentParams is the params of textDocument/didChange.
+type DidChangeTextDocumentParams struct {
+	TextDocument   VersionedTextDocumentIdentifier  `json:"textDocument"`
+	ContentChanges []TextDocumentContentChangeEvent `json:"contentChanges"`
+}
+
+// DidCloseTextDocumentParams is the params of textDocument/didClose.
+type DidCloseTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// -----------------------------------------------------------------------------
+
+// DiagnosticSeverity represents the severity of a diagnostic.
+type DiagnosticSeverity int
+
+const (
+	SeverityError       DiagnosticSeverity = 1
+	SeverityWarning     DiagnosticSeverity = 2
+	SeverityInformation DiagnosticSeverity = 3
+	SeverityHint        DiagnosticSeverity = 4
+)
+
+// Diagnostic represents a compiler error or warning.
+type Diagnostic struct {
+	Range    Range              `json:"range"`
+	Severity DiagnosticSeverity `json:"severity,omitempty"`
+	Source   string             `json:"source,omitempty"`
+	Message  string             `json:"message"`
+}
+
+// PublishDiagnosticsParams is the params of textDocument/publishDiagnostics.
+type PublishDiagnosticsParams struct {
+	URI         DocumentURI  `json:"uri"`
+	Version     int32        `json:"version,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// -----------------------------------------------------------------------------
+
+// TextDocumentPositionParams is a position inside a document.
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+// MarkupKind describes the content type of MarkupContent.
+type MarkupKind string
+
+const (
+	PlainText MarkupKind = "plaintext"
+	Markdown  MarkupKind = "markdown"
+)
+
+// MarkupContent represents a string value which content is interpreted
+// based on its kind.
+type MarkupContent struct {
+	Kind  MarkupKind `json:"kind"`
+	Value string     `json:"value"`
+}
+
+// Hover is the result of a hover request.
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+
+// CompletionTriggerKind describes how a completion was triggered.
+type CompletionTriggerKind int
+
+const (
+	TriggerInvoked             CompletionTriggerKind = 1
+	TriggerCharacter           CompletionTriggerKind = 2
+	TriggerForIncompleteResult CompletionTriggerKind = 3
+)
+
+// CompletionContext contains additional information about the context in
+// which a completion request is triggered.
+type CompletionContext struct {
+	TriggerKind      CompletionTriggerKind `json:"triggerKind"`
+	TriggerCharacter string                `json:"triggerCharacter,omitempty"`
+}
+
+// CompletionParams is the params of textDocument/completion.
+type CompletionParams struct {
+	TextDocumentPositionParams
+	Context *CompletionContext `json:"context,omitempty"`
+}
+
+// CompletionItemKind is the kind of a completion entry.
+type CompletionItemKind int
+
+const (
+	TextCompletion      CompletionItemKind = 1
+	MethodCompletion    CompletionItemKind = 2
+	FunctionCompletion  CompletionItemKind = 3
+	FieldCompletion     CompletionItemKind = 5
+	VariableCompletion  CompletionItemKind = 6
+	ClassCompletion     CompletionItemKind = 7
+	InterfaceCompletion CompletionItemKind = 8
+	ModuleCompletion    CompletionItemKind = 9
+	KeywordCompletion   CompletionItemKind = 14
+	SnippetCompletion   CompletionItemKind = 15
+	ConstantCompletion  CompletionItemKind = 21
+	StructCompletion    CompletionItemKind = 22
+	OperatorCompletion  CompletionItemKind = 24
+	TypeParamCompletion CompletionItemKind = 25
+)
+
+// CompletionItem represents a completion entry.
+type CompletionItem struct {
+	Label         string             `json:"label"`
+	Kind          CompletionItemKind `json:"kind,omitempty"`
+	Detail        string             `json:"detail,omitempty"`
+	Documentation *MarkupContent     `json:"documentation,omitempty"`
+	SortText      string             `json:"sortText,omitempty"`
+	FilterText    string             `json:"filterText,omitempty"`
+	InsertText    string             `json:"insertText,omitempty"`
+}
+
+// CompletionList represents a collection of completion items.
+type CompletionList struct {
+	IsIncomplete bool             `json:"isIncomplete"`
+	Items        []CompletionItem `json:"items"`
+}
+
+// -----------------------------------------------------------------------------
+
+// SymbolKind is the kind of a symbol.
+type SymbolKind int
+
+const (
+	FileSymbol          SymbolKind = 1
+	ModuleSymbol        SymbolKind = 2
+	NamespaceSymbol     SymbolKind = 3
+	PackageSymbol       SymbolKind = 4
+	ClassSymbol         SymbolKind = 5
+	MethodSymbol        SymbolKind = 6
+	PropertySymbol      SymbolKind = 7
+	FieldSymbol         SymbolKind = 8
+	ConstructorSymbol   SymbolKind = 9
+	EnumSymbol          SymbolKind = 10
+	InterfaceSymbol     SymbolKind = 11
+	FunctionSymbol      SymbolKind = 12
+	VariableSymbol      SymbolKind = 13
+	ConstantSymbol      SymbolKind = 14
+	StructSymbol        SymbolKind = 23
+	OperatorSymbol      SymbolKind = 25
+	TypeParameterSymbol SymbolKind = 26
+)
+
+// DocumentSymbolParams is the params of textDocument/documentSymbol.
+type DocumentSymbolParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// DocumentSymbol represents a symbol of a document. Symbols can be
+// hierarchical, eg. methods and fields are children of their class.
+type DocumentSymbol struct {
+	Name           string           `json:"name"`
+	Detail         string           `json:"detail,omitempty"`
+	Kind           SymbolKind       `json:"kind"`
+	Range          Range            `json:"range"`
+	SelectionRange Range            `json:"selectionRange"`
+	Children       []DocumentSymbol `json:"children,omitempty"`
+}
+
+// WorkspaceSymbolParams is the params of workspace/symbol.
+type WorkspaceSymbolParams struct {
+	Query string `json:"query"`
+}
+
+// SymbolInformation represents information about a symbol of the workspace.
+type SymbolInformation struct {
+	Name          string     `json:"name"`
+	Kind          SymbolKind `json:"kind"`
+	Location      Location   `json:"location"`
+	ContainerName string     `json:"containerName,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+
+// ReferenceContext controls the result of textDocument/references.
+type ReferenceContext struct {
+	IncludeDeclaration bool `json:"includeDeclaration"`
+}
+
+// ReferenceParams is the params of textDocument/references.
+type ReferenceParams struct {
+	TextDocumentPositionParams
+	Context ReferenceContext `json:"context"`
+}
+
+// RenameParams is the params of textDocument/rename.
+type RenameParams struct {
+	TextDocumentPositionParams
+	NewName string `json:"newName"`
+}
+
+// TextEdit is a textual edit applicable to a document.
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// WorkspaceEdit represents changes to many documents.
+type WorkspaceEdit struct {
+	Changes map[DocumentURI][]TextEdit `json:"changes"`
+}
+
+// -----------------------------------------------------------------------------
+
+// SemanticTokensLegend defines the token types and modifiers used by the
+// LangServer. Tokens refer to them by indexes.
+type SemanticTokensLegend struct {
+	TokenTypes     []string `json:"tokenTypes"`
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+// SemanticTokensOptions describes the semantic tokens support of the LangServer.
+type SemanticTokensOptions struct {
+	Legend SemanticTokensLegend `json:"legend"`
+	Range  bool                 `json:"range,omitempty"`
+	Full   bool                 `json:"full,omitempty"`
+}
+
+// SemanticTokensParams is the params of textDocument/semanticTokens/full.
+type SemanticTokensParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// SemanticTokensRangeParams is the params of textDocument/semanticTokens/range.
+type SemanticTokensRangeParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+}
+
+// SemanticTokens is the result of semantic tokens requests. Each token takes
+// 5 integers in Data: deltaLine, deltaStartChar, length, tokenType and
+// tokenModifiers.
+type SemanticTokens struct {
+	Data []uint32 `json:"data"`
+}
+
+// -----------------------------------------------------------------------------
+
+// ProgressToken identifies a progress, it is an integer or a string.
+type ProgressToken = any
+
+// WorkDoneProgressCreateParams is the params of the
+// window/workDoneProgress/create request.
+type WorkDoneProgressCreateParams struct {
+	Token ProgressToken `json:"token"`
+}
+
+// ProgressParams is the params of $/progress notifications.
+type ProgressParams struct {
+	Token ProgressToken `json:"token"`
+	Value any           `json:"value"`
+}
+
+// WorkDoneProgressBegin is the value of a progress notification which starts
+// a work.
+type WorkDoneProgressBegin struct {
+	Kind        string `json:"kind"` // always "begin"
+	Title       string `json:"title"`
+	Cancellable bool   `json:"cancellable,omitempty"`
+	Message     string `json:"message,omitempty"`
+}
+
+// WorkDoneProgressEnd is the value of a progress notification which ends
+// a work.
+type WorkDoneProgressEnd struct {
+	Kind    string `json:"kind"` // always "end"
+	Message string `json:"message,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+
+// FormattingOptions describes how to format a document. XGo code is always
+// indented by tabs, so the options are ignored.
+type FormattingOptions struct {
+	TabSize      uint32 `json:"tabSize"`
+	InsertSpaces bool   `json:"insertSpaces"`
+}
+
+// DocumentFormattingParams is the params of textDocument/formatting.
+type DocumentFormattingParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Options      FormattingOptions      `json:"options"`
+}
+
+// DocumentRangeFormattingParams is the params of textDocument/rangeFormatting.
+type DocumentRangeFormattingParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+	Options      FormattingOptions      `json:"options"`
+}
+
+// DocumentOnTypeFormattingParams is the params of textDocument/onTypeFormatting.
+type DocumentOnTypeFormattingParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+	Ch           string                 `json:"ch"` // the character typed
+	Options      FormattingOptions      `json:"options"`
+}
+
+// DocumentOnTypeFormattingOptions describes the characters on typing which
+// the LangServer formats the document.
+type DocumentOnTypeFormattingOptions struct {
+	FirstTriggerCharacter string   `json:"firstTriggerCharacter"`
+	MoreTriggerCharacter  []string `json:"moreTriggerCharacter,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+
+// CodeActionKind is the kind of a code action, eg. quickfix.
+type CodeActionKind string
+
+const (
+	QuickFix        CodeActionKind = "quickfix"
+	RefactorRewrite CodeActionKind = "refactor.rewrite"
+)
+
+// CodeActionOptions describes the code action support of the LangServer.
+type CodeActionOptions struct {
+	CodeActionKinds []CodeActionKind `json:"codeActionKinds,omitempty"`
+}
+
+// CodeActionContext carries the diagnostics of the range of a code action
+// request.
+type CodeActionContext struct {
+	Diagnostics []Diagnostic     `json:"diagnostics"`
+	Only        []CodeActionKind `json:"only,omitempty"`
+}
+
+// CodeActionParams is the params of textDocument/codeAction.
+type CodeActionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+	Context      CodeActionContext      `json:"context"`
+}
+
+// CodeAction is a change that can be performed in code, eg. to fix a problem.
+type CodeAction struct {
+	Title       string         `json:"title"`
+	Kind        CodeActionKind `json:"kind,omitempty"`
+	Diagnostics []Diagnostic   `json:"diagnostics,omitempty"`
+	IsPreferred bool           `json:"isPreferred,omitempty"`
+	Edit        *WorkspaceEdit `json:"edit,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,490 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"errors"
+	"fmt"
+	goast "go/ast"
+	gotoken "go/token"
+	"go/types"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/goplus/xgo/cl/outline"
+	"github.com/goplus/xgo/token"
+)
+
+// -----------------------------------------------------------------------------
+
+// objKey identifies a package-level object, a method or a field across
+// packages, as the same object imported by different packages are different
+// types.Object values.
+type objKey struct {
+	pkg, owner, name string
+}
+
+func keyOf(obj types.Object) (key objKey, ok bool) {
+	pkg := obj.Pkg()
+	if pkg == nil {
+		return
+	}
+	key = objKey{pkg: pkg.Path(), name: obj.Name()}
+	switch o := obj.(type) {
+	case *types.Func:
+		if recv := o.Type().(*types.Signature).Recv(); recv != nil {
+			typ := recv.Type()
+			if ptr, ok := typ.(*types.Pointer); ok {
+				typ = ptr.Elem()
+			}
+			named, ok := typ.(*types.Named)
+			if !ok {
+				return key, false
+			}
+			key.owner = named.Obj().Name()
+			return key, true
+		}
+	case *types.Var:
+		if o.IsField() {
+			key.owner = fieldOwner(o)
+			return key, key.owner != ""
+		}
+	}
+	return key, obj.Parent() == pkg.Scope()
+}
+
+// fieldOwner returns the name of the named struct type declaring field fld.
+func fieldOwner(fld *types.Var) string {
+	scope := fld.Pkg().Scope()
+	for _, name := range scope.Names() {
+		if t, ok := scope.Lookup(name).(*types.TypeName); ok && !t.IsAlias() {
+			if st, ok := t.Type().Underlying().(*types.Struct); ok {
+				for i, n := 0, st.NumFields(); i < n; i++ {
+					if st.Field(i) == fld {
+						return name
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// refTarget is the object to find references of. For overloaded functions,
+// it includes the overload declaration and all of its members.
+type refTarget struct {
+	name string // user-facing name
+	objs map[types.Object]bool
+	keys map[objKey]bool
+}
+
+func newRefTarget(id *identInfo) *refTarget {
+	p := &refTarget{
+		name: displayName(id.obj),
+		objs: make(map[types.Object]bool),
+		keys: make(map[objKey]bool),
+	}
+	p.add(id.obj)
+	if _, _, ok := outline.CheckOverload(id.obj); ok { // find the overload declaration
+		if scope := id.obj.Parent(); scope != nil {
+			if decl := scope.Lookup(p.name); decl != nil {
+				for _, o := range overloadMembers(decl) {
+					if o == id.obj {
+						p.add(decl)
+						break
+					}
+				}
+			}
+		}
+	}
+	for obj := range p.objs {
+		for _, o := range overloadMembers(obj) {
+			p.add(o)
+		}
+	}
+	return p
+}
+
+func (p *refTarget) add(obj types.Object) {
+	p.objs[obj] = true
+	if key, ok := keyOf(obj); ok {
+		p.keys[key] = true
+	}
+}
+
+func (p *refTarget) match(obj types.Object) bool {
+	if p.objs[obj] {
+		return true
+	}
+	key, ok := keyOf(obj)
+	return ok && p.keys[key]
+}
+
+// global reports whether references may be found in other packages.
+func (p *refTarget) global() bool {
+	for key := range p.keys {
+		if gotoken.IsExported(key.name) || key.owner != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// reference is an identifier referring to a refTarget.
+type reference struct {
+	snap   *snapshot
+	file   string
+	ident  string
+	pos    token.Pos
+	obj    types.Object
+	isDecl bool
+}
+
+func (p *reference) end() token.Pos {
+	return p.pos + token.Pos(len(p.ident))
+}
+
+// -----------------------------------------------------------------------------
+
+// findRefs returns references of the identifier at position pos of document
+// uri, sorted by file and position.
+func (p *session) findRefs(uri DocumentURI, pos Position) (id *identInfo, t *refTarget, refs []*reference) {
+	id, ok := p.identAt(uri, pos)
+	if !ok {
+		return nil, nil, nil
+	}
+	t = newRefTarget(id)
+	snaps := []*snapshot{id.snap}
+	if t.global() {
+		for _, dir := range p.workspaceDirs() {
+			if dir != id.snap.dir {
+				snaps = append(snaps, p.snapshotOf(dir))
+			}
+		}
+	}
+	seen := make(map[token.Position]bool)
+	add := func(snap *snapshot, pos token.Pos, name string, obj types.Object, isDecl bool) {
+		if obj == nil || !t.match(obj) {
+			return
+		}
+		position := snap.fset.Position(pos)
+		if seen[position] || !p.hasIdent(position, name) {
+			return
+		}
+		seen[position] = true
+		refs = append(refs, &reference{
+			snap: snap, file: position.Filename, ident: name, pos: pos, obj: obj, isDecl: isDecl,
+		})
+	}
+	for _, snap := range snaps {
+		if snap.info == nil {
+			continue
+		}
+		for ident, obj := range snap.info.Defs {
+			add(snap, ident.Pos(), ident.Name, obj, true)
+		}
+		for ident, obj := range snap.info.Uses {
+			add(snap, ident.Pos(), ident.Name, obj, false)
+		}
+		for ident, obj := range snap.goInfo.Defs {
+			add(snap, ident.Pos(), ident.Name, obj, true)
+		}
+		for ident, obj := range snap.goInfo.Uses {
+			add(snap, ident.Pos(), ident.Name, obj, false)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		a, b := refs[i], refs[j]
+		if a.file != b.file {
+			return a.file < b.file
+		}
+		return a.pos < b.pos
+	})
+	return
+}
+
+// hasIdent reports whether identifier name is at position pos. Identifiers
+// synthesized by the compiler, eg. members of overload declarations, are not.
+func (p *session) hasIdent(pos token.Position, name string) bool {
+	text, err := p.fs.content(pos.Filename)
+	if err != nil {
+		return false
+	}
+	off := byteOffset(lineStartsOf(text), pos)
+	return off+len(name) <= len(text) && string(text[off:off+len(name)]) == name
+}
+
+// references implements textDocument/references.
+func (p *session) references(params *ReferenceParams) ([]Location, error) {
+	_, _, refs := p.findRefs(params.TextDocument.URI, params.Position)
+	var ret []Location
+	for _, ref := range refs {
+		if ref.isDecl && !params.Context.IncludeDeclaration {
+			continue
+		}
+		ret = append(ret, p.refLocation(ref))
+	}
+	return ret, nil
+}
+
+func (p *session) refLocation(ref *reference) Location {
+	fset := ref.snap.fset
+	return Location{
+		URI:   URIOf(ref.file),
+		Range: p.ws.rangeOf(fset.Position(ref.pos), fset.Position(ref.end())),
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// rename implements textDocument/rename. References calling a Go name in
+// lowercase from XGo, eg. foo for Foo, are renamed in lowercase too. It fails
+// if the new name would collide with or shadow another declaration, or would
+// change whether a name referenced by other packages is exported.
+func (p *session) rename(params *RenameParams) (*WorkspaceEdit, error) {
+	id, t, refs := p.findRefs(params.TextDocument.URI, params.Position)
+	if id == nil {
+		return nil, fmt.Errorf("%w: no identifier found", ErrRequestFailed)
+	}
+	newName := params.NewName
+	if !token.IsIdentifier(newName) || !gotoken.IsIdentifier(newName) {
+		return nil, fmt.Errorf("%w: invalid identifier %q", ErrRequestFailed, newName)
+	}
+	switch obj := id.obj.(type) {
+	case *types.PkgName:
+		return nil, fmt.Errorf("%w: renaming package names is not supported", ErrRequestFailed)
+	default:
+		if obj.Pkg() == nil || obj.Name() == "_" || isHidden(obj.Name()) {
+			return nil, fmt.Errorf("%w: cannot rename builtin %s", ErrRequestFailed, t.name)
+		}
+	}
+	if newName == t.name {
+		return nil, nil
+	}
+	hasDecl := false
+	for _, ref := range refs {
+		if ref.isDecl {
+			hasDecl = true
+			break
+		}
+	}
+	if !hasDecl {
+		return nil, fmt.Errorf("%w: %s is declared outside the workspace", ErrRequestFailed, t.name)
+	}
+	if gotoken.IsExported(t.name) != gotoken.IsExported(newName) {
+		for _, ref := range refs {
+			if ref.snap.pkg != nil && ref.obj.Pkg() != nil && ref.snap.pkg.Path() != ref.obj.Pkg().Path() {
+				pos := ref.snap.fset.Position(ref.pos)
+				return nil, fmt.Errorf("%w: renaming %s to %s changes whether it's exported, but it's referenced by another package at %v",
+					ErrRequestFailed, t.name, newName, pos)
+			}
+		}
+	}
+	if err := checkRename(t, refs, newName); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRequestFailed, err)
+	}
+
+	ret := &WorkspaceEdit{Changes: make(map[DocumentURI][]TextEdit)}
+	for _, ref := range refs {
+		text, ok := renameIdent(ref.ident, t.name, newName)
+		if !ok {
+			pos := ref.snap.fset.Position(ref.pos)
+			return nil, fmt.Errorf("%w: unexpected reference %s at %v", ErrRequestFailed, ref.ident, pos)
+		}
+		loc := p.refLocation(ref)
+		ret.Changes[loc.URI] = append(ret.Changes[loc.URI], TextEdit{Range: loc.Range, NewText: text})
+	}
+	return ret, nil
+}
+
+// renameIdent returns the new text of an identifier referring to name.
+func renameIdent(ident, name, newName string) (string, bool) {
+	switch {
+	case ident == name:
+		return newName, true
+	case ident == lowerFirst(name):
+		return lowerFirst(newName), true
+	case strings.HasPrefix(ident, name+"__"): // overload members, eg. Add__0
+		return newName + ident[len(name):], true
+	}
+	return "", false
+}
+
+func upperFirst(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[size:]
+}
+
+// checkRename checks if renaming t to newName causes collisions:
+//   - another object named newName in the same scope or type;
+//   - a reference to t would be shadowed by another object named newName;
+//   - a reference to another object named newName would be shadowed by t.
+//
+// As XGo looks up Foo for foo, both cases of the first letter are checked.
+// Positions are reported by the file set of the snapshot they are in.
+func checkRename(t *refTarget, refs []*reference, newName string) error {
+	names := []string{newName, upperFirst(newName)}
+	if names[1] == newName {
+		names[1] = lowerFirst(newName)
+	}
+	conflict := func(obj types.Object) bool {
+		return obj != nil && !t.match(obj)
+	}
+	snaps := make(map[types.Object]*snapshot) // object => snapshot referring to it
+	for _, ref := range refs {
+		snaps[ref.obj] = ref.snap
+	}
+	declared := func(o types.Object, snap *snapshot) string {
+		if snap == nil {
+			return o.Name() + " already declared"
+		}
+		return fmt.Sprintf("%s already declared at %v", o.Name(), snap.fset.Position(o.Pos()))
+	}
+	var scopes []*types.Scope // declaring scopes of t
+	for obj := range t.objs {
+		if key, ok := keyOf(obj); ok && key.owner != "" {
+			owner := obj.Pkg().Scope().Lookup(key.owner)
+			for _, name := range names {
+				o, _, _ := types.LookupFieldOrMethod(owner.Type(), true, obj.Pkg(), name)
+				if conflict(o) {
+					return fmt.Errorf("%s.%s already declared", key.owner, name)
+				}
+			}
+			continue
+		}
+		if scope := obj.Parent(); scope != nil {
+			for _, name := range names {
+				if o := scope.Lookup(name); conflict(o) {
+					return errors.New(declared(o, snaps[obj]))
+				}
+			}
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 { // fields and methods are referred by selectors
+		return nil
+	}
+	inScopes := func(s *types.Scope) bool {
+		for _, v := range scopes {
+			if v == s {
+				return true
+			}
+		}
+		return false
+	}
+	for _, ref := range refs {
+		if ref.isDecl {
+			continue
+		}
+		for _, s := range identScopes(ref.snap, ref.pos) {
+			if inScopes(s) {
+				break
+			}
+			for _, name := range names {
+				if o := s.Lookup(name); conflict(o) {
+					return fmt.Errorf("reference at %v would be shadowed by %s", ref.snap.fset.Position(ref.pos), name)
+				}
+			}
+		}
+	}
+	for _, snap := range snapsOf(refs) {
+		check := func(pos token.Pos, obj types.Object) error {
+			if obj == nil || t.match(obj) || obj.Parent() == nil {
+				return nil
+			}
+			for _, s := range identScopes(snap, pos) {
+				if s == obj.Parent() {
+					return nil
+				}
+				if inScopes(s) {
+					return fmt.Errorf("%s at %v would be shadowed", obj.Name(), snap.fset.Position(pos))
+				}
+			}
+			return nil
+		}
+		for _, name := range names {
+			for ident, obj := range snap.info.Uses {
+				if ident.Name == name {
+					if err := check(ident.Pos(), obj); err != nil {
+						return err
+					}
+				}
+			}
+			for ident, obj := range snap.goInfo.Uses {
+				if ident.Name == name {
+					if err := check(ident.Pos(), obj); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func snapsOf(refs []*reference) (ret []*snapshot) {
+	seen := make(map[*snapshot]bool)
+	for _, ref := range refs {
+		if !seen[ref.snap] {
+			seen[ref.snap] = true
+			ret = append(ret, ref.snap)
+		}
+	}
+	return
+}
+
+// identScopes returns scopes containing position pos of an XGo or Go file,
+// from the innermost to the universe scope.
+func identScopes(snap *snapshot, pos token.Pos) []*types.Scope {
+	for _, f := range snap.files {
+		if f.Pos() <= pos && pos <= f.End() {
+			return scopesAt(snap, f, pos)
+		}
+	}
+	for _, f := range snap.goFiles {
+		if f.Pos() <= pos && pos <= f.End() {
+			return goScopesAt(snap, f, pos)
+		}
+	}
+	return nil
+}
+
+// goScopesAt returns scopes of Go file f containing position pos, from the
+// innermost to the universe scope.
+func goScopesAt(snap *snapshot, f *goast.File, pos token.Pos) (ret []*types.Scope) {
+	inner := snap.pkg.Scope()
+	if s, ok := snap.goInfo.Scopes[f]; ok {
+		inner = s
+	}
+	goast.Inspect(f, func(n goast.Node) bool {
+		if n == nil || pos < n.Pos() || pos > n.End() {
+			return false
+		}
+		if s, ok := snap.goInfo.Scopes[n]; ok {
+			inner = s
+		}
+		return true
+	})
+	for s := inner; s != nil; s = s.Parent() {
+		ret = append(ret, s)
+	}
+	return
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,190 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var renameFiles = map[string]string{
+	"lib/lib.go": `package lib
+
+// Hello returns a greeting.
+func Hello() string {
+	return "hello"
+}
+
+type T struct {
+	Name string
+}
+`,
+	"main.xgo": `import (
+	"strings"
+
+	"example.com/hello/lib"
+)
+
+x := 1
+echo lib.hello(), lib.Hello(), x
+t := lib.T{Name: "xgo"}
+echo strings.ToUpper(t.Name)
+`,
+	"local.xgo": `func local(a, b int) int {
+	return a + b
+}
+`,
+}
+
+// locString returns loc as "file:line:col-col", the file is relative to root.
+func (c *testClient) locString(loc Location) string {
+	name := strings.TrimPrefix(string(loc.URI), string(URIOf(c.root))+"/")
+	rg := loc.Range
+	return fmt.Sprintf("%s:%d:%d-%d", name, rg.Start.Line, rg.Start.Character, rg.End.Character)
+}
+
+func (c *testClient) references(name, sub string, n int, decl bool) string {
+	c.t.Helper()
+	params := &ReferenceParams{TextDocumentPositionParams: *c.position(name, sub, n)}
+	params.Context.IncludeDeclaration = decl
+	var locs []Location
+	if err := c.call(methodReferences, params, &locs); err != nil {
+		c.t.Fatal("references:", err)
+	}
+	if locs == nil {
+		c.t.Fatal("references: null")
+	}
+	ret := make([]string, len(locs))
+	for i, loc := range locs {
+		ret[i] = c.locString(loc)
+	}
+	return strings.Join(ret, " ")
+}
+
+func (c *testClient) rename(name, sub string, n int, newName string) (string, error) {
+	c.t.Helper()
+	params := &RenameParams{TextDocumentPositionParams: *c.position(name, sub, n), NewName: newName}
+	var edit *WorkspaceEdit
+	if err := c.call(methodRename, params, &edit); err != nil {
+		return "", err
+	}
+	if edit == nil {
+		return "", nil
+	}
+	var ret []string
+	for uri, edits := range edit.Changes {
+		for _, e := range edits {
+			ret = append(ret, c.locString(Location{URI: uri, Range: e.Range})+" "+e.NewText)
+		}
+	}
+	sort.Strings(ret)
+	return strings.Join(ret, ", "), nil
+}
+
+func TestReferences(t *testing.T) {
+	c := newTestClient(t, renameFiles)
+	tests := []struct {
+		name, sub string
+		n         int
+		decl      bool
+		want      string
+	}{
+		{"lib/lib.go", "Hello", 1, true, "lib/lib.go:3:5-10 main.xgo:7:9-14 main.xgo:7:22-27"},
+		{"main.xgo", "hello()", 0, false, "main.xgo:7:9-14 main.xgo:7:22-27"},
+		{"main.xgo", "Name", 0, true, "lib/lib.go:8:1-5 main.xgo:8:11-15 main.xgo:9:23-27"},
+		{"main.xgo", "x :=", 0, true, "main.xgo:6:0-1 main.xgo:7:31-32"},
+		{"local.xgo", "a + b", 0, false, "local.xgo:1:8-9"},
+	}
+	for _, tt := range tests {
+		if got := c.references(tt.name, tt.sub, tt.n, tt.decl); got != tt.want {
+			t.Errorf("references %s %q: got %s, want %s", tt.name, tt.sub, got, tt.want)
+		}
+	}
+
+	// not an identifier, and a file neither opened nor on disk
+	for _, params := range []*ReferenceParams{
+		{TextDocumentPositionParams: *c.position("main.xgo", "= 1", 0)},
+		{TextDocumentPositionParams: TextDocumentPositionParams{TextDocument: c.doc("none.xgo")}},
+	} {
+		var locs []Location
+		if err := c.call(methodReferences, params, &locs); err != nil || locs == nil || len(locs) != 0 {
+			t.Fatalf("references %v: %v, %v", params, locs, err)
+		}
+	}
+}
+
+func TestRename(t *testing.T) {
+	c := newTestClient(t, renameFiles)
+	tests := []struct {
+		name, sub string
+		n         int
+		newName   string
+		want      string
+	}{
+		{"lib/lib.go", "Hello", 1, "Greet",
+			"lib/lib.go:3:5-10 Greet, main.xgo:7:22-27 Greet, main.xgo:7:9-14 greet"},
+		{"main.xgo", "Name", 0, "Title",
+			"lib/lib.go:8:1-5 Title, main.xgo:8:11-15 Title, main.xgo:9:23-27 Title"},
+		{"main.xgo", "x :=", 0, "y", "main.xgo:6:0-1 y, main.xgo:7:31-32 y"},
+		{"local.xgo", "a + b", 0, "a", ""},                           // unchanged
+		{"local.xgo", "local", 0, "Local", "local.xgo:0:5-10 Local"}, // exported, but only used by its package
+	}
+	for _, tt := range tests {
+		got, err := c.rename(tt.name, tt.sub, tt.n, tt.newName)
+		if err != nil {
+			t.Fatalf("rename %s %q: %v", tt.name, tt.sub, err)
+		}
+		if got != tt.want {
+			t.Errorf("rename %s %q:\ngot  %s\nwant %s", tt.name, tt.sub, got, tt.want)
+		}
+	}
+}
+
+func TestRenameFailed(t *testing.T) {
+	c := newTestClient(t, renameFiles)
+	tests := []struct {
+		name, sub string
+		newName   string
+		err       string
+	}{
+		{"local.xgo", "a + b", "b", "b already declared"},
+		{"local.xgo", "a + b", "1a", "invalid identifier"},
+		{"main.xgo", "x :=", "t", "t already declared"},
+		{"lib/lib.go", "Hello()", "greet", "referenced by another package at " + c.root + "/main.xgo:8:10"},
+		{"main.xgo", "Name", "name", "referenced by another package"},
+		{"main.xgo", "ToUpper", "Upper", "declared outside the workspace"},
+		{"main.xgo", "strings.", "str", "renaming package names is not supported"},
+		{"main.xgo", "echo", "say", "Println is declared outside the workspace"},
+		{"local.xgo", "int", "integer", "cannot rename builtin int"},
+		{"main.xgo", "= 1", "y", "no identifier found"},
+		{"none.xgo", "", "y", "no identifier found"},
+	}
+	for _, tt := range tests {
+		params := &RenameParams{NewName: tt.newName}
+		params.TextDocument = c.doc(tt.name)
+		if tt.sub != "" {
+			params.TextDocumentPositionParams = *c.position(tt.name, tt.sub, 0)
+		}
+		err := c.call(methodRename, params, nil)
+		if !errors.Is(err, ErrRequestFailed) || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("rename %s %q to %s: got %v, want %s", tt.name, tt.sub, tt.newName, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"context"
+
+	"github.com/goplus/xgo/x/jsonrpc2"
+)
+
+// -----------------------------------------------------------------------------
+
+// Replay plays a client session recorded by `gop serve -record` against a
+// fresh LangServer, and returns the responses which differ from the recorded
+// ones. See jsonrpc2.Replay.
+func Replay(ctx context.Context, records []*jsonrpc2.Record, conf *Config) ([]*jsonrpc2.ReplayDiff, error) {
+	listener, err := jsonrpc2.NetListener(ctx, "tcp", "127.0.0.1:0", jsonrpc2.NetListenOptions{})
+	if err != nil {
+		return nil, err
+	}
+	server := NewServer(ctx, listener, conf)
+	defer func() {
+		listener.Close()
+		server.Wait()
+	}()
+	opts := new(jsonrpc2.ReplayOptions)
+	if conf != nil {
+		opts.Framer = conf.Framer
+	}
+	return jsonrpc2.Replay(ctx, listener.Dialer(), records, opts)
+}
+
+// -----------------------------------------------------------------------------
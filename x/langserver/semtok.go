@@ -0,0 +1,351 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"bytes"
+	"go/types"
+	"path/filepath"
+	"sort"
+
+	"github.com/goplus/xgo/ast"
+	"github.com/goplus/xgo/token"
+)
+
+// -----------------------------------------------------------------------------
+
+// Semantic token types and modifiers, in the order of the legend.
+const (
+	tokNamespace = iota
+	tokType
+	tokParameter
+	tokVariable
+	tokProperty
+	tokFunction
+	tokMethod
+	tokMacro
+	tokKeyword
+	tokString
+	tokNumber
+	tokRegexp
+	tokOperator
+)
+
+const (
+	modDeclaration = 1 << iota
+	modReadonly
+	modDefaultLibrary
+)
+
+var semanticTokensLegend = SemanticTokensLegend{
+	TokenTypes: []string{
+		"namespace", "type", "parameter", "variable", "property", "function", "method",
+		"macro", "keyword", "string", "number", "regexp", "operator",
+	},
+	TokenModifiers: []string{"declaration", "readonly", "defaultLibrary"},
+}
+
+// semToken is a semantic token in byte offsets of a document.
+type semToken struct {
+	off, end  int
+	typ, mods int
+}
+
+// tokenizer collects semantic tokens of the XGo specific constructs of a file,
+// which TextMate grammars can't tell.
+type tokenizer struct {
+	snap *snapshot
+	text []byte
+	base int
+	toks []semToken
+}
+
+func (p *tokenizer) add(pos token.Pos, n int, typ, mods int) {
+	off := int(pos) - p.base
+	if n <= 0 || off < 0 || off+n > len(p.text) {
+		return
+	}
+	p.toks = append(p.toks, semToken{off, off + n, typ, mods})
+}
+
+// addRange adds a token which may span multiple lines.
+func (p *tokenizer) addRange(from, to token.Pos, typ, mods int) {
+	off, end := int(from)-p.base, int(to)-p.base
+	if off < 0 || end > len(p.text) {
+		return
+	}
+	for off < end {
+		n := bytes.IndexByte(p.text[off:end], '\n')
+		if n < 0 {
+			n = end - off
+		}
+		if n > 0 {
+			p.toks = append(p.toks, semToken{off, off + n, typ, mods})
+		}
+		off += n + 1
+	}
+}
+
+// addIdent adds a token for identifier id by the object it denotes.
+func (p *tokenizer) addIdent(id *ast.Ident, mods int) {
+	obj := p.snap.info.ObjectOf(id)
+	if obj == nil {
+		return
+	}
+	typ := tokVariable
+	switch o := obj.(type) {
+	case *types.PkgName:
+		typ = tokNamespace
+	case *types.TypeName:
+		typ = tokType
+	case *types.Var:
+		if o.IsField() {
+			typ = tokProperty
+		}
+	case *types.Const:
+		mods |= modReadonly
+	case *types.Func:
+		typ = tokFunction
+		if o.Type().(*types.Signature).Recv() != nil {
+			typ = tokMethod
+		}
+	case *types.Builtin:
+		typ, mods = tokFunction, mods|modDefaultLibrary
+	}
+	if obj.Pkg() == nil {
+		mods |= modDefaultLibrary
+	}
+	p.add(id.Pos(), len(id.Name), typ, mods)
+}
+
+// addIdents adds tokens for identifiers in expression x.
+func (p *tokenizer) addIdents(x ast.Expr) {
+	ast.Inspect(x, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			p.addIdent(id, 0)
+		}
+		return true
+	})
+}
+
+func (p *tokenizer) textAt(pos token.Pos, s string) bool {
+	off := int(pos) - p.base
+	return off >= 0 && off+len(s) <= len(p.text) && string(p.text[off:off+len(s)]) == s
+}
+
+// addStringLitEx adds tokens of a string with interpolations, eg.
+// "Hello, ${name}". The string parts start at pos.
+func (p *tokenizer) addStringLitEx(pos token.Pos, lit *ast.StringLitEx) {
+	for _, part := range lit.Parts {
+		switch v := part.(type) {
+		case string:
+			p.add(pos, len(v), tokString, 0)
+			pos += token.Pos(len(v))
+		case ast.Expr:
+			start, end := v.Pos(), v.End()
+			for start > pos && !p.textAt(start-2, "${") {
+				start--
+			}
+			for int(end)-p.base < len(p.text) && !p.textAt(end, "}") {
+				end++
+			}
+			p.add(start-2, 2, tokOperator, 0)
+			p.addIdents(v)
+			p.add(end, 1, tokOperator, 0)
+			pos = end + 1
+		}
+	}
+}
+
+func (p *tokenizer) Visit(n ast.Node) ast.Visitor {
+	switch v := n.(type) {
+	case *ast.CallExpr:
+		if v.IsCommand() { // eg. echo x
+			switch fn := v.Fun.(type) {
+			case *ast.Ident:
+				p.addCommand(fn)
+			case *ast.SelectorExpr:
+				p.addCommand(fn.Sel)
+			}
+		}
+	case *ast.DomainTextLit:
+		p.addDomainTextLit(v)
+	case *ast.BasicLit:
+		if v.Extra != nil {
+			p.add(v.ValuePos, 1, tokString, 0)
+			p.addStringLitEx(v.ValuePos+1, v.Extra)
+			p.add(v.End()-1, 1, tokString, 0)
+		}
+	case *ast.NumberUnitLit:
+		p.add(v.ValuePos, len(v.Value), tokNumber, 0)
+		p.add(v.ValuePos+token.Pos(len(v.Value)), len(v.Unit), tokType, modDefaultLibrary)
+	case *ast.EnvExpr:
+		p.add(v.TokPos, 1, tokOperator, 0)
+		if v.Lbrace.IsValid() {
+			p.add(v.Lbrace, 1, tokOperator, 0)
+			p.add(v.Rbrace, 1, tokOperator, 0)
+		}
+		if v.Name != nil {
+			p.add(v.Name.Pos(), len(v.Name.Name), tokVariable, modReadonly)
+		}
+	case *ast.LambdaExpr:
+		p.addLambda(v.Lhs, v.Rarrow)
+	case *ast.LambdaExpr2:
+		p.addLambda(v.Lhs, v.Rarrow)
+	case *ast.ForPhrase:
+		p.add(v.For, len("for"), tokKeyword, 0)
+		for _, id := range []*ast.Ident{v.Key, v.Value} {
+			if id != nil {
+				p.add(id.Pos(), len(id.Name), tokVariable, modDeclaration)
+			}
+		}
+		if p.textAt(v.TokPos, "in") {
+			p.add(v.TokPos, len("in"), tokKeyword, 0)
+		} else {
+			p.add(v.TokPos, len("<-"), tokOperator, 0)
+		}
+		if v.IfPos.IsValid() && p.textAt(v.IfPos, "if") {
+			p.add(v.IfPos, len("if"), tokKeyword, 0)
+		}
+	case *ast.RangeExpr:
+		p.add(v.To, 1, tokOperator, 0)
+		if v.Colon2.IsValid() {
+			p.add(v.Colon2, 1, tokOperator, 0)
+		}
+	case *ast.ErrWrapExpr:
+		n := 1
+		if v.Default != nil && p.textAt(v.TokPos, "?:") {
+			n = 2
+		}
+		p.add(v.TokPos, n, tokOperator, 0)
+	}
+	return p
+}
+
+func (p *tokenizer) addCommand(id *ast.Ident) {
+	typ, mods := tokFunction, 0
+	if obj := p.snap.info.ObjectOf(id); obj != nil {
+		if fn, ok := obj.(*types.Func); ok && fn.Type().(*types.Signature).Recv() != nil {
+			typ = tokMethod
+		}
+		if obj.Pkg() == nil || obj.Pkg().Path() == "" || obj.Pkg() != p.snap.pkg && isXGoBuiltin(id.Name) {
+			mods = modDefaultLibrary // universe or XGo builtins, eg. echo for fmt.Println
+		}
+	}
+	p.add(id.Pos(), len(id.Name), typ, mods)
+}
+
+func isXGoBuiltin(name string) bool {
+	for _, v := range xgoBuiltins {
+		if v.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *tokenizer) addLambda(lhs []*ast.Ident, rarrow token.Pos) {
+	for _, id := range lhs {
+		p.add(id.Pos(), len(id.Name), tokParameter, modDeclaration)
+	}
+	p.add(rarrow, len("=>"), tokOperator, 0)
+}
+
+// addDomainTextLit adds tokens of a domain text literal, eg. json`{"a":1}`.
+// The text is colored as a regexp for regexp domains, or as a string.
+func (p *tokenizer) addDomainTextLit(v *ast.DomainTextLit) {
+	mods := 0
+	for _, tag := range domainTags {
+		if v.Domain.Name == tag {
+			mods = modDefaultLibrary
+			break
+		}
+	}
+	p.add(v.Domain.Pos(), len(v.Domain.Name), tokMacro, mods)
+	typ := tokString
+	if v.Domain.Name == "regexp" || v.Domain.Name == "regexposix" {
+		typ = tokRegexp
+	}
+	end := v.ValuePos + token.Pos(len(v.Value))
+	switch e := v.Extra.(type) {
+	case *ast.DomainTextLitEx: // domainTag`> arg1, arg2, ...
+		for _, arg := range e.Args {
+			p.addIdents(arg)
+		}
+		p.addRange(e.RawPos, end, typ, 0)
+	case *ast.StringLitEx:
+		p.add(v.ValuePos, 1, typ, 0)
+		p.addStringLitEx(v.ValuePos+1, e)
+		p.add(end-1, 1, typ, 0)
+	default:
+		p.addRange(v.ValuePos, end, typ, 0)
+	}
+}
+
+// -----------------------------------------------------------------------------
+
+// semanticTokens implements textDocument/semanticTokens/full and
+// textDocument/semanticTokens/range. If rg is nil, tokens of the whole
+// document are returned.
+func (p *session) semanticTokens(uri DocumentURI, rg *Range) (*SemanticTokens, error) {
+	file := uri.Path()
+	snap := p.snapshotOf(filepath.Dir(file))
+	ret := &SemanticTokens{Data: []uint32{}}
+	f, ok := snap.files[file]
+	if !ok || snap.info == nil {
+		return ret, nil
+	}
+	text, err := p.fs.content(file)
+	if err != nil {
+		return ret, nil
+	}
+	tf := snap.fset.File(f.Pos())
+	if tf == nil || tf.Size() != len(text) { // the snapshot is out of date
+		return ret, nil
+	}
+	t := &tokenizer{snap: snap, text: text, base: tf.Base()}
+	ast.Walk(t, f)
+	sort.SliceStable(t.toks, func(i, j int) bool {
+		return t.toks[i].off < t.toks[j].off
+	})
+
+	lines := lineStartsOf(text)
+	from, to := 0, len(text)
+	if rg != nil {
+		from, to = offsetOf(text, lines, rg.Start), offsetOf(text, lines, rg.End)
+	}
+	var last Position
+	lastEnd := 0
+	for _, tok := range t.toks {
+		if tok.off < lastEnd || tok.end <= from || tok.off >= to { // overlapped or out of range
+			continue
+		}
+		lastEnd = tok.end
+		start, end := positionOf(text, lines, tok.off), positionOf(text, lines, tok.end)
+		delta := start.Character
+		if start.Line == last.Line {
+			delta -= last.Character
+		}
+		ret.Data = append(ret.Data,
+			start.Line-last.Line, delta, end.Character-start.Character,
+			uint32(tok.typ), uint32(tok.mods))
+		last = start
+	}
+	return ret, nil
+}
+
+// -----------------------------------------------------------------------------
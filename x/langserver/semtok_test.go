@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const semtokSrc = `import "os"
+
+func apply(x int, fn func(int) int) int {
+	return fn(x)
+}
+
+name := "xgo"
+echo "Hello, ${name}!"
+echo apply(2, x => x * 2)
+evens := [x for x in 1:10 if x%2 == 0]
+echo evens
+f := os.open("a.txt")?:nil
+echo f, ${HOME}, regexp` + "`^[a-z]+$`" + `
+`
+
+// decodeTokens returns semantic tokens as lines of "line:col text type mods".
+func decodeTokens(text string, data []uint32) string {
+	lines := strings.Split(text, "\n")
+	legend := semanticTokensLegend
+	var b strings.Builder
+	var line, col uint32
+	for i := 0; i+5 <= len(data); i += 5 {
+		if data[i] != 0 {
+			col = 0
+		}
+		line += data[i]
+		col += data[i+1]
+		var mods []string
+		for j, mod := range legend.TokenModifiers {
+			if data[i+4]&(1<<j) != 0 {
+				mods = append(mods, mod)
+			}
+		}
+		tok := lines[line][col : col+data[i+2]] // ASCII only
+		fmt.Fprintf(&b, "%d:%d %s %s", line, col, tok, legend.TokenTypes[data[i+3]])
+		if mods != nil {
+			b.WriteString(" " + strings.Join(mods, ","))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func TestSemanticTokens(t *testing.T) {
+	c := newTestClient(t, map[string]string{"main.xgo": semtokSrc, "a.go": "package main\n"})
+	var ret SemanticTokens
+	if err := c.call(methodSemanticTokensFull, &SemanticTokensParams{TextDocument: c.doc("main.xgo")}, &ret); err != nil {
+		t.Fatal("semanticTokens/full:", err)
+	}
+	want := `7:0 echo function defaultLibrary
+7:5 " string
+7:6 Hello,  string
+7:13 ${ operator
+7:15 name variable
+7:19 } operator
+7:20 ! string
+7:21 " string
+8:0 echo function defaultLibrary
+8:14 x parameter declaration
+8:16 => operator
+9:12 for keyword
+9:16 x variable declaration
+9:18 in keyword
+9:22 : operator
+9:26 if keyword
+10:0 echo function defaultLibrary
+11:21 ?: operator
+12:0 echo function defaultLibrary
+12:8 $ operator
+12:9 { operator
+12:10 HOME variable readonly
+12:14 } operator
+12:17 regexp macro defaultLibrary
+12:23 ` + "`^[a-z]+$`" + ` regexp
+`
+	if got := decodeTokens(semtokSrc, ret.Data); got != want {
+		t.Fatalf("semanticTokens/full:\n%s\nwant:\n%s", got, want)
+	}
+
+	rg := &SemanticTokensRangeParams{TextDocument: c.doc("main.xgo")}
+	rg.Range.Start, rg.Range.End = Position{Line: 8, Character: 16}, Position{Line: 9, Character: 20}
+	if err := c.call(methodSemanticTokensRange, rg, &ret); err != nil {
+		t.Fatal("semanticTokens/range:", err)
+	}
+	want = "8:16 => operator\n9:12 for keyword\n9:16 x variable declaration\n9:18 in keyword\n"
+	if got := decodeTokens(semtokSrc, ret.Data); got != want {
+		t.Fatalf("semanticTokens/range:\n%s\nwant:\n%s", got, want)
+	}
+
+	// only XGo files have tokens, and files not found have none
+	for _, name := range []string{"a.go", "none.xgo"} {
+		var ret *SemanticTokens
+		if err := c.call(methodSemanticTokensFull, &SemanticTokensParams{TextDocument: c.doc(name)}, &ret); err != nil {
+			t.Fatal("semanticTokens/full:", err)
+		}
+		if ret == nil || ret.Data == nil || len(ret.Data) != 0 {
+			t.Fatalf("semanticTokens/full of %s: %v", name, ret)
+		}
+	}
+}
+
+func TestSemanticTokensOpenDocument(t *testing.T) {
+	c := newTestClient(t, map[string]string{"main.xgo": semtokSrc})
+	text := "x := 1\necho x\n"
+	c.open("main.xgo", text)
+	var ret SemanticTokens
+	if err := c.call(methodSemanticTokensFull, &SemanticTokensParams{TextDocument: c.doc("main.xgo")}, &ret); err != nil {
+		t.Fatal("semanticTokens/full:", err)
+	}
+	if got := decodeTokens(text, ret.Data); got != "1:0 echo function defaultLibrary\n" {
+		t.Fatalf("semanticTokens/full of the open document:\n%s", got)
+	}
+}
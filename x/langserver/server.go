@@ -19,12 +19,15 @@ package langserver
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/goplus/xgo/tool"
 	"github.com/goplus/xgo/x/jsonrpc2"
+	"github.com/goplus/xgo/x/watcher"
 	"github.com/goplus/xgo/x/xgoprojs"
 )
 
@@ -41,6 +44,33 @@ type Config struct {
 	// Framer allows control over the message framing and encoding.
 	// If nil, HeaderFramer will be used.
 	Framer jsonrpc2.Framer
+
+	// Interceptors wrap the handling of requests, eg. for logging or metrics.
+	Interceptors []jsonrpc2.Interceptor
+
+	// MethodTimeouts limits how long a request of a method may take, the key
+	// "" is for other methods. See jsonrpc2.ConnectionOptions.MethodTimeouts.
+	MethodTimeouts map[string]time.Duration
+}
+
+// NewFramer returns the framer named name with the maximum size of a message
+// read (0 means jsonrpc2.DefaultMaxMessageSize). name is one of:
+//   - header: messages with Content-Length headers, as LSP does (default).
+//   - raw: newline-delimited JSON messages.
+//   - length: messages with a 4-byte big-endian length prefix.
+func NewFramer(name string, maxSize int64) (jsonrpc2.Framer, error) {
+	var f jsonrpc2.Framer
+	switch name {
+	case "header", "":
+		f = jsonrpc2.HeaderFramer()
+	case "raw":
+		f = jsonrpc2.RawFramer()
+	case "length":
+		f = jsonrpc2.LengthFramer()
+	default:
+		return nil, fmt.Errorf("unknown framer %q: want header, raw or length", name)
+	}
+	return jsonrpc2.LimitFramer(f, maxSize)
 }
 
 // NewServer creates a new LangServer and returns it.
@@ -50,13 +80,14 @@ func NewServer(ctx context.Context, listener Listener, conf *Config) (ret *Serve
 		func(ctx context.Context, c *jsonrpc2.Connection) (ret jsonrpc2.ConnectionOptions) {
 			if conf != nil {
 				ret.Framer = conf.Framer
+				ret.Interceptors = conf.Interceptors
+				ret.MethodTimeouts = conf.MethodTimeouts
 			}
-			ret.Handler = h
+			ret.Handler = newSession(h, c)
 			// ret.OnInternalError = h.OnInternalError
 			return
 		}))
 	h.server = ret
-	go h.runLoop()
 	return
 }
 
@@ -65,16 +96,46 @@ func NewServer(ctx context.Context, listener Listener, conf *Config) (ret *Serve
 type none = struct{}
 
 type handler struct {
-	mutex sync.Mutex
-	dirty map[string]none
+	mutex   sync.Mutex
+	waiting map[string]genClients // dir => clients which changed files of it
+
+	changes *watcher.Debouncer
+	queue   *genQueue
+	server  *Server
 
-	server *Server
+	diskVer uint64 // must only be accessed using atomic operations
 }
 
 func newHandle() *handler {
-	return &handler{
-		dirty: make(map[string]none),
+	p := &handler{
+		waiting: make(map[string]genClients),
 	}
+	p.queue = newGenQueue(func(dir string) error {
+		_, _, err := tool.GenGoEx(dir, nil, true, tool.GenFlagPrompt)
+		p.diskChanged()
+		return err
+	})
+	p.changes = watcher.NewDebouncer(genGoDelay, func(events []watcher.Event) {
+		for _, dir := range watcher.Dirs(events) {
+			dir = filepath.FromSlash(dir)
+			p.mutex.Lock()
+			clients := p.waiting[dir]
+			delete(p.waiting, dir)
+			p.mutex.Unlock()
+			p.queue.changed(dir, clients)
+		}
+	})
+	return p
+}
+
+// diskVersion returns the version of files on disk, which changes each time
+// the client reports changed files or xgo_autogen.go files are generated.
+func (p *handler) diskVersion() uint64 {
+	return atomic.LoadUint64(&p.diskVer)
+}
+
+func (p *handler) diskChanged() {
+	atomic.AddUint64(&p.diskVer, 1)
 }
 
 /*
@@ -83,52 +144,50 @@ func (p *handler) OnInternalError(err error) {
 }
 */
 
-func (p *handler) runLoop() {
-	const (
-		duration = time.Second / 100
-	)
-	for {
-		var dir string
+// changed schedules generating xgo_autogen.go of directories of the files
+// changed by client c, once no file changes for genGoDelay.
+func (p *handler) changed(c genClient, files []string) {
+	p.diskChanged()
+	for _, file := range files {
+		file = filepath.Clean(file)
+		dir := filepath.Dir(file)
 		p.mutex.Lock()
-		for dir = range p.dirty {
-			delete(p.dirty, dir)
-			break
+		clients := p.waiting[dir]
+		if clients == nil {
+			clients = make(genClients)
+			p.waiting[dir] = clients
 		}
+		clients[c] = none{}
 		p.mutex.Unlock()
-		if dir == "" {
-			time.Sleep(duration)
-			continue
-		}
-		tool.GenGoEx(dir, nil, true, tool.GenFlagPrompt)
-	}
-}
-
-func (p *handler) Changed(files []string) {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
-
-	for _, file := range files {
-		dir := filepath.Dir(file)
-		p.dirty[dir] = none{}
+		p.changes.Add(watcher.Event{Name: filepath.ToSlash(file), Kind: watcher.Modified})
 	}
 }
 
 func (p *handler) Handle(ctx context.Context, req *jsonrpc2.Request) (result any, err error) {
 	switch req.Method {
-	case methodChanged:
-		var files []string
-		err = json.Unmarshal(req.Params, &files)
-		if err != nil {
-			return
-		}
-		p.Changed(files)
 	case methodGenGo:
 		var pattern []string
 		err = json.Unmarshal(req.Params, &pattern)
 		if err != nil {
 			return
 		}
-		err = GenGo(pattern...)
+		// GenGo can't be interrupted: if ctx is done, it goes on in background
+		// and the request returns, so that it doesn't hang the connection.
+		done := make(chan error, 1)
+		go func() {
+			p.queue.gen.Lock()
+			defer p.queue.gen.Unlock()
+			err := GenGo(pattern...)
+			p.diskChanged()
+			done <- err
+		}()
+		select {
+		case err = <-done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	default:
+		err = jsonrpc2.ErrNotHandled
 	}
 	return
 }
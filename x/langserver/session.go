@@ -0,0 +1,340 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/goplus/xgo/env"
+	"github.com/goplus/xgo/x/jsonrpc2"
+)
+
+// -----------------------------------------------------------------------------
+
+// session serves the standard LSP methods for one client connection.
+// Methods it doesn't understand are delegated to the shared handler.
+type session struct {
+	*handler
+	conn *jsonrpc2.Connection
+
+	fs    *overlay
+	ws    *workspace
+	snaps map[string]*snapshot       // dir => last snapshot
+	diags map[string]map[string]bool // dir => files with published diagnostics
+
+	rootURI     DocumentURI
+	smartFormat bool
+	progress    bool // the client supports window/workDoneProgress/create
+}
+
+func newSession(h *handler, conn *jsonrpc2.Connection) *session {
+	fs := newOverlay()
+	return &session{
+		handler: h,
+		conn:    conn,
+		fs:      fs,
+		ws:      newWorkspace(fs, h.diskVersion),
+		snaps:   make(map[string]*snapshot),
+		diags:   make(map[string]map[string]bool),
+	}
+}
+
+func unmarshalParams(req *jsonrpc2.Request, params any) error {
+	if err := json.Unmarshal(req.Params, params); err != nil {
+		return fmt.Errorf("%w: %s", jsonrpc2.ErrInvalidParams, err)
+	}
+	return nil
+}
+
+var jsonNull = json.RawMessage("null")
+
+// orNull returns null instead of a nil result, as calls must have a result.
+func orNull[T any](ret *T, err error) (any, error) {
+	if err != nil {
+		return nil, err
+	}
+	if ret == nil {
+		return jsonNull, nil
+	}
+	return ret, nil
+}
+
+// orEmpty returns an empty list instead of a nil result.
+func orEmpty[T any](ret []T, err error) (any, error) {
+	if err != nil {
+		return nil, err
+	}
+	if ret == nil {
+		return []T{}, nil
+	}
+	return ret, nil
+}
+
+func (p *session) Handle(ctx context.Context, req *jsonrpc2.Request) (result any, err error) {
+	switch req.Method {
+	case methodInitialize:
+		var params InitializeParams
+		if err = unmarshalParams(req, &params); err != nil {
+			return
+		}
+		p.rootURI = params.RootURI
+		if window := params.Capabilities.Window; window != nil {
+			p.progress = window.WorkDoneProgress
+		}
+		if opts := params.InitializationOptions; opts != nil {
+			p.smartFormat = opts.SmartFormat
+		}
+		return p.initialize(), nil
+	case methodInitialized:
+	case methodShutdown:
+		return jsonNull, nil
+	case methodExit:
+		go p.conn.Close()
+	case methodChanged:
+		var files []string
+		if err = unmarshalParams(req, &files); err != nil {
+			return
+		}
+		p.changed(p, files)
+	case methodDidOpen:
+		var params DidOpenTextDocumentParams
+		if err = unmarshalParams(req, &params); err != nil {
+			return
+		}
+		item := &params.TextDocument
+		doc := newDocument(item.URI, item.Version, []byte(item.Text))
+		p.fs.open(doc)
+		p.publish(ctx, filepath.Dir(doc.path))
+	case methodDidChange:
+		var params DidChangeTextDocumentParams
+		if err = unmarshalParams(req, &params); err != nil {
+			return
+		}
+		doc := p.fs.get(params.TextDocument.URI.Path())
+		if doc == nil {
+			return nil, fmt.Errorf("%w: document not opened - %s", jsonrpc2.ErrInvalidParams, params.TextDocument.URI)
+		}
+		for i := range params.ContentChanges {
+			doc.applyChange(&params.ContentChanges[i])
+		}
+		doc.version = params.TextDocument.Version
+		p.publish(ctx, filepath.Dir(doc.path))
+	case methodDidClose:
+		var params DidCloseTextDocumentParams
+		if err = unmarshalParams(req, &params); err != nil {
+			return
+		}
+		path := params.TextDocument.URI.Path()
+		p.fs.close(path)
+		p.publish(ctx, filepath.Dir(path))
+	case methodHover:
+		var params TextDocumentPositionParams
+		if err = unmarshalParams(req, &params); err != nil {
+			return
+		}
+		return orNull(p.hover(&params))
+	case methodDefinition:
+		var params TextDocumentPositionParams
+		if err = unmarshalParams(req, &params); err != nil {
+			return
+		}
+		return orEmpty(p.definition(&params))
+	case methodCompletion:
+		var params CompletionParams
+		if err = unmarshalParams(req, &params); err != nil {
+			return
+		}
+		return orNull(p.completion(&params))
+	case methodDocumentSymbol:
+		var params DocumentSymbolParams
+		if err = unmarshalParams(req, &params); err != nil {
+			return
+		}
+		return orEmpty(p.documentSymbol(&params))
+	case methodWorkspaceSymbol:
+		var params WorkspaceSymbolParams
+		if err = unmarshalParams(req, &params); err != nil {
+			return
+		}
+		return orEmpty(p.workspaceSymbol(&params))
+	case methodReferences:
+		var params ReferenceParams
+		if err = unmarshalParams(req, &params); err != nil {
+			return
+		}
+		return orEmpty(p.references(&params))
+	case methodRename:
+		var params RenameParams
+		if err = unmarshalParams(req, &params); err != nil {
+			return
+		}
+		return orNull(p.rename(&params))
+	case methodSemanticTokensFull:
+		var params SemanticTokensParams
+		if err = unmarshalParams(req, &params); err != nil {
+			return
+		}
+		return orNull(p.semanticTokens(params.TextDocument.URI, nil))
+	case methodSemanticTokensRange:
+		var params SemanticTokensRangeParams
+		if err = unmarshalParams(req, &params); err != nil {
+			return
+		}
+		return orNull(p.semanticTokens(params.TextDocument.URI, &params.Range))
+	case methodFormatting:
+		var params DocumentFormattingParams
+		if err = unmarshalParams(req, &params); err != nil {
+			return
+		}
+		return orEmpty(p.formatting(&params))
+	case methodRangeFormatting:
+		var params DocumentRangeFormattingParams
+		if err = unmarshalParams(req, &params); err != nil {
+			return
+		}
+		return orEmpty(p.rangeFormatting(&params))
+	case methodOnTypeFormatting:
+		var params DocumentOnTypeFormattingParams
+		if err = unmarshalParams(req, &params); err != nil {
+			return
+		}
+		return orEmpty(p.onTypeFormatting(&params))
+	case methodCodeAction:
+		var params CodeActionParams
+		if err = unmarshalParams(req, &params); err != nil {
+			return
+		}
+		return orEmpty(p.codeAction(&params))
+	default:
+		return p.handler.Handle(ctx, req)
+	}
+	return
+}
+
+func (p *session) initialize() *InitializeResult {
+	return &InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync: &TextDocumentSyncOptions{
+				OpenClose: true,
+				Change:    SyncIncremental,
+			},
+			HoverProvider:      true,
+			DefinitionProvider: true,
+			CompletionProvider: &CompletionOptions{
+				TriggerCharacters: []string{"."},
+			},
+			DocumentSymbolProvider:  true,
+			WorkspaceSymbolProvider: true,
+			ReferencesProvider:      true,
+			RenameProvider:          true,
+			SemanticTokensProvider: &SemanticTokensOptions{
+				Legend: semanticTokensLegend,
+				Range:  true,
+				Full:   true,
+			},
+			DocumentFormattingProvider:      true,
+			DocumentRangeFormattingProvider: true,
+			DocumentOnTypeFormattingProvider: &DocumentOnTypeFormattingOptions{
+				FirstTriggerCharacter: "}",
+				MoreTriggerCharacter:  []string{"\n"},
+			},
+			CodeActionProvider: &CodeActionOptions{
+				CodeActionKinds: []CodeActionKind{QuickFix, RefactorRewrite},
+			},
+		},
+		ServerInfo: &ServerInfo{Name: "xgo", Version: env.Version()},
+	}
+}
+
+// createProgress implements genClient.
+func (p *session) createProgress(ctx context.Context, token ProgressToken) bool {
+	if !p.progress {
+		return false
+	}
+	params := &WorkDoneProgressCreateParams{Token: token}
+	return p.conn.Call(ctx, methodWorkDoneProgressCreate, params).Await(ctx, nil) == nil
+}
+
+// notify implements genClient.
+func (p *session) notify(method string, params any) {
+	p.conn.Notify(context.Background(), method, params)
+}
+
+// -----------------------------------------------------------------------------
+
+// snapshotOf returns the last snapshot of directory dir, checking it again if
+// files on disk have changed since.
+func (p *session) snapshotOf(dir string) *snapshot {
+	if snap, ok := p.snaps[dir]; ok && snap.diskVer == p.diskVersion() {
+		return snap
+	}
+	snap := p.ws.check(dir)
+	p.snaps[dir] = snap
+	return snap
+}
+
+// publish type checks the package in directory dir and pushes diagnostics of
+// its files to the client. Files whose errors have been fixed get an empty
+// diagnostics list.
+func (p *session) publish(ctx context.Context, dir string) {
+	snap := p.ws.check(dir)
+	p.snaps[dir] = snap
+
+	docs := p.fs.docsIn(dir)
+	fallback := ""
+	if len(docs) > 0 {
+		fallback = docs[0].path
+	}
+	diags := p.ws.diagnostics(snap, fallback)
+	files := make(map[string]bool, len(diags))
+	for file := range diags {
+		files[file] = true
+	}
+	for file := range p.diags[dir] {
+		if _, ok := diags[file]; !ok {
+			diags[file] = nil
+		}
+	}
+	for _, doc := range docs {
+		if _, ok := diags[doc.path]; !ok {
+			diags[doc.path] = nil
+		}
+	}
+	p.diags[dir] = files
+
+	names := make([]string, 0, len(diags))
+	for file := range diags {
+		names = append(names, file)
+	}
+	sort.Strings(names)
+	for _, file := range names {
+		params := &PublishDiagnosticsParams{URI: URIOf(file), Diagnostics: diags[file]}
+		if params.Diagnostics == nil {
+			params.Diagnostics = []Diagnostic{}
+		}
+		if doc := p.fs.get(file); doc != nil {
+			params.Version = doc.version
+		}
+		p.conn.Notify(ctx, methodPublishDiagnostics, params)
+	}
+}
+
+// -----------------------------------------------------------------------------
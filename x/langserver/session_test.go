@@ -0,0 +1,375 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goplus/xgo/ast"
+	"github.com/goplus/xgo/x/jsonrpc2"
+	"github.com/goplus/xgo/x/jsonrpc2/jsonrpc2test"
+)
+
+// -----------------------------------------------------------------------------
+
+const testGoMod = "module example.com/hello\n\ngo 1.18\n"
+
+// testClient is a LSP client of a fresh LangServer over an in-memory pipe,
+// working on a fixture module in a temporary directory.
+type testClient struct {
+	t    *testing.T
+	root string
+	conn *jsonrpc2.Connection
+
+	mutex sync.Mutex
+	notes []*jsonrpc2.Request // notifications and calls from the server
+	vers  map[string]int32    // file => version of the open document
+}
+
+// newTestClient writes files (name => content) to a new module, starts a
+// LangServer and initializes it with the module as the workspace root.
+func newTestClient(t *testing.T, files map[string]string) *testClient {
+	t.Helper()
+	root := t.TempDir()
+	if _, ok := files["go.mod"]; !ok {
+		writeTestFile(t, filepath.Join(root, "go.mod"), testGoMod)
+	}
+	for name, data := range files {
+		writeTestFile(t, filepath.Join(root, name), data)
+	}
+	return dialTestClient(t, newTestServer(t), &InitializeParams{RootURI: URIOf(root)})
+}
+
+// newTestServer starts a LangServer and returns its listener.
+func newTestServer(t *testing.T) jsonrpc2.Listener {
+	listener := jsonrpc2test.NetPipeListener()
+	server := NewServer(context.Background(), listener, nil)
+	t.Cleanup(func() {
+		listener.Close()
+		server.Wait()
+	})
+	return listener
+}
+
+// dialTestClient connects a new client to the server and initializes it.
+func dialTestClient(t *testing.T, listener jsonrpc2.Listener, params *InitializeParams) *testClient {
+	t.Helper()
+	c := &testClient{t: t, root: params.RootURI.Path(), vers: make(map[string]int32)}
+	conn, err := jsonrpc2.Dial(context.Background(), listener.Dialer(), jsonrpc2.BinderFunc(
+		func(ctx context.Context, conn *jsonrpc2.Connection) jsonrpc2.ConnectionOptions {
+			return jsonrpc2.ConnectionOptions{Handler: jsonrpc2.HandlerFunc(c.handle)}
+		}), nil)
+	if err != nil {
+		t.Fatal("Dial:", err)
+	}
+	c.conn = conn
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	var ret InitializeResult
+	if err = c.call(methodInitialize, params, &ret); err != nil {
+		t.Fatal("initialize:", err)
+	}
+	return c
+}
+
+func writeTestFile(t *testing.T, file, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *testClient) handle(ctx context.Context, req *jsonrpc2.Request) (any, error) {
+	c.mutex.Lock()
+	c.notes = append(c.notes, req)
+	c.mutex.Unlock()
+	if req.IsCall() {
+		return jsonNull, nil
+	}
+	return nil, nil
+}
+
+func (c *testClient) path(name string) string {
+	return filepath.Join(c.root, filepath.FromSlash(name))
+}
+
+func (c *testClient) uri(name string) DocumentURI {
+	return URIOf(c.path(name))
+}
+
+func (c *testClient) doc(name string) TextDocumentIdentifier {
+	return TextDocumentIdentifier{URI: c.uri(name)}
+}
+
+func (c *testClient) call(method string, params, result any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	return c.conn.Call(ctx, method, params).Await(ctx, result)
+}
+
+func (c *testClient) notify(method string, params any) {
+	c.t.Helper()
+	if err := c.conn.Notify(context.Background(), method, params); err != nil {
+		c.t.Fatal(method, err)
+	}
+}
+
+// sync makes sure the messages sent before have been handled by the server,
+// as the server handles them in order.
+func (c *testClient) sync() {
+	c.t.Helper()
+	if err := c.call(methodShutdown, nil, nil); err != nil {
+		c.t.Fatal("shutdown:", err)
+	}
+}
+
+// open opens file name with its content on disk, or text if it's given.
+func (c *testClient) open(name string, text ...string) {
+	c.t.Helper()
+	if text == nil {
+		data, err := os.ReadFile(c.path(name))
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		text = []string{string(data)}
+	}
+	c.mutex.Lock()
+	c.vers[name] = 1
+	c.mutex.Unlock()
+	c.notify(methodDidOpen, &DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: c.uri(name), LanguageID: "xgo", Version: 1, Text: text[0]},
+	})
+}
+
+// change replaces the whole content of the open document name.
+func (c *testClient) change(name, text string) {
+	c.t.Helper()
+	c.mutex.Lock()
+	c.vers[name]++
+	ver := c.vers[name]
+	c.mutex.Unlock()
+	c.notify(methodDidChange, &DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: c.uri(name), Version: ver},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: text}},
+	})
+}
+
+// waitNote waits for a notification or call of method from the server for
+// which ok returns true, and returns its params.
+func waitNote[T any](c *testClient, method string, ok func(params *T) bool) *T {
+	c.t.Helper()
+	deadline := time.Now().Add(time.Minute)
+	for seen := 0; ; {
+		c.mutex.Lock()
+		notes := c.notes[seen:]
+		c.mutex.Unlock()
+		for _, note := range notes {
+			seen++
+			if note.Method != method {
+				continue
+			}
+			params := new(T)
+			if err := json.Unmarshal(note.Params, params); err != nil {
+				c.t.Fatal(method, err)
+			}
+			if ok(params) {
+				return params
+			}
+		}
+		if time.Now().After(deadline) {
+			c.t.Fatal("timeout waiting for", method)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// diagnostics waits for diagnostics of the current version of document name.
+func (c *testClient) diagnostics(name string) []Diagnostic {
+	c.t.Helper()
+	uri := c.uri(name)
+	c.mutex.Lock()
+	ver := c.vers[name]
+	c.mutex.Unlock()
+	params := waitNote(c, methodPublishDiagnostics, func(params *PublishDiagnosticsParams) bool {
+		return params.URI == uri && params.Version == ver
+	})
+	return params.Diagnostics
+}
+
+// at returns the position of the n-th (0-based) occurrence of sub in file
+// name, as it's on disk.
+func (c *testClient) at(name, sub string, n int) Position {
+	c.t.Helper()
+	data, err := os.ReadFile(c.path(name))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return positionIn(c.t, string(data), sub, n)
+}
+
+func positionIn(t *testing.T, text, sub string, n int) Position {
+	t.Helper()
+	off := -len(sub)
+	for i := 0; i <= n; i++ {
+		j := strings.Index(text[off+len(sub):], sub)
+		if j < 0 {
+			t.Fatalf("%q not found", sub)
+		}
+		off += len(sub) + j
+	}
+	doc := &document{text: []byte(text)}
+	return doc.positionOf(off)
+}
+
+func (c *testClient) position(name, sub string, n int) *TextDocumentPositionParams {
+	c.t.Helper()
+	return &TextDocumentPositionParams{TextDocument: c.doc(name), Position: c.at(name, sub, n)}
+}
+
+func isInvalidParams(err error) bool {
+	return errors.Is(err, jsonrpc2.ErrInvalidParams)
+}
+
+// -----------------------------------------------------------------------------
+
+func TestInitialize(t *testing.T) {
+	c := newTestClient(t, map[string]string{"main.xgo": "echo 1\n"})
+	var ret InitializeResult
+	if err := c.call(methodInitialize, &InitializeParams{RootURI: URIOf(c.root)}, &ret); err != nil {
+		t.Fatal("initialize:", err)
+	}
+	caps := ret.Capabilities
+	if caps.TextDocumentSync == nil || caps.TextDocumentSync.Change != SyncIncremental ||
+		!caps.HoverProvider || caps.CompletionProvider == nil || caps.SemanticTokensProvider == nil {
+		t.Fatalf("capabilities: %+v", caps)
+	}
+	if ret.ServerInfo == nil || ret.ServerInfo.Name != "xgo" {
+		t.Fatalf("server info: %+v", ret.ServerInfo)
+	}
+	if err := c.call("xgo/unknown", nil, nil); !errors.Is(err, jsonrpc2.ErrMethodNotFound) {
+		t.Fatal("unknown method:", err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newTestClient(t, map[string]string{
+		"main.xgo": "echo add(1, 2)\n",
+		"add.xgo":  "func add(a, b int) int {\n\treturn a + b\n}\n",
+	})
+	c.open("main.xgo")
+	if diags := c.diagnostics("main.xgo"); len(diags) != 0 {
+		t.Fatal("diagnostics of valid code:", diags)
+	}
+
+	c.change("main.xgo", "echo add(1, 2)\necho undefinedName\n")
+	diags := c.diagnostics("main.xgo")
+	if len(diags) != 1 {
+		t.Fatal("diagnostics:", diags)
+	}
+	want := Range{Start: Position{Line: 1, Character: 5}, End: Position{Line: 1, Character: 18}}
+	if d := diags[0]; d.Range != want || d.Severity != SeverityError || d.Source != "xgo" {
+		t.Fatalf("diagnostic: %+v", d)
+	}
+
+	// errors in a file which is not open are reported too
+	c.change("main.xgo", "echo add(1, 2)\n")
+	c.open("add.xgo", "func add(a, b int) int {\n\treturn a + b +\n}\n")
+	if diags = c.diagnostics("add.xgo"); len(diags) == 0 {
+		t.Fatal("no syntax error")
+	}
+	c.notify(methodDidClose, &DidCloseTextDocumentParams{TextDocument: c.doc("add.xgo")})
+	params := waitNote(c, methodPublishDiagnostics, func(params *PublishDiagnosticsParams) bool {
+		return params.URI == c.uri("add.xgo") && params.Version == 0
+	})
+	if len(params.Diagnostics) != 0 {
+		t.Fatal("diagnostics of the closed file:", params.Diagnostics)
+	}
+}
+
+func TestDidChangeNotOpened(t *testing.T) {
+	c := newTestClient(t, map[string]string{"main.xgo": "echo 1\n"})
+	err := c.call(methodDidChange, &DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: c.uri("main.xgo"), Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "echo 2\n"}},
+	}, nil)
+	if !isInvalidParams(err) {
+		t.Fatal("didChange of a document not opened:", err)
+	}
+	if err = c.call(methodHover, 1, nil); !isInvalidParams(err) {
+		t.Fatal("hover with invalid params:", err)
+	}
+}
+
+func TestMainPkgOf(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  string
+	}{
+		{[]string{"foo", "foo_test"}, "foo"},
+		{[]string{"foo_test"}, "foo_test"},
+		{[]string{"foo", "bar", "bar_test"}, "bar"},
+		{[]string{"foo_test", "bar_test"}, "bar_test"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		pkgs := make(map[string]*ast.Package)
+		for _, name := range tt.names {
+			pkgs[name] = &ast.Package{Name: name}
+		}
+		for i := 0; i < 10; i++ { // map iteration order varies
+			got := ""
+			if pkg := mainPkgOf(pkgs); pkg != nil {
+				got = pkg.Name
+			}
+			if got != tt.want {
+				t.Fatalf("mainPkgOf(%v) = %q, want %q", tt.names, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestConfInvalidated(t *testing.T) {
+	var ver uint64
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "go.mod"), testGoMod)
+	ws := newWorkspace(newOverlay(), func() uint64 { return ver })
+	conf, err := ws.confOf(root)
+	if err != nil {
+		t.Fatal("confOf:", err)
+	}
+	if conf2, _ := ws.confOf(root); conf2 != conf {
+		t.Fatal("confOf: the config is not cached")
+	}
+	ver++
+	if conf2, _ := ws.confOf(root); conf2 == conf {
+		t.Fatal("confOf: the config is not dropped after files changed")
+	}
+}
+
+// -----------------------------------------------------------------------------
@@ -0,0 +1,426 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	goast "go/ast"
+	"go/types"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goplus/mod/xgomod"
+	"github.com/goplus/xgo/ast"
+	"github.com/goplus/xgo/cl/outline"
+	"github.com/goplus/xgo/token"
+	"github.com/goplus/xgo/tool"
+)
+
+// -----------------------------------------------------------------------------
+
+// extent is the source range of a declaration.
+type extent struct {
+	start, end token.Pos
+}
+
+// extentsOf returns source ranges of declarations in an XGo file, indexed by
+// positions of the declared names.
+func extentsOf(f *ast.File) map[token.Pos]extent {
+	ret := make(map[token.Pos]extent)
+	single := make(map[ast.Spec]extent) // specs of declarations without parens
+	specExtent := func(spec ast.Spec) extent {
+		if e, ok := single[spec]; ok {
+			return e
+		}
+		return extent{spec.Pos(), spec.End()}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch v := n.(type) {
+		case *ast.GenDecl:
+			if !v.Lparen.IsValid() && len(v.Specs) == 1 {
+				single[v.Specs[0]] = extent{v.Pos(), v.End()}
+			}
+		case *ast.FuncDecl:
+			if !v.Shadow {
+				ret[v.Name.Pos()] = extent{v.Pos(), v.End()}
+			}
+		case *ast.OverloadFuncDecl:
+			ret[v.Name.Pos()] = extent{v.Pos(), v.End()}
+			for _, fn := range v.Funcs {
+				ret[fn.Pos()] = extent{fn.Pos(), fn.End()}
+			}
+		case *ast.TypeSpec:
+			ret[v.Name.Pos()] = specExtent(v)
+		case *ast.ValueSpec:
+			for _, name := range v.Names {
+				ret[name.Pos()] = specExtent(v)
+			}
+		case *ast.Field:
+			for _, name := range v.Names {
+				ret[name.Pos()] = extent{v.Pos(), v.End()}
+			}
+		}
+		return true
+	})
+	return ret
+}
+
+// goExtentsOf is the same as extentsOf but for Go files.
+func goExtentsOf(f *goast.File) map[token.Pos]extent {
+	ret := make(map[token.Pos]extent)
+	single := make(map[goast.Spec]extent)
+	specExtent := func(spec goast.Spec) extent {
+		if e, ok := single[spec]; ok {
+			return e
+		}
+		return extent{spec.Pos(), spec.End()}
+	}
+	goast.Inspect(f, func(n goast.Node) bool {
+		switch v := n.(type) {
+		case *goast.GenDecl:
+			if !v.Lparen.IsValid() && len(v.Specs) == 1 {
+				single[v.Specs[0]] = extent{v.Pos(), v.End()}
+			}
+		case *goast.FuncDecl:
+			ret[v.Name.Pos()] = extent{v.Pos(), v.End()}
+		case *goast.TypeSpec:
+			ret[v.Name.Pos()] = specExtent(v)
+		case *goast.ValueSpec:
+			for _, name := range v.Names {
+				ret[name.Pos()] = specExtent(v)
+			}
+		case *goast.Field:
+			for _, name := range v.Names {
+				ret[name.Pos()] = extent{v.Pos(), v.End()}
+			}
+		}
+		return true
+	})
+	return ret
+}
+
+// symbolBuilder builds document symbols of a file from the package outline.
+type symbolBuilder struct {
+	ws      *workspace
+	snap    *snapshot
+	all     *outline.All
+	file    string
+	extents map[token.Pos]extent
+}
+
+func (p *symbolBuilder) inFile(obj types.Object) bool {
+	return obj.Pos().IsValid() && p.snap.fset.Position(obj.Pos()).Filename == p.file
+}
+
+func (p *symbolBuilder) rangeOf(start, end token.Pos) Range {
+	fset := p.snap.fset
+	return p.ws.rangeOf(fset.Position(start), fset.Position(end))
+}
+
+func (p *symbolBuilder) newSymbol(obj types.Object, name string, kind SymbolKind) DocumentSymbol {
+	pos := obj.Pos()
+	sel := p.rangeOf(pos, pos+token.Pos(len(obj.Name())))
+	rg := sel
+	if e, ok := p.extents[pos]; ok {
+		rg = p.rangeOf(e.start, e.end)
+	}
+	return DocumentSymbol{
+		Name:           name,
+		Detail:         symbolDetail(obj, types.RelativeTo(p.all.Pkg())),
+		Kind:           kind,
+		Range:          rg,
+		SelectionRange: sel,
+	}
+}
+
+func symbolDetail(obj types.Object, qf types.Qualifier) string {
+	switch obj.(type) {
+	case *types.Func:
+		if members := overloadMembers(obj); members != nil {
+			return ""
+		}
+		return strings.TrimPrefix(types.TypeString(obj.Type(), qf), "func")
+	case *types.Var, *types.Const:
+		return types.TypeString(obj.Type(), qf)
+	}
+	return ""
+}
+
+func typeSymbolKind(t *types.TypeName) SymbolKind {
+	switch t.Type().Underlying().(type) {
+	case *types.Interface:
+		return InterfaceSymbol
+	case *types.Struct:
+		return StructSymbol
+	}
+	return ClassSymbol
+}
+
+// build returns the document symbols of the file.
+func (p *symbolBuilder) build(f *ast.File) (ret []DocumentSymbol) {
+	var classType string
+	if f != nil && f.IsClass {
+		classType, _ = tool.GetFileClassType(p.snap.mod, f, p.file)
+	}
+	for _, t := range p.all.Types {
+		ret = append(ret, p.typeSymbols(t, t.Name() == classType)...)
+	}
+	for _, c := range p.all.Consts {
+		if p.inFile(c.Const) {
+			ret = append(ret, p.newSymbol(c.Const, c.Name(), ConstantSymbol))
+		}
+	}
+	for _, v := range p.all.Vars {
+		if p.inFile(v.Var) {
+			ret = append(ret, p.newSymbol(v.Var, v.Name(), VariableSymbol))
+		}
+	}
+	ret = append(ret, p.funcSymbols(outlineFuncs(p.all.Funcs), FunctionSymbol)...)
+	sortSymbols(ret)
+	return
+}
+
+// funcSymbols returns symbols of functions in the file. Overload members are
+// shown with their user-facing names, as children of the overload
+// declaration if it's in the same file.
+func (p *symbolBuilder) funcSymbols(fns []*types.Func, kind SymbolKind) (ret []DocumentSymbol) {
+	decls := make(map[string]int) // name => index of overload declaration in ret
+	var members []*types.Func
+	for _, fn := range fns {
+		if !p.inFile(fn) {
+			continue
+		}
+		if _, _, ok := outline.CheckOverload(fn); ok {
+			members = append(members, fn)
+			continue
+		}
+		if overloadMembers(fn) != nil {
+			decls[fn.Name()] = len(ret)
+		}
+		ret = append(ret, p.newSymbol(fn, fn.Name(), kind))
+	}
+	for _, fn := range members {
+		name, _, _ := outline.CheckOverload(fn)
+		sym := p.newSymbol(fn, name, kind)
+		if i, ok := decls[name]; ok {
+			ret[i].Children = append(ret[i].Children, sym)
+		} else {
+			ret = append(ret, sym)
+		}
+	}
+	return
+}
+
+// typeSymbols returns symbols of type t in the file. If t is the class type
+// of the file, it covers the whole file. Members of t in other files than
+// t are returned as top-level symbols, qualified by the type name.
+func (p *symbolBuilder) typeSymbols(t *outline.TypeName, isClass bool) (ret []DocumentSymbol) {
+	var children []DocumentSymbol
+	if named, ok := t.TypeName.Type().(*types.Named); ok && !t.IsAlias() {
+		if st, ok := named.Underlying().(*types.Struct); ok {
+			for i, n := 0, st.NumFields(); i < n; i++ {
+				if fld := st.Field(i); p.inFile(fld) {
+					children = append(children, p.newSymbol(fld, fld.Name(), FieldSymbol))
+				}
+			}
+		} else if it, ok := named.Underlying().(*types.Interface); ok {
+			for i, n := 0, it.NumExplicitMethods(); i < n; i++ {
+				if fn := it.ExplicitMethod(i); p.inFile(fn) {
+					children = append(children, p.newSymbol(fn, fn.Name(), MethodSymbol))
+				}
+			}
+		}
+		fns := make([]*types.Func, named.NumMethods())
+		for i := range fns {
+			fns[i] = named.Method(i)
+		}
+		children = append(children, p.funcSymbols(fns, MethodSymbol)...)
+	}
+	for _, c := range t.Consts {
+		if p.inFile(c.Const) {
+			children = append(children, p.newSymbol(c.Const, c.Name(), ConstantSymbol))
+		}
+	}
+	children = append(children, p.funcSymbols(outlineFuncs(t.Creators), ConstructorSymbol)...)
+	children = append(children, p.funcSymbols(outlineFuncs(t.Helpers), FunctionSymbol)...)
+	for _, fn := range t.GoptFuncs {
+		if p.inFile(fn.Func) {
+			children = append(children, p.newSymbol(fn.Func, goptName(fn.Name()), MethodSymbol))
+		}
+	}
+	sortSymbols(children)
+
+	switch {
+	case isClass:
+		sym := DocumentSymbol{Name: t.Name(), Kind: ClassSymbol, Children: children}
+		if tf := p.fileOf(); tf != nil {
+			sym.Range = p.rangeOf(token.Pos(tf.Base()), token.Pos(tf.Base()+tf.Size()))
+			sym.SelectionRange = p.rangeOf(token.Pos(tf.Base()), token.Pos(tf.Base()))
+		}
+		ret = append(ret, sym)
+	case p.inFile(t.TypeName):
+		sym := p.newSymbol(t.TypeName, t.Name(), typeSymbolKind(t.TypeName))
+		sym.Children = children
+		ret = append(ret, sym)
+	default:
+		for _, sym := range children {
+			sym.Name = t.Name() + "." + sym.Name
+			ret = append(ret, sym)
+		}
+	}
+	return
+}
+
+func outlineFuncs(list []outline.Func) []*types.Func {
+	fns := make([]*types.Func, len(list))
+	for i, fn := range list {
+		fns[i] = fn.Func
+	}
+	return fns
+}
+
+func (p *symbolBuilder) fileOf() *token.File {
+	if f, ok := p.snap.files[p.file]; ok {
+		return p.snap.fset.File(f.Pos())
+	}
+	return nil
+}
+
+// goptName returns the method name of a Gopt_ function, eg. Run for
+// Gopt_App_Run.
+func goptName(name string) string {
+	name = strings.TrimPrefix(name, "Gopt_")
+	if pos := strings.IndexByte(name, '_'); pos >= 0 {
+		return name[pos+1:]
+	}
+	return name
+}
+
+func sortSymbols(syms []DocumentSymbol) {
+	sort.SliceStable(syms, func(i, j int) bool {
+		a, b := syms[i].Range.Start, syms[j].Range.Start
+		return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+	})
+}
+
+// -----------------------------------------------------------------------------
+
+// symbolsOf returns the document symbols of file in snapshot snap.
+func (p *session) symbolsOf(snap *snapshot, file string) []DocumentSymbol {
+	all := p.ws.outlineOf(snap)
+	if all == nil {
+		return nil
+	}
+	b := &symbolBuilder{ws: p.ws, snap: snap, all: all, file: file}
+	f, ok := snap.files[file]
+	if ok {
+		b.extents = extentsOf(f)
+	} else if gof, ok := snap.goFiles[file]; ok {
+		b.extents = goExtentsOf(gof)
+	} else {
+		return nil
+	}
+	return b.build(f)
+}
+
+// documentSymbol implements textDocument/documentSymbol.
+func (p *session) documentSymbol(params *DocumentSymbolParams) ([]DocumentSymbol, error) {
+	file := params.TextDocument.URI.Path()
+	return p.symbolsOf(p.snapshotOf(filepath.Dir(file)), file), nil
+}
+
+// workspaceSymbol implements workspace/symbol. It searches symbols whose
+// names contain the query, case-insensitively, in all packages of the
+// workspace.
+func (p *session) workspaceSymbol(params *WorkspaceSymbolParams) ([]SymbolInformation, error) {
+	query := strings.ToLower(params.Query)
+	var ret []SymbolInformation
+	var collect func(uri DocumentURI, syms []DocumentSymbol, container string)
+	collect = func(uri DocumentURI, syms []DocumentSymbol, container string) {
+		for _, sym := range syms {
+			if strings.Contains(strings.ToLower(sym.Name), query) {
+				ret = append(ret, SymbolInformation{
+					Name:          sym.Name,
+					Kind:          sym.Kind,
+					Location:      Location{URI: uri, Range: sym.SelectionRange},
+					ContainerName: container,
+				})
+			}
+			collect(uri, sym.Children, sym.Name)
+		}
+	}
+	for _, dir := range p.workspaceDirs() {
+		snap := p.snapshotOf(dir)
+		files := make([]string, 0, len(snap.files)+len(snap.goFiles))
+		for file := range snap.files {
+			files = append(files, file)
+		}
+		for file := range snap.goFiles {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			collect(URIOf(file), p.symbolsOf(snap, file), "")
+		}
+	}
+	return ret, nil
+}
+
+// workspaceDirs returns directories containing XGo/Go source files under
+// the workspace root, and directories of checked packages.
+func (p *session) workspaceDirs() []string {
+	dirs := make(map[string]bool)
+	for dir := range p.snaps {
+		dirs[dir] = true
+	}
+	if p.rootURI != "" {
+		root := p.rootURI.Path()
+		mod, _ := tool.LoadMod(root)
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				name := d.Name()
+				if path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata") {
+					return filepath.SkipDir
+				}
+			} else if isSourceFile(mod, d.Name()) {
+				dirs[filepath.Dir(path)] = true
+			}
+			return nil
+		})
+	}
+	ret := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		ret = append(ret, dir)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func isSourceFile(mod *xgomod.Module, name string) bool {
+	switch ext := filepath.Ext(name); ext {
+	case ".go", ".xgo", ".gop", ".gox":
+		return true
+	default:
+		return mod != nil && mod.IsClass(ext)
+	}
+}
+
+// -----------------------------------------------------------------------------
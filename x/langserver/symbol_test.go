@@ -0,0 +1,172 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package langserver
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var symbolFiles = map[string]string{
+	"types.xgo": `const Pi = 3.14
+
+var count int
+
+type Point struct {
+	X, Y int
+}
+
+func (p *Point) Move(dx, dy int) {
+	p.X += dx
+	p.Y += dy
+}
+
+func NewPoint(x, y int) *Point {
+	return &Point{X: x, Y: y}
+}
+
+func add = (
+	func(a, b int) int {
+		return a + b
+	}
+	func(a, b string) string {
+		return a + b
+	}
+)
+`,
+	"point.go": `package main
+
+import "fmt"
+
+func (p *Point) String() string {
+	return fmt.Sprint(p.X, ",", p.Y)
+}
+`,
+	"main.xgo": `echo NewPoint(1, 2), add(1, 2), Pi, count
+`,
+}
+
+// symbolTree returns symbols as lines of "name kind detail", children are
+// indented by a tab.
+func symbolTree(syms []DocumentSymbol, indent string) string {
+	var b strings.Builder
+	for _, sym := range syms {
+		fmt.Fprintf(&b, "%s%s %d %s\n", indent, sym.Name, sym.Kind, sym.Detail)
+		b.WriteString(symbolTree(sym.Children, indent+"\t"))
+	}
+	return b.String()
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	c := newTestClient(t, symbolFiles)
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"types.xgo", `Pi 14 untyped float
+count 13 int
+Point 23 
+	X 8 int
+	Y 8 int
+	Move 6 (dx int, dy int)
+	NewPoint 9 (x int, y int) *Point
+add 12 
+	add 12 (a int, b int) int
+	add 12 (a string, b string) string
+`},
+		{"point.go", "Point.String 6 () string\n"},
+		{"main.xgo", "main 12 ()\n"}, // the main function of a script
+	}
+	for _, tt := range tests {
+		var syms []DocumentSymbol
+		if err := c.call(methodDocumentSymbol, &DocumentSymbolParams{TextDocument: c.doc(tt.name)}, &syms); err != nil {
+			t.Fatal("documentSymbol:", err)
+		}
+		if syms == nil {
+			t.Fatalf("documentSymbol %s: null", tt.name)
+		}
+		if got := symbolTree(syms, ""); got != tt.want {
+			t.Errorf("documentSymbol %s:\n%s\nwant:\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDocumentSymbolRange(t *testing.T) {
+	c := newTestClient(t, symbolFiles)
+	var syms []DocumentSymbol
+	if err := c.call(methodDocumentSymbol, &DocumentSymbolParams{TextDocument: c.doc("types.xgo")}, &syms); err != nil {
+		t.Fatal("documentSymbol:", err)
+	}
+	for _, sym := range syms {
+		if sym.Name != "Point" {
+			continue
+		}
+		want := Range{Start: c.at("types.xgo", "type Point", 0), End: c.at("types.xgo", "\n\nfunc (p", 0)}
+		if sym.Range != want {
+			t.Errorf("range of Point: got %v, want %v", sym.Range, want)
+		}
+		start := c.at("types.xgo", "Point struct", 0)
+		if sel := sym.SelectionRange; sel.Start != start || sel.End.Character != start.Character+5 {
+			t.Errorf("selection range of Point: %v", sel)
+		}
+		return
+	}
+	t.Fatal("Point not found")
+}
+
+func TestDocumentSymbolOpenDocument(t *testing.T) {
+	c := newTestClient(t, symbolFiles)
+	c.open("main.xgo", "func hello() {}\n\necho hello\n")
+	var syms []DocumentSymbol
+	if err := c.call(methodDocumentSymbol, &DocumentSymbolParams{TextDocument: c.doc("main.xgo")}, &syms); err != nil {
+		t.Fatal("documentSymbol:", err)
+	}
+	if got := symbolTree(syms, ""); got != "hello 12 ()\nmain 12 ()\n" {
+		t.Fatalf("documentSymbol of the open document:\n%s", got)
+	}
+
+	// neither opened nor on disk
+	syms = nil
+	if err := c.call(methodDocumentSymbol, &DocumentSymbolParams{TextDocument: c.doc("none.xgo")}, &syms); err != nil || syms == nil || len(syms) != 0 {
+		t.Fatal("documentSymbol of a file not found:", syms, err)
+	}
+}
+
+func TestWorkspaceSymbol(t *testing.T) {
+	files := map[string]string{
+		"sub/sub.xgo": "func PointSub() {}\n",
+		"_skip/a.xgo": "func PointSkipped() {}\n",
+	}
+	for name, data := range symbolFiles {
+		files[name] = data
+	}
+	c := newTestClient(t, files)
+	var syms []SymbolInformation
+	if err := c.call(methodWorkspaceSymbol, &WorkspaceSymbolParams{Query: "point"}, &syms); err != nil {
+		t.Fatal("workspace/symbol:", err)
+	}
+	var got []string
+	for _, sym := range syms {
+		name := strings.TrimPrefix(string(sym.Location.URI), string(URIOf(c.root))+"/")
+		got = append(got, fmt.Sprintf("%s %s %s", name, sym.ContainerName, sym.Name))
+	}
+	want := "point.go  Point.String types.xgo  Point types.xgo Point NewPoint sub/sub.xgo  PointSub"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("workspace/symbol:\n%s\nwant:\n%s", strings.Join(got, " "), want)
+	}
+}
@@ -258,6 +258,19 @@ func convErr(fset *token.FileSet, e error) (ret types.Error, ok bool) {
 	return
 }
 
+// ErrorRange returns the source range [start, end) of a type-checking error.
+// If the error doesn't carry range information, start and end are both err.Pos.
+func ErrorRange(err types.Error) (start, end token.Pos) {
+	_, start, end = typesutil.GetErrorGo116(&err)
+	if !start.IsValid() {
+		start = err.Pos
+	}
+	if end < start {
+		end = start
+	}
+	return
+}
+
 func scopeDelete(objMap map[types.Object]types.Object, scope *types.Scope, name string) {
 	if o := typesutil.ScopeDelete(scope, name); o != nil {
 		objMap[o] = nil
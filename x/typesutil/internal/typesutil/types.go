@@ -77,6 +77,11 @@ func SetErrorGo116(ret *types.Error, code int, start, end token.Pos) {
 	e.go116end = end
 }
 
+func GetErrorGo116(ret *types.Error) (code int, start, end token.Pos) {
+	e := (*Error)(unsafe.Pointer(ret))
+	return e.go116code, e.go116start, e.go116end
+}
+
 // -----------------------------------------------------------------------------
 
 func init() {
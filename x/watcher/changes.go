@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/goplus/mod/xgomod"
 )
@@ -47,24 +48,41 @@ func (p *module) ignore(fname string) bool {
 
 type none struct{}
 
+// Changes collects the changes of files in a workspace. They are debounced,
+// and fetched in batches by FetchEvents, or by directory by Fetch.
 type Changes struct {
-	changed map[string]none
+	batches [][]Event
+	dirs    []string // directories to be fetched by Fetch
 	mods    map[string]*module
 	mutex   sync.Mutex
 	cond    sync.Cond
 
-	root string
+	events *Debouncer
+	root   string
 }
 
 func NewChanges(root string) *Changes {
-	changed := make(map[string]none)
 	mods := make(map[string]*module)
 	root, _ = filepath.Abs(root)
-	c := &Changes{changed: changed, mods: mods, root: root + "/"}
+	c := &Changes{mods: mods, root: root + "/"}
 	c.cond.L = &c.mutex
+	c.events = NewDebouncer(DefaultQuiet, c.addBatch)
 	return c
 }
 
+// SetQuiet sets how long no change must happen before changes are fetched.
+// Default is DefaultQuiet.
+func (p *Changes) SetQuiet(quiet time.Duration) {
+	p.events.SetQuiet(quiet)
+}
+
+func (p *Changes) addBatch(events []Event) {
+	p.mutex.Lock()
+	p.batches = append(p.batches, events)
+	p.mutex.Unlock()
+	p.cond.Broadcast()
+}
+
 func (p *Changes) doLookupMod(name string) *module {
 	mod, ok := p.mods[name]
 	if !ok {
@@ -104,15 +122,29 @@ func (p *Changes) deleteMod(dir string) {
 	p.mutex.Unlock()
 }
 
-func (p *Changes) Fetch(fullPath bool) (dir string) {
+// FetchEvents waits for a batch of changes. Use either FetchEvents or Fetch,
+// as they consume the same changes.
+func (p *Changes) FetchEvents() (events []Event) {
 	p.mutex.Lock()
-	for len(p.changed) == 0 {
+	for len(p.batches) == 0 {
 		p.cond.Wait()
 	}
-	for dir = range p.changed {
-		delete(p.changed, dir)
-		break
+	events, p.batches = p.batches[0], p.batches[1:]
+	p.mutex.Unlock()
+	return
+}
+
+// Fetch waits for a directory with changed files.
+func (p *Changes) Fetch(fullPath bool) (dir string) {
+	p.mutex.Lock()
+	for len(p.dirs) == 0 {
+		for len(p.batches) == 0 {
+			p.cond.Wait()
+		}
+		p.dirs = appendDirs(p.dirs, Dirs(p.batches[0]))
+		p.batches = p.batches[1:]
 	}
+	dir, p.dirs = p.dirs[0], p.dirs[1:]
 	p.mutex.Unlock()
 	if fullPath {
 		dir = p.root + dir
@@ -120,6 +152,19 @@ func (p *Changes) Fetch(fullPath bool) (dir string) {
 	return
 }
 
+func appendDirs(dirs, adds []string) []string {
+next:
+	for _, add := range adds {
+		for _, dir := range dirs {
+			if dir == add {
+				continue next
+			}
+		}
+		dirs = append(dirs, add)
+	}
+	return dirs
+}
+
 func (p *Changes) Ignore(name string, isDir bool) bool {
 	dir, fname := path.Split(name)
 	if strings.HasPrefix(fname, "_") {
@@ -129,22 +174,30 @@ func (p *Changes) Ignore(name string, isDir bool) bool {
 }
 
 func (p *Changes) FileChanged(name string) {
-	dir := path.Dir(name)
-	p.mutex.Lock()
-	n := len(p.changed)
-	p.changed[dir] = none{}
-	p.mutex.Unlock()
-	if n == 0 {
-		p.cond.Broadcast()
+	p.events.Add(Event{Name: name, Kind: Modified})
+}
+
+func (p *Changes) FileChangedEx(name string, created bool) {
+	kind := Modified
+	if created {
+		kind = Created
 	}
+	p.events.Add(Event{Name: name, Kind: kind})
 }
 
 func (p *Changes) EntryDeleted(name string, isDir bool) {
+	p.entryGone(name, isDir, Deleted)
+}
+
+func (p *Changes) EntryRenamed(name string, isDir bool) {
+	p.entryGone(name, isDir, Renamed)
+}
+
+func (p *Changes) entryGone(name string, isDir bool, kind EventKind) {
 	if isDir {
 		p.deleteMod(name)
-	} else {
-		p.FileChanged(name)
 	}
+	p.events.Add(Event{Name: name, Kind: kind, IsDir: isDir})
 }
 
 func (p *Changes) DirAdded(name string) {
@@ -154,9 +207,9 @@ func (p *Changes) DirAdded(name string) {
 			return err
 		}
 		entry, _ = filepath.Rel(dir, entry)
-		entry = filepath.ToSlash(entry)
+		entry = path.Join(name, filepath.ToSlash(entry))
 		if !p.Ignore(entry, false) {
-			p.FileChanged(entry)
+			p.FileChangedEx(entry, true)
 		}
 		return nil
 	})
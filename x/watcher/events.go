@@ -0,0 +1,179 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package watcher
+
+import (
+	"path"
+	"sync"
+	"time"
+)
+
+// -----------------------------------------------------------------------------------------
+
+// EventKind is the kind of a change of a file.
+type EventKind int
+
+const (
+	Created EventKind = iota + 1
+	Modified
+	Deleted
+	Renamed // renamed to another name, which is reported as Created
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case Created:
+		return "create"
+	case Modified:
+		return "modify"
+	case Deleted:
+		return "delete"
+	case Renamed:
+		return "rename"
+	}
+	return "unknown"
+}
+
+// Event is a change of a file or a directory.
+type Event struct {
+	Name  string // relative to the root of Changes, with slashes
+	Kind  EventKind
+	IsDir bool
+}
+
+func (e Event) String() string {
+	return e.Kind.String() + " " + e.Name
+}
+
+// Dirs returns the directories of files changed by events, in the order they
+// first appear. Events of directories are skipped.
+func Dirs(events []Event) []string {
+	var dirs []string
+	seen := make(map[string]none)
+	for _, e := range events {
+		if e.IsDir {
+			continue
+		}
+		dir := path.Dir(e.Name)
+		if _, ok := seen[dir]; !ok {
+			seen[dir] = none{}
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// mergeKind returns the kind of two successive changes of a file, 0 if they
+// cancel out.
+func mergeKind(old, new EventKind) EventKind {
+	switch new {
+	case Created:
+		if old == Created {
+			return Created
+		}
+		return Modified // eg. an atomic save removes the file and then creates it
+	case Modified:
+		if old == Created {
+			return Created
+		}
+		return Modified
+	default: // Deleted, Renamed
+		if old == Created {
+			return 0 // eg. a temp file
+		}
+		return new
+	}
+}
+
+// -----------------------------------------------------------------------------------------
+
+// DefaultQuiet is the default quiet period of a Debouncer used by Changes.
+const DefaultQuiet = 100 * time.Millisecond
+
+// Debouncer batches events, and delivers them once no event happens for a
+// quiet period. Successive events of a file are merged into one, eg. a file
+// created and then modified is reported as created.
+type Debouncer struct {
+	mutex  sync.Mutex
+	events []Event
+	index  map[string]int // name => index in events
+	timer  *time.Timer
+	last   time.Time // when the last event happened
+
+	quiet   time.Duration
+	deliver func(events []Event)
+}
+
+// NewDebouncer creates a Debouncer which calls deliver with a batch of events
+// each time no event happens for the quiet period.
+func NewDebouncer(quiet time.Duration, deliver func(events []Event)) *Debouncer {
+	return &Debouncer{
+		index:   make(map[string]int),
+		quiet:   quiet,
+		deliver: deliver,
+	}
+}
+
+// SetQuiet sets the quiet period.
+func (p *Debouncer) SetQuiet(quiet time.Duration) {
+	p.mutex.Lock()
+	p.quiet = quiet
+	p.mutex.Unlock()
+}
+
+// Add adds an event, which is delivered after the quiet period.
+func (p *Debouncer) Add(e Event) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if i, ok := p.index[e.Name]; ok {
+		if e.Kind = mergeKind(p.events[i].Kind, e.Kind); e.Kind == 0 {
+			delete(p.index, e.Name)
+		}
+		p.events[i] = e
+	} else {
+		p.index[e.Name] = len(p.events)
+		p.events = append(p.events, e)
+	}
+	p.last = time.Now()
+	if p.timer == nil {
+		p.timer = time.AfterFunc(p.quiet, p.flush)
+	}
+}
+
+func (p *Debouncer) flush() {
+	p.mutex.Lock()
+	if wait := p.quiet - time.Since(p.last); wait > 0 { // changed again
+		p.timer.Reset(wait)
+		p.mutex.Unlock()
+		return
+	}
+	events := make([]Event, 0, len(p.events))
+	for _, e := range p.events {
+		if e.Kind != 0 {
+			events = append(events, e)
+		}
+	}
+	p.events, p.index, p.timer = nil, make(map[string]int), nil
+	p.mutex.Unlock()
+
+	if len(events) > 0 {
+		p.deliver(events)
+	}
+}
+
+// -----------------------------------------------------------------------------------------
@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2025 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package watcher
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMergeKind(t *testing.T) {
+	tests := []struct {
+		old, new, want EventKind
+	}{
+		{Created, Created, Created},
+		{Created, Modified, Created},
+		{Created, Deleted, 0},
+		{Created, Renamed, 0},
+		{Modified, Created, Modified},
+		{Modified, Modified, Modified},
+		{Modified, Deleted, Deleted},
+		{Modified, Renamed, Renamed},
+		{Deleted, Created, Modified},
+		{Deleted, Modified, Modified},
+		{Deleted, Deleted, Deleted},
+		{Renamed, Created, Modified},
+	}
+	for _, tt := range tests {
+		if got := mergeKind(tt.old, tt.new); got != tt.want {
+			t.Errorf("mergeKind(%v, %v) = %v, want %v", tt.old, tt.new, got, tt.want)
+		}
+	}
+}
+
+func TestDebouncerMerge(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []Event
+		want   []Event
+	}{
+		{"create+modify", []Event{
+			{Name: "a.xgo", Kind: Created},
+			{Name: "a.xgo", Kind: Modified},
+		}, []Event{{Name: "a.xgo", Kind: Created}}},
+		{"modify+delete", []Event{
+			{Name: "a.xgo", Kind: Modified},
+			{Name: "a.xgo", Kind: Deleted},
+		}, []Event{{Name: "a.xgo", Kind: Deleted}}},
+		{"create+delete", []Event{
+			{Name: "a.xgo~", Kind: Created},
+			{Name: "b.xgo", Kind: Modified},
+			{Name: "a.xgo~", Kind: Deleted},
+		}, []Event{{Name: "b.xgo", Kind: Modified}}},
+		{"create+delete+create", []Event{
+			{Name: "a.xgo", Kind: Created},
+			{Name: "a.xgo", Kind: Deleted},
+			{Name: "a.xgo", Kind: Created},
+		}, []Event{{Name: "a.xgo", Kind: Created}}},
+		{"delete+create", []Event{
+			{Name: "a.xgo", Kind: Deleted},
+			{Name: "a.xgo", Kind: Created},
+		}, []Event{{Name: "a.xgo", Kind: Modified}}},
+		{"order", []Event{
+			{Name: "b.xgo", Kind: Modified},
+			{Name: "a.xgo", Kind: Modified},
+			{Name: "b.xgo", Kind: Modified},
+		}, []Event{{Name: "b.xgo", Kind: Modified}, {Name: "a.xgo", Kind: Modified}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(chan []Event, 1)
+			p := NewDebouncer(10*time.Millisecond, func(events []Event) { got <- events })
+			for _, e := range tt.events {
+				p.Add(e)
+			}
+			select {
+			case events := <-got:
+				if !reflect.DeepEqual(events, tt.want) {
+					t.Fatalf("got %v, want %v", events, tt.want)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("events not delivered")
+			}
+		})
+	}
+}
+
+func TestDebouncerCancelOut(t *testing.T) {
+	delivered := make(chan []Event, 1)
+	p := NewDebouncer(10*time.Millisecond, func(events []Event) { delivered <- events })
+	p.Add(Event{Name: "a.xgo~", Kind: Created})
+	p.Add(Event{Name: "a.xgo~", Kind: Deleted})
+	select {
+	case events := <-delivered:
+		t.Fatal("empty batch delivered:", events)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDebouncerQuiet(t *testing.T) {
+	const quiet = 50 * time.Millisecond
+	delivered := make(chan []Event, 2)
+	p := NewDebouncer(quiet, func(events []Event) { delivered <- events })
+
+	// events keep coming within the quiet period: one batch after the last
+	start := time.Now()
+	var last time.Time
+	for i := 0; i < 5; i++ {
+		last = time.Now()
+		p.Add(Event{Name: "a.xgo", Kind: Modified})
+		time.Sleep(quiet / 5)
+	}
+	events := <-delivered
+	if since := time.Since(last); since < quiet {
+		t.Fatalf("delivered %v after the last event, want >= %v", since, quiet)
+	}
+	if len(events) != 1 {
+		t.Fatal("events:", events)
+	}
+	if time.Since(start) < quiet+4*quiet/5 {
+		t.Fatal("delivered before the quiet period")
+	}
+
+	// a new batch after the flush
+	p.Add(Event{Name: "b.xgo", Kind: Created})
+	events = <-delivered
+	if len(events) != 1 || events[0].Name != "b.xgo" {
+		t.Fatal("events:", events)
+	}
+	select {
+	case events = <-delivered:
+		t.Fatal("unexpected batch:", events)
+	case <-time.After(2 * quiet):
+	}
+}
+
+func TestDirs(t *testing.T) {
+	events := []Event{
+		{Name: "b/x.xgo", Kind: Modified},
+		{Name: "a", Kind: Created, IsDir: true},
+		{Name: "a/y.xgo", Kind: Created},
+		{Name: "b/z.xgo", Kind: Deleted},
+		{Name: "main.xgo", Kind: Modified},
+	}
+	if got, want := Dirs(events), []string{"b", "a", "."}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Dirs: got %v, want %v", got, want)
+	}
+}
@@ -18,6 +18,8 @@
 package watcher
 
 import (
+	"time"
+
 	"github.com/goplus/xgo/x/fsnotify"
 )
 
@@ -47,10 +49,34 @@ func New(root string) Runner {
 	return Runner{w: w, c: c}
 }
 
+// NewPoller creates a Runner which polls the file system every interval
+// instead of using inotify, see fsnotify.NewPoller.
+func NewPoller(root string, interval time.Duration) Runner {
+	w := fsnotify.NewPoller(interval)
+	c := NewChanges(root)
+	return Runner{w: w, c: c}
+}
+
+// Fetch waits for a directory with changed files, see Changes.Fetch.
 func (p Runner) Fetch(fullPath bool) (dir string) {
 	return p.c.Fetch(fullPath)
 }
 
+// FetchEvents waits for a batch of changes, see Changes.FetchEvents.
+func (p Runner) FetchEvents() []Event {
+	return p.c.FetchEvents()
+}
+
+// SetQuiet sets how long no change must happen before changes are fetched.
+func (p Runner) SetQuiet(quiet time.Duration) {
+	p.c.SetQuiet(quiet)
+}
+
+// Root returns the root directory being watched, with a trailing slash.
+func (p Runner) Root() string {
+	return p.c.root
+}
+
 func (p Runner) Run() error {
 	root := p.c.root
 	root = root[:len(root)-1]